@@ -0,0 +1,246 @@
+package alert
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+// Event 规则触发时产生的告警事件
+type Event struct {
+	Rule      string    `json:"rule"`      // 规则名称
+	Code      string    `json:"code"`      // 股票代码
+	Metric    string    `json:"metric"`    // 指标名称
+	Condition string    `json:"condition"` // 触发条件
+	Value     float64   `json:"value"`     // 触发时的指标值
+	Threshold float64   `json:"threshold"` // 阈值
+	Time      time.Time `json:"time"`      // 触发时间
+	Message   string    `json:"message"`   // 可读的描述
+}
+
+// Engine 告警引擎，可以在多个goroutine中使用
+type Engine struct {
+	mu      sync.Mutex
+	rules   map[string]*ruleState
+	byCode  map[string][]*ruleState
+	sinks   []Sink
+	onError func(Event, error)
+	now     func() time.Time
+}
+
+// NewEngine 创建告警引擎，规则触发时依次发送到sinks
+func NewEngine(sinks ...Sink) *Engine {
+	return &Engine{
+		rules:  make(map[string]*ruleState),
+		byCode: make(map[string][]*ruleState),
+		sinks:  sinks,
+		now:    time.Now,
+	}
+}
+
+// AddSink 添加告警事件的接收者
+func (e *Engine) AddSink(s Sink) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sinks = append(e.sinks, s)
+}
+
+// OnError 设置发送事件失败时的处理函数
+func (e *Engine) OnError(fn func(Event, error)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onError = fn
+}
+
+// AddRule 添加规则，同名规则已存在时返回错误
+func (e *Engine) AddRule(r Rule) error {
+	r, err := r.validate()
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.rules[r.Name]; ok {
+		return fmt.Errorf("alert rule %s already exists", r.Name)
+	}
+	st := newRuleState(r)
+	e.rules[r.Name] = st
+	e.byCode[r.Code] = append(e.byCode[r.Code], st)
+	return nil
+}
+
+// RemoveRule 删除规则
+func (e *Engine) RemoveRule(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st, ok := e.rules[name]
+	if !ok {
+		return
+	}
+	delete(e.rules, name)
+	rules := e.byCode[st.rule.Code]
+	for i, r := range rules {
+		if r == st {
+			e.byCode[st.rule.Code] = append(rules[:i:i], rules[i+1:]...)
+			break
+		}
+	}
+	if len(e.byCode[st.rule.Code]) == 0 {
+		delete(e.byCode, st.rule.Code)
+	}
+}
+
+// Rules 返回所有规则
+func (e *Engine) Rules() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rules := make([]Rule, 0, len(e.rules))
+	for _, st := range e.rules {
+		rules = append(rules, st.rule)
+	}
+	return rules
+}
+
+// OnSnapshot 用快照评估规则
+func (e *Engine) OnSnapshot(s qosapi.WSSnapshot) {
+	values := make(map[Metric]float64)
+	lp, err := strconv.ParseFloat(s.LastPrice, 64)
+	if err != nil {
+		return
+	}
+	values[MetricLastPrice] = lp
+	if yp, err := strconv.ParseFloat(s.PrevClose, 64); err == nil && yp != 0 {
+		values[MetricChangePercent] = (lp - yp) / yp * 100
+	}
+	e.evaluate(s.Code, "S", values)
+}
+
+// OnTrade 用逐笔成交评估规则
+func (e *Engine) OnTrade(t qosapi.WSTrade) {
+	values := make(map[Metric]float64)
+	if p, err := strconv.ParseFloat(t.Price, 64); err == nil {
+		values[MetricLastPrice] = p
+	}
+	if v, err := strconv.ParseFloat(t.Volume, 64); err == nil {
+		values[MetricTradeVolume] = v
+	}
+	e.evaluate(t.Code, "T", values)
+}
+
+// OnDepth 用盘口评估规则，任意一侧为空时不评估
+func (e *Engine) OnDepth(d qosapi.WSDepth) {
+	if len(d.Bids) == 0 || len(d.Asks) == 0 {
+		return
+	}
+	bid, err1 := strconv.ParseFloat(d.Bids[0].Price, 64)
+	ask, err2 := strconv.ParseFloat(d.Asks[0].Price, 64)
+	if err1 != nil || err2 != nil {
+		return
+	}
+	e.evaluate(d.Code, "D", map[Metric]float64{MetricSpread: ask - bid})
+}
+
+// evaluate 评估代码上所有数据来源匹配的规则，并在不持有锁时发送事件
+func (e *Engine) evaluate(code, source string, values map[Metric]float64) {
+	if sym, err := qosapi.ParseSymbol(code); err == nil {
+		code = sym.String()
+	}
+
+	e.mu.Lock()
+	now := e.now()
+	var events []Event
+	for _, st := range e.byCode[code] {
+		r := st.rule
+		if !strings.Contains(r.Metric.source(), source) {
+			continue
+		}
+		metric := r.Metric
+		if metric == MetricSpreadTicks {
+			metric = MetricSpread
+		}
+		v, ok := values[metric]
+		if !ok {
+			continue
+		}
+		if r.Metric == MetricSpreadTicks {
+			// 消除浮点误差，使整数倍的价差得到整数档位
+			v = math.Round(v/r.TickSize*1e8) / 1e8
+		}
+		if st.eval(v, now) {
+			events = append(events, Event{
+				Rule:      r.Name,
+				Code:      code,
+				Metric:    r.Metric.String(),
+				Condition: r.Condition.String(),
+				Value:     v,
+				Threshold: r.Threshold,
+				Time:      now,
+				Message:   fmt.Sprintf("%s %s %g %s %g", code, r.Metric, v, r.Condition, r.Threshold),
+			})
+		}
+	}
+	sinks, onError := e.sinks, e.onError
+	e.mu.Unlock()
+
+	for _, ev := range events {
+		for _, s := range sinks {
+			if err := s.Send(ev); err != nil && onError != nil {
+				onError(ev, err)
+			}
+		}
+	}
+}
+
+// Subscribe 通过行情来源(WSClient或Replayer)订阅规则需要的快照、逐笔成交和盘口，
+// 之后添加的规则需要再次调用Subscribe。返回的订阅可用于取消
+func (e *Engine) Subscribe(src qosapi.MarketDataSource) ([]*qosapi.Subscription, error) {
+	e.mu.Lock()
+	codes := make(map[string][]string) // 数据来源 -> 代码
+	for code, rules := range e.byCode {
+		seen := make(map[string]bool)
+		for _, st := range rules {
+			for _, source := range st.rule.Metric.source() {
+				if !seen[string(source)] {
+					seen[string(source)] = true
+					codes[string(source)] = append(codes[string(source)], code)
+				}
+			}
+		}
+	}
+	e.mu.Unlock()
+
+	var subs []*qosapi.Subscription
+	fail := func(err error) ([]*qosapi.Subscription, error) {
+		for _, sub := range subs {
+			sub.Unsubscribe()
+		}
+		return nil, err
+	}
+	if len(codes["S"]) > 0 {
+		sub, err := src.OnSnapshot(codes["S"], e.OnSnapshot)
+		if err != nil {
+			return fail(err)
+		}
+		subs = append(subs, sub)
+	}
+	if len(codes["T"]) > 0 {
+		sub, err := src.OnTrade(codes["T"], e.OnTrade)
+		if err != nil {
+			return fail(err)
+		}
+		subs = append(subs, sub)
+	}
+	if len(codes["D"]) > 0 {
+		sub, err := src.OnDepth(codes["D"], e.OnDepth)
+		if err != nil {
+			return fail(err)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
@@ -0,0 +1,278 @@
+package alert_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/alert"
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+	"github.com/qos-max/qos-quote-api-go-sdk/qostest"
+)
+
+// step 某一时刻的最新价
+type step struct {
+	at    time.Duration // 距开始的时间
+	price float64
+	fire  bool
+}
+
+// runRule 依次推送快照，返回每一步是否触发
+func runRule(t *testing.T, r alert.Rule, steps []step) []bool {
+	t.Helper()
+	var fired []alert.Event
+	e := alert.NewEngine(alert.FuncSink(func(ev alert.Event) { fired = append(fired, ev) }))
+	start := time.Date(2026, 3, 9, 14, 30, 0, 0, time.UTC)
+	var now time.Time
+	e.SetClock(func() time.Time { return now })
+	r.Name, r.Code = "rule", "US:AAPL"
+	if err := e.AddRule(r); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	var result []bool
+	for _, s := range steps {
+		now = start.Add(s.at)
+		n := len(fired)
+		e.OnSnapshot(qosapi.WSSnapshot{Code: "US:AAPL", LastPrice: fmt.Sprint(s.price)})
+		result = append(result, len(fired) > n)
+	}
+	return result
+}
+
+func TestRuleTriggers(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  alert.Rule
+		steps []step
+	}{
+		{
+			name:  "above fires on the first value",
+			rule:  alert.Rule{Condition: alert.Above, Threshold: 100},
+			steps: []step{{0, 101, true}, {1, 102, false}, {2, 99, false}, {3, 101, true}},
+		},
+		{
+			name:  "below",
+			rule:  alert.Rule{Condition: alert.Below, Threshold: 100},
+			steps: []step{{0, 99, true}, {1, 98, false}, {2, 100, false}, {3, 99, true}},
+		},
+		{
+			name:  "cross above needs a value below first",
+			rule:  alert.Rule{Condition: alert.CrossAbove, Threshold: 100},
+			steps: []step{{0, 101, false}, {1, 100, false}, {2, 101, true}, {3, 102, false}, {4, 99, false}, {5, 100.5, true}},
+		},
+		{
+			name:  "cross below",
+			rule:  alert.Rule{Condition: alert.CrossBelow, Threshold: 100},
+			steps: []step{{0, 99, false}, {1, 101, false}, {2, 99, true}, {3, 98, false}},
+		},
+		{
+			name:  "hysteresis",
+			rule:  alert.Rule{Condition: alert.CrossAbove, Threshold: 100, Hysteresis: 2},
+			steps: []step{{0, 98, false}, {1, 101, true}, {2, 99, false}, {3, 101, false}, {4, 98, false}, {5, 101, true}},
+		},
+		{
+			name:  "hysteresis below",
+			rule:  alert.Rule{Condition: alert.Below, Threshold: 100, Hysteresis: 1},
+			steps: []step{{0, 99, true}, {1, 100.5, false}, {2, 99, false}, {3, 101, false}, {4, 99, true}},
+		},
+		{
+			name: "cooldown",
+			rule: alert.Rule{Condition: alert.Above, Threshold: 100, Cooldown: time.Minute},
+			steps: []step{
+				{0, 101, true},
+				{10 * time.Second, 99, false},
+				{20 * time.Second, 101, false}, // 冷却期内
+				{30 * time.Second, 102, false}, // 被忽略的触发也需要先回到阈值以下
+				{40 * time.Second, 99, false},
+				{70 * time.Second, 101, true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runRule(t, tt.rule, tt.steps)
+			for i, s := range tt.steps {
+				if got[i] != s.fire {
+					t.Fatalf("step %d (%v at %v): fired = %v, want %v", i, s.price, s.at, got[i], s.fire)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricSources(t *testing.T) {
+	ch := make(chan alert.Event, 10)
+	e := alert.NewEngine(alert.ChanSink(ch))
+	for _, r := range []alert.Rule{
+		{Name: "change", Code: "hk:700", Metric: alert.MetricChangePercent, Condition: alert.Above, Threshold: 5},
+		{Name: "volume", Code: "HK:00700", Metric: alert.MetricTradeVolume, Condition: alert.Above, Threshold: 1000},
+		{Name: "ticks", Code: "HK:00700", Metric: alert.MetricSpreadTicks, Condition: alert.Above, Threshold: 2, TickSize: 0.2},
+	} {
+		if err := e.AddRule(r); err != nil {
+			t.Fatalf("AddRule %s: %v", r.Name, err)
+		}
+	}
+
+	e.OnTrade(qosapi.WSTrade{Code: "HK:700", Price: "400", Volume: "500"})
+	e.OnSnapshot(qosapi.WSSnapshot{Code: "HK:700", LastPrice: "330", PrevClose: "300"})
+	e.OnTrade(qosapi.WSTrade{Code: "HK:700", Price: "315", Volume: "2000"})
+	e.OnDepth(qosapi.WSDepth{Code: "HK:00700",
+		Bids: []qosapi.DepthItem{{Price: "314.8", Volume: "1"}}, Asks: []qosapi.DepthItem{{Price: "315.4", Volume: "1"}}})
+	e.OnDepth(qosapi.WSDepth{Code: "HK:00700", Bids: []qosapi.DepthItem{{Price: "314.8", Volume: "1"}}}) // 单边盘口不评估
+	close(ch)
+
+	var got []string
+	for ev := range ch {
+		if ev.Code != "HK:00700" {
+			t.Errorf("event code = %s, want HK:00700", ev.Code)
+		}
+		got = append(got, fmt.Sprintf("%s=%g", ev.Rule, ev.Value))
+	}
+	want := []string{"change=10", "volume=2000", "ticks=3"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+}
+
+func TestAddRuleValidation(t *testing.T) {
+	e := alert.NewEngine()
+	if err := e.AddRule(alert.Rule{Name: "ok", Code: "US:AAPL", Threshold: 1}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	for _, r := range []alert.Rule{
+		{Code: "US:AAPL"},
+		{Name: "ok", Code: "US:AAPL"},
+		{Name: "code", Code: "AAPL"},
+		{Name: "metric", Code: "US:AAPL", Metric: alert.Metric(99)},
+		{Name: "condition", Code: "US:AAPL", Condition: alert.Condition(-1)},
+		{Name: "ticks", Code: "US:AAPL", Metric: alert.MetricSpreadTicks},
+		{Name: "cooldown", Code: "US:AAPL", Cooldown: -time.Second},
+		{Name: "hysteresis", Code: "US:AAPL", Hysteresis: -1},
+	} {
+		if err := e.AddRule(r); err == nil {
+			t.Errorf("AddRule(%+v) succeeded", r)
+		}
+	}
+	if rules := e.Rules(); len(rules) != 1 {
+		t.Fatalf("Rules = %v, want one rule", rules)
+	}
+}
+
+func TestRemoveRuleAndSinkErrors(t *testing.T) {
+	ch := make(chan alert.Event, 1)
+	e := alert.NewEngine(alert.ChanSink(ch))
+	var sinkErrs []error
+	e.OnError(func(_ alert.Event, err error) { sinkErrs = append(sinkErrs, err) })
+	for _, name := range []string{"a", "b"} {
+		if err := e.AddRule(alert.Rule{Name: name, Code: "US:AAPL", Condition: alert.Above, Threshold: 100}); err != nil {
+			t.Fatalf("AddRule: %v", err)
+		}
+	}
+	// 两条规则同时触发，channel只能容纳一个事件
+	e.OnTrade(qosapi.WSTrade{Code: "US:AAPL", Price: "101"})
+	if len(ch) != 1 || len(sinkErrs) != 1 || !errors.Is(sinkErrs[0], alert.ErrSinkFull) {
+		t.Fatalf("queued %d events, sink errors %v", len(ch), sinkErrs)
+	}
+	<-ch
+
+	e.RemoveRule("a")
+	e.RemoveRule("missing")
+	e.OnTrade(qosapi.WSTrade{Code: "US:AAPL", Price: "99"})
+	e.OnTrade(qosapi.WSTrade{Code: "US:AAPL", Price: "101"})
+	if ev := <-ch; ev.Rule != "b" || len(ch) != 0 {
+		t.Fatalf("event after RemoveRule = %s", ev.Rule)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var mu sync.Mutex
+	var received []alert.Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev alert.Event
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if ev.Rule == "reject" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		mu.Lock()
+		received = append(received, ev)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	var failed []string
+	sink := alert.NewWebhookSink(srv.URL, alert.WebhookOptions{OnError: func(ev alert.Event, err error) {
+		failed = append(failed, ev.Rule)
+	}})
+	for _, name := range []string{"a", "reject", "b"} {
+		if err := sink.Send(alert.Event{Rule: name, Code: "US:AAPL", Value: 101}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sink.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := sink.Send(alert.Event{Rule: "late"}); !errors.Is(err, alert.ErrSinkClosed) {
+		t.Fatalf("Send after Close = %v, want ErrSinkClosed", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 || received[0].Rule != "a" || received[1].Rule != "b" || received[0].Value != 101 {
+		t.Fatalf("received %+v", received)
+	}
+	if len(failed) != 1 || failed[0] != "reject" {
+		t.Fatalf("failed = %v, want [reject]", failed)
+	}
+}
+
+func TestEngineSubscribe(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := srv.WSClient()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer client.Close()
+
+	ch := make(chan alert.Event, 1)
+	e := alert.NewEngine(alert.ChanSink(ch))
+	if err := e.AddRule(alert.Rule{Name: "spread", Code: "US:AAPL", Metric: alert.MetricSpread, Condition: alert.Above, Threshold: 0.05}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	subs, err := e.Subscribe(client)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if len(subs) != 1 {
+		t.Fatalf("Subscribe returned %d subscriptions, want only depth", len(subs))
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !srv.Subscribed("D", "US:AAPL") {
+		if time.Now().After(deadline) {
+			t.Fatal("depth was not subscribed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	srv.PushDepth(qosapi.WSDepth{Code: "US:AAPL",
+		Bids: []qosapi.DepthItem{{Price: "200.00", Volume: "1"}}, Asks: []qosapi.DepthItem{{Price: "200.10", Volume: "1"}}})
+	select {
+	case ev := <-ch:
+		if ev.Rule != "spread" {
+			t.Fatalf("event = %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no alert for pushed depth")
+	}
+}
@@ -0,0 +1,10 @@
+package alert
+
+import "time"
+
+// SetClock 替换Engine获取当前时间的函数，仅用于测试
+func (e *Engine) SetClock(now func() time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.now = now
+}
@@ -0,0 +1,164 @@
+// Package alert 根据实时快照、逐笔成交和盘口评估告警规则，规则触发时将事件发送到回调、
+// channel或webhook。规则为边沿触发：条件从不满足变为满足时触发一次，
+// 之后需要先回到阈值另一侧(考虑回差)才能再次触发，并且两次触发之间至少间隔冷却时间。
+//
+//	engine := alert.NewEngine(alert.FuncSink(func(e alert.Event) { log.Println(e.Message) }))
+//	engine.AddRule(alert.Rule{Name: "aapl-200", Code: "US:AAPL", Metric: alert.MetricLastPrice,
+//		Condition: alert.CrossAbove, Threshold: 200})
+//	subs, err := engine.Subscribe(wsClient)
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+// Metric 规则比较的行情指标
+type Metric int
+
+const (
+	MetricLastPrice     Metric = iota // 最新价，来自快照和逐笔成交
+	MetricChangePercent               // 相对昨收的涨跌幅(百分比，5表示5%)，来自快照
+	MetricSpread                      // 买卖价差(最优卖价-最优买价)，来自盘口
+	MetricSpreadTicks                 // 以最小价格变动单位计的买卖价差，来自盘口，需要设置Rule.TickSize
+	MetricTradeVolume                 // 单笔成交量，来自逐笔成交
+)
+
+// String 返回指标名称
+func (m Metric) String() string {
+	switch m {
+	case MetricLastPrice:
+		return "last price"
+	case MetricChangePercent:
+		return "change %"
+	case MetricSpread:
+		return "spread"
+	case MetricSpreadTicks:
+		return "spread ticks"
+	case MetricTradeVolume:
+		return "trade volume"
+	}
+	return fmt.Sprintf("metric(%d)", int(m))
+}
+
+// source 指标的数据来源
+func (m Metric) source() string {
+	switch m {
+	case MetricChangePercent:
+		return "S"
+	case MetricSpread, MetricSpreadTicks:
+		return "D"
+	case MetricTradeVolume:
+		return "T"
+	}
+	return "ST"
+}
+
+// Condition 触发条件
+type Condition int
+
+const (
+	Above      Condition = iota // 指标高于阈值，第一次取值就高于阈值时也会触发
+	Below                       // 指标低于阈值，第一次取值就低于阈值时也会触发
+	CrossAbove                  // 指标从不高于阈值变为高于阈值
+	CrossBelow                  // 指标从不低于阈值变为低于阈值
+)
+
+// String 返回条件名称
+func (c Condition) String() string {
+	switch c {
+	case Above:
+		return "above"
+	case Below:
+		return "below"
+	case CrossAbove:
+		return "crossed above"
+	case CrossBelow:
+		return "crossed below"
+	}
+	return fmt.Sprintf("condition(%d)", int(c))
+}
+
+// Rule 告警规则
+type Rule struct {
+	Name      string    // 规则名称，在同一个Engine中唯一
+	Code      string    // 股票代码，只能是单个代码
+	Metric    Metric    // 比较的指标
+	Condition Condition // 触发条件
+	Threshold float64   // 阈值
+
+	// Hysteresis 回差，触发后指标需要回到阈值另一侧超过Hysteresis才能再次触发，用于避免在阈值附近反复触发
+	Hysteresis float64
+
+	// Cooldown 两次触发之间的最小间隔，冷却期内满足条件的变化被忽略
+	Cooldown time.Duration
+
+	// TickSize 最小价格变动单位，MetricSpreadTicks需要
+	TickSize float64
+}
+
+// validate 校验并规范化规则
+func (r Rule) validate() (Rule, error) {
+	if r.Name == "" {
+		return r, errors.New("alert rule name is required")
+	}
+	sym, err := qosapi.ParseSymbol(r.Code)
+	if err != nil {
+		return r, fmt.Errorf("alert rule %s: %w", r.Name, err)
+	}
+	r.Code = sym.String()
+	if r.Metric < MetricLastPrice || r.Metric > MetricTradeVolume {
+		return r, fmt.Errorf("alert rule %s: unknown metric %d", r.Name, r.Metric)
+	}
+	if r.Condition < Above || r.Condition > CrossBelow {
+		return r, fmt.Errorf("alert rule %s: unknown condition %d", r.Name, r.Condition)
+	}
+	if r.Metric == MetricSpreadTicks && r.TickSize <= 0 {
+		return r, fmt.Errorf("alert rule %s: tick size is required for spread ticks", r.Name)
+	}
+	if r.Hysteresis < 0 || r.Cooldown < 0 {
+		return r, fmt.Errorf("alert rule %s: hysteresis and cooldown must not be negative", r.Name)
+	}
+	return r, nil
+}
+
+// ruleState 规则的触发状态
+type ruleState struct {
+	rule      Rule
+	armed     bool
+	lastFired time.Time
+}
+
+func newRuleState(r Rule) *ruleState {
+	// Above和Below第一次取值满足条件即触发，Cross*需要先观察到阈值另一侧的值
+	return &ruleState{rule: r, armed: r.Condition == Above || r.Condition == Below}
+}
+
+// eval 用新的指标值更新状态，返回是否触发
+func (s *ruleState) eval(v float64, now time.Time) bool {
+	r := s.rule
+	var active, rearm bool
+	switch r.Condition {
+	case Above, CrossAbove:
+		active, rearm = v > r.Threshold, v <= r.Threshold-r.Hysteresis
+	case Below, CrossBelow:
+		active, rearm = v < r.Threshold, v >= r.Threshold+r.Hysteresis
+	}
+
+	if !s.armed {
+		s.armed = rearm
+		return false
+	}
+	if !active {
+		return false
+	}
+	s.armed = false
+	if r.Cooldown > 0 && !s.lastFired.IsZero() && now.Sub(s.lastFired) < r.Cooldown {
+		return false
+	}
+	s.lastFired = now
+	return true
+}
@@ -0,0 +1,144 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrSinkFull 接收者的缓冲区已满，事件被丢弃
+var ErrSinkFull = errors.New("alert sink is full")
+
+// ErrSinkClosed 接收者已关闭
+var ErrSinkClosed = errors.New("alert sink is closed")
+
+// Sink 告警事件的接收者。Send在行情回调的goroutine中调用，应尽快返回
+type Sink interface {
+	Send(e Event) error
+}
+
+// FuncSink 将事件交给回调函数处理
+type FuncSink func(Event)
+
+// Send 实现Sink
+func (f FuncSink) Send(e Event) error {
+	f(e)
+	return nil
+}
+
+// ChanSink 将事件写入channel，channel已满时丢弃事件并返回ErrSinkFull
+type ChanSink chan<- Event
+
+// Send 实现Sink
+func (c ChanSink) Send(e Event) error {
+	select {
+	case c <- e:
+		return nil
+	default:
+		return ErrSinkFull
+	}
+}
+
+// DefaultWebhookTimeout webhook请求的默认超时时间
+const DefaultWebhookTimeout = 5 * time.Second
+
+// WebhookSink 将事件以JSON格式POST到指定URL。事件先进入缓冲区，由后台goroutine依次发送，
+// 不会阻塞行情回调
+type WebhookSink struct {
+	url     string
+	client  *http.Client
+	onError func(Event, error)
+
+	mu     sync.Mutex
+	queue  chan Event
+	done   chan struct{}
+	closed bool
+}
+
+// WebhookOptions webhook配置
+type WebhookOptions struct {
+	Client     *http.Client       // HTTP客户端，为nil时使用超时为DefaultWebhookTimeout的客户端
+	BufferSize int                // 缓冲区大小，默认为256
+	OnError    func(Event, error) // 发送失败时的处理函数
+}
+
+// NewWebhookSink 创建webhook接收者，url通常为本地服务的地址，如http://127.0.0.1:8080/alerts
+func NewWebhookSink(url string, opts WebhookOptions) *WebhookSink {
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: DefaultWebhookTimeout}
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 256
+	}
+	w := &WebhookSink{
+		url:     url,
+		client:  opts.Client,
+		onError: opts.OnError,
+		queue:   make(chan Event, opts.BufferSize),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Send 实现Sink，缓冲区已满时返回ErrSinkFull
+func (w *WebhookSink) Send(e Event) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrSinkClosed
+	}
+	select {
+	case w.queue <- e:
+		return nil
+	default:
+		return ErrSinkFull
+	}
+}
+
+// Close 停止接收事件，等待缓冲区中的事件发送完成或ctx结束
+func (w *WebhookSink) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *WebhookSink) run() {
+	defer close(w.done)
+	for e := range w.queue {
+		if err := w.post(e); err != nil && w.onError != nil {
+			w.onError(e, err)
+		}
+	}
+}
+
+func (w *WebhookSink) post(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s: status %d", w.url, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+var (
+	snapshotColumns = []string{"code", "time", "last", "prev_close", "change", "change_pct", "open", "high", "low", "volume", "turnover", "suspended"}
+	depthColumns    = []string{"code", "time", "side", "level", "price", "volume"}
+	tradeColumns    = []string{"code", "time", "price", "volume", "direction"}
+	klineColumns    = []string{"code", "type", "time", "open", "high", "low", "close", "volume"}
+	infoColumns     = []string{"code", "exchange", "currency", "name_cn", "name_en", "lot_size", "total_shares", "outstanding_shares", "eps", "nav", "dividend_yield"}
+)
+
+// klineTypes K线类型名称
+var klineTypes = []struct {
+	name string
+	kt   int
+}{
+	{"1m", qosapi.KLineTypeMin1},
+	{"5m", qosapi.KLineTypeMin5},
+	{"15m", qosapi.KLineTypeMin15},
+	{"30m", qosapi.KLineTypeMin30},
+	{"1h", qosapi.KLineTypeHour1},
+	{"2h", qosapi.KLineTypeHour2},
+	{"4h", qosapi.KLineTypeHour4},
+	{"1d", qosapi.KLineTypeDay},
+	{"1w", qosapi.KLineTypeWeek},
+	{"1M", qosapi.KLineTypeMonth},
+	{"1y", qosapi.KLineTypeYear},
+}
+
+// parseKLineType 解析K线类型，支持1m、1h、1d、1w、1M、1y等名称和接口使用的数值
+func parseKLineType(s string) (int, error) {
+	for _, t := range klineTypes {
+		if t.name == s {
+			return t.kt, nil
+		}
+	}
+	if kt, err := strconv.Atoi(s); err == nil {
+		for _, t := range klineTypes {
+			if t.kt == kt {
+				return kt, nil
+			}
+		}
+	}
+	names := make([]string, len(klineTypes))
+	for i, t := range klineTypes {
+		names[i] = t.name
+	}
+	return 0, fmt.Errorf("unknown kline type %q, expected one of %s", s, strings.Join(names, ", "))
+}
+
+func klineTypeName(kt int) string {
+	for _, t := range klineTypes {
+		if t.kt == kt {
+			return t.name
+		}
+	}
+	return strconv.Itoa(kt)
+}
+
+// formatTime 将时间戳(秒)格式化为代码所在市场的当地时间
+func formatTime(code string, ts int64) string {
+	if ts == 0 {
+		return ""
+	}
+	loc := time.UTC
+	if sym, err := qosapi.ParseSymbol(code); err == nil {
+		loc = sym.Location()
+	}
+	return time.Unix(ts, 0).In(loc).Format(time.RFC3339)
+}
+
+// parseTime 解析-from和-to参数，支持时间戳(秒)、RFC3339和当地时间的日期或日期时间。
+// endOfDay为true时只有日期的参数解析为当天最后一秒
+func parseTime(s string, loc *time.Location, endOfDay bool) (int64, error) {
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ts, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.Unix(), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	t, err := time.ParseInLocation(time.DateOnly, s, loc)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected unix seconds, RFC3339 or 2006-01-02[ 15:04[:05]]", s)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	return t.Unix(), nil
+}
+
+func snapshotRow(s qosapi.Snapshot) []string {
+	change, pct := "", ""
+	last, err1 := qosapi.ParseDecimal(s.LastPrice)
+	prev, err2 := qosapi.ParseDecimal(s.PrevClose)
+	if err1 == nil && err2 == nil && !prev.IsZero() {
+		diff := last.Sub(prev)
+		change = diff.String()
+		pct = diff.Mul(qosapi.NewDecimalFromInt(100)).Div(prev, 2).StringFixed(2)
+	}
+	return []string{s.Code, formatTime(s.Code, s.Timestamp), s.LastPrice, s.PrevClose, change, pct,
+		s.Open, s.High, s.Low, s.Volume, s.Turnover, strconv.FormatBool(s.Suspended != 0)}
+}
+
+// depthRows 返回盘口每一档的记录，levels大于0时只输出前levels档
+func depthRows(d qosapi.Depth, levels int) [][]string {
+	var rows [][]string
+	add := func(side string, items []qosapi.DepthItem) {
+		for i, item := range items {
+			if levels > 0 && i >= levels {
+				break
+			}
+			rows = append(rows, []string{d.Code, formatTime(d.Code, d.Timestamp), side, strconv.Itoa(i + 1), item.Price, item.Volume})
+		}
+	}
+	add("ask", d.Asks)
+	add("bid", d.Bids)
+	return rows
+}
+
+func tradeRow(t qosapi.Trade) []string {
+	direction := ""
+	switch t.Direction {
+	case qosapi.TradeDirectionBuy:
+		direction = "buy"
+	case qosapi.TradeDirectionSell:
+		direction = "sell"
+	}
+	return []string{t.Code, formatTime(t.Code, t.Timestamp), t.Price, t.Volume, direction}
+}
+
+func klineRow(k qosapi.KLine) []string {
+	return []string{k.Code, klineTypeName(k.KLineType), formatTime(k.Code, k.Timestamp), k.Open, k.High, k.Low, k.Close, k.Volume}
+}
+
+func infoRow(i qosapi.InstrumentInfo) []string {
+	return []string{i.Code, i.Exchange, i.TradeCurrency, i.NameCN, i.NameEN, strconv.FormatInt(i.LotSize, 10),
+		strconv.FormatInt(i.TotalShares, 10), strconv.FormatInt(i.OutstandingShares, 10), i.EPS, i.NAV, i.DividendYield}
+}
+
+// printRows 按输出格式打印所有记录
+func (e *env) printRows(columns []string, rows [][]string) error {
+	p, err := newPrinter(e.format, e.stdout, columns, false)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := p.Print(row); err != nil {
+			return err
+		}
+	}
+	return p.Flush()
+}
+
+func runSnapshot(ctx context.Context, e *env, args []string) error {
+	fs := e.flags()
+	codes, err := e.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	snapshots, err := client.GetSnapshotContext(ctx, codes)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, len(snapshots))
+	for i, s := range snapshots {
+		rows[i] = snapshotRow(s)
+	}
+	return e.printRows(snapshotColumns, rows)
+}
+
+func runDepth(ctx context.Context, e *env, args []string) error {
+	fs := e.flags()
+	levels := fs.Int("levels", 0, "每侧输出的档位数，0表示全部")
+	codes, err := e.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	depths, err := client.GetDepthContext(ctx, codes)
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	for _, d := range depths {
+		rows = append(rows, depthRows(d, *levels)...)
+	}
+	return e.printRows(depthColumns, rows)
+}
+
+func runTrades(ctx context.Context, e *env, args []string) error {
+	fs := e.flags()
+	count := fs.Int("count", 20, "每个代码的成交笔数")
+	codes, err := e.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	trades, err := client.GetTradeContext(ctx, codes, *count)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, len(trades))
+	for i, t := range trades {
+		rows[i] = tradeRow(t)
+	}
+	return e.printRows(tradeColumns, rows)
+}
+
+func runInfo(ctx context.Context, e *env, args []string) error {
+	fs := e.flags()
+	codes, err := e.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	infos, err := client.GetInstrumentInfoContext(ctx, codes)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, len(infos))
+	for i, info := range infos {
+		rows[i] = infoRow(info)
+	}
+	return e.printRows(infoColumns, rows)
+}
+
+func runKLine(ctx context.Context, e *env, args []string) error {
+	fs := e.flags()
+	typ := fs.String("type", "1d", "K线类型: 1m、5m、15m、30m、1h、2h、4h、1d、1w、1M、1y")
+	count := fs.Int("count", 100, "每个代码的K线数量")
+	adjust := fs.Bool("adjust", false, "前复权")
+	codes, err := e.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	kt, err := parseKLineType(*typ)
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	requests := make([]qosapi.KLineRequest, len(codes))
+	for i, code := range codes {
+		requests[i] = qosapi.KLineRequest{Codes: code, Count: *count, KLineType: kt, Adjust: boolInt(*adjust)}
+	}
+	result, err := client.GetKLineContext(ctx, requests)
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	for _, klines := range result {
+		for _, k := range klines {
+			rows = append(rows, klineRow(k))
+		}
+	}
+	return e.printRows(klineColumns, rows)
+}
+
+func runHistory(ctx context.Context, e *env, args []string) error {
+	fs := e.flags()
+	typ := fs.String("type", "1d", "K线类型: 1m、5m、15m、30m、1h、2h、4h、1d、1w、1M、1y")
+	from := fs.String("from", "", "起始时间(含)：时间戳(秒)、RFC3339或市场当地时间的2006-01-02[ 15:04[:05]]")
+	to := fs.String("to", "", "结束时间(含)，格式同-from，只有日期时包含当天，默认为最新")
+	adjust := fs.Bool("adjust", false, "前复权")
+	pageSize := fs.Int("page-size", qosapi.DefaultHistoryPageSize, "每页请求的K线数量")
+	codes, err := e.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *from == "" {
+		fs.Usage()
+		return errUsage
+	}
+	kt, err := parseKLineType(*typ)
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	p, err := newPrinter(e.format, e.stdout, klineColumns, false)
+	if err != nil {
+		return err
+	}
+	for _, code := range codes {
+		sym, err := qosapi.ParseSymbol(code)
+		if err != nil {
+			return err
+		}
+		q := qosapi.HistoryQuery{Code: sym.String(), KLineType: kt, Adjust: boolInt(*adjust), PageSize: *pageSize}
+		if q.Start, err = parseTime(*from, sym.Location(), false); err != nil {
+			return err
+		}
+		if *to != "" {
+			if q.End, err = parseTime(*to, sym.Location(), true); err != nil {
+				return err
+			}
+		}
+		klines, err := qosapi.CollectHistoryKLines(ctx, client.GetHistoryKLineContext, q)
+		if err != nil {
+			return fmt.Errorf("%s: %w", code, err)
+		}
+		for _, k := range klines {
+			if err := p.Print(klineRow(k)); err != nil {
+				return err
+			}
+		}
+	}
+	return p.Flush()
+}
+
+func runStream(ctx context.Context, e *env, args []string) error {
+	fs := e.flags()
+	typ := fs.String("type", "1m", "K线类型，仅用于stream kline")
+	levels := fs.Int("levels", 0, "盘口每侧输出的档位数，0表示全部，仅用于stream depth")
+	limit := fs.Int("n", 0, "收到n条推送后退出，0表示一直运行直到中断")
+	args, err := e.parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	kind, codes := args[0], args[1:]
+
+	var columns []string
+	switch kind {
+	case "snapshot":
+		columns = snapshotColumns
+	case "trades", "trade":
+		columns = tradeColumns
+	case "depth":
+		columns = depthColumns
+	case "kline":
+		columns = klineColumns
+	default:
+		fs.Usage()
+		return errUsage
+	}
+	kt, err := parseKLineType(*typ)
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(e.format, e.stdout, columns, true)
+	if err != nil {
+		return err
+	}
+
+	client, err := e.wsClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	client.StartHeartbeat(30 * time.Second)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		mu       sync.Mutex
+		received int
+		printErr error
+	)
+	emit := func(rows ...[]string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		for _, row := range rows {
+			if err := p.Print(row); err != nil {
+				printErr = err
+				cancel()
+				return
+			}
+		}
+		received++
+		if *limit > 0 && received >= *limit {
+			cancel()
+		}
+	}
+
+	var sub *qosapi.Subscription
+	switch kind {
+	case "snapshot":
+		sub, err = client.OnSnapshot(codes, func(s qosapi.WSSnapshot) {
+			emit(snapshotRow(qosapi.Snapshot{Code: s.Code, LastPrice: s.LastPrice, PrevClose: s.PrevClose,
+				Open: s.Open, High: s.High, Low: s.Low, Timestamp: s.Timestamp, Volume: s.Volume,
+				Turnover: s.Turnover, Suspended: s.Suspended, TradeSessionType: s.TradeSessionType}))
+		})
+	case "trades", "trade":
+		sub, err = client.OnTrade(codes, func(t qosapi.WSTrade) {
+			emit(tradeRow(qosapi.Trade{Code: t.Code, Price: t.Price, Volume: t.Volume, Timestamp: t.Timestamp, Direction: t.Direction}))
+		})
+	case "depth":
+		sub, err = client.OnDepth(codes, func(d qosapi.WSDepth) {
+			emit(depthRows(qosapi.Depth{Code: d.Code, Bids: d.Bids, Asks: d.Asks, Timestamp: d.Timestamp}, *levels)...)
+		})
+	case "kline":
+		sub, err = client.OnKLine(codes, kt, func(k qosapi.WSKLine) {
+			emit(klineRow(k.KLine()))
+		})
+	}
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	<-ctx.Done()
+	mu.Lock()
+	defer mu.Unlock()
+	if printErr != nil {
+		return printErr
+	}
+	return p.Flush()
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
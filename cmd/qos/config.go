@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+// config 配置文件内容
+type config struct {
+	APIKey  string `json:"api_key"`
+	BaseURL string `json:"base_url,omitempty"`
+	WSURL   string `json:"ws_url,omitempty"`
+}
+
+// defaultConfigPath 返回默认配置文件路径
+func defaultConfigPath() string {
+	if path := os.Getenv("QOS_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "qos", "config.json")
+}
+
+// loadConfig 读取配置文件，使用默认路径且文件不存在时返回空配置
+func loadConfig(path string, explicit bool) (config, error) {
+	var cfg config
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// env 命令的运行环境，包含所有命令共用的参数
+type env struct {
+	stdout io.Writer
+	cmd    command
+
+	key     string
+	config  string
+	baseURL string
+	wsURL   string
+	format  string
+	timeout time.Duration
+}
+
+// flags 创建注册了共用参数的FlagSet
+func (e *env) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet(e.cmd.name, flag.ContinueOnError)
+	fs.StringVar(&e.key, "key", "", "API Key，默认读取QOS_API_KEY环境变量或配置文件")
+	fs.StringVar(&e.config, "config", "", "配置文件路径，默认为QOS_CONFIG环境变量或<用户配置目录>/qos/config.json")
+	fs.StringVar(&e.baseURL, "base-url", "", "HTTP接口地址")
+	fs.StringVar(&e.wsURL, "ws-url", "", "WebSocket地址")
+	fs.StringVar(&e.format, "format", "table", "输出格式: table、json、ndjson、csv")
+	fs.DurationVar(&e.timeout, "timeout", 30*time.Second, "单次请求的超时时间")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: qos", e.cmd.usage)
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse 解析参数，允许参数出现在代码之后，如qos snapshot US:AAPL -format json。
+// 返回非参数部分，min为至少需要的数量
+func (e *env) parse(fs *flag.FlagSet, args []string, min int) ([]string, error) {
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, errUsage
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+	if len(rest) < min {
+		fs.Usage()
+		return nil, errUsage
+	}
+	return rest, nil
+}
+
+// options 合并命令行参数、环境变量和配置文件，返回API Key和客户端配置
+func (e *env) options() (string, []qosapi.Option, error) {
+	path, explicit := e.config, e.config != ""
+	if !explicit {
+		path = defaultConfigPath()
+	}
+	cfg, err := loadConfig(path, explicit)
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := e.key
+	if key == "" {
+		key = os.Getenv("QOS_API_KEY")
+	}
+	if key == "" {
+		key = cfg.APIKey
+	}
+	if key == "" {
+		return "", nil, errors.New("API key is required: use -key, QOS_API_KEY or the config file")
+	}
+
+	opts := []qosapi.Option{
+		qosapi.WithHTTPClient(&http.Client{Timeout: e.timeout}),
+		qosapi.WithRequestTimeout(e.timeout),
+	}
+	if url := firstNonEmpty(e.baseURL, cfg.BaseURL); url != "" {
+		opts = append(opts, qosapi.WithBaseURL(url))
+	}
+	if url := firstNonEmpty(e.wsURL, cfg.WSURL); url != "" {
+		opts = append(opts, qosapi.WithWSURL(url))
+	}
+	return key, opts, nil
+}
+
+// client 创建HTTP客户端
+func (e *env) client() (*qosapi.QOSClient, error) {
+	key, opts, err := e.options()
+	if err != nil {
+		return nil, err
+	}
+	return qosapi.NewClient(key, opts...), nil
+}
+
+// wsClient 创建并连接WebSocket客户端
+func (e *env) wsClient() (*qosapi.WSClient, error) {
+	key, opts, err := e.options()
+	if err != nil {
+		return nil, err
+	}
+	c := qosapi.NewWSClient(key, opts...)
+	if err := c.Connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
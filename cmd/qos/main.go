@@ -0,0 +1,97 @@
+// qos 是QOS行情接口的命令行工具，无需编写Go代码即可查询和订阅行情。
+//
+// 用法:
+//
+//	qos <命令> [参数] 代码...
+//
+// 命令:
+//
+//	snapshot  查询行情快照          qos snapshot US:AAPL HK:00700
+//	depth     查询盘口              qos depth US:AAPL
+//	trades    查询逐笔成交          qos trades -count 20 US:AAPL
+//	info      查询基础信息          qos info US:AAPL
+//	kline     查询最新K线           qos kline -type 1d -count 100 US:AAPL
+//	history   按时间范围查询历史K线  qos history -type 1d -from 2024-01-01 -to 2024-06-30 US:AAPL
+//	stream    订阅实时推送          qos stream trades CF:BTCUSDT
+//
+// 所有命令都支持-format参数选择输出格式：table(默认)、json、ndjson、csv。
+// API Key依次从-key参数、QOS_API_KEY环境变量和配置文件读取，配置文件默认为
+// <用户配置目录>/qos/config.json，可通过-config参数或QOS_CONFIG环境变量指定：
+//
+//	{"api_key": "your-api-key", "base_url": "https://api.qos.hk", "ws_url": "wss://api.qos.hk/ws"}
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	_ "time/tzdata" // 系统缺少时区数据库时使用内置数据
+)
+
+// command 子命令
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, env *env, args []string) error
+}
+
+var commands = []command{
+	{"snapshot", "snapshot [参数] 代码...", runSnapshot},
+	{"depth", "depth [参数] 代码...", runDepth},
+	{"trades", "trades [-count N] [参数] 代码...", runTrades},
+	{"info", "info [参数] 代码...", runInfo},
+	{"kline", "kline [-type 1d] [-count 100] [-adjust] [参数] 代码...", runKLine},
+	{"history", "history [-type 1d] -from 时间 [-to 时间] [-adjust] [参数] 代码...", runHistory},
+	{"stream", "stream snapshot|trades|depth|kline [-type 1m] [-n N] [参数] 代码...", runStream},
+}
+
+// errUsage 参数错误，打印用法后退出
+var errUsage = errors.New("usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, os.Args[1:], os.Stdout)
+	stop()
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	default:
+		fmt.Fprintln(os.Stderr, "qos:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(os.Stderr)
+		if len(args) == 0 {
+			return errUsage
+		}
+		return nil
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(ctx, &env{stdout: stdout, cmd: cmd}, args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "qos: unknown command %q\n\n", args[0])
+	usage(os.Stderr)
+	return errUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "用法: qos <命令> [参数] 代码...")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "命令:")
+	for _, cmd := range commands {
+		fmt.Fprintln(w, "  qos", cmd.usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "使用 qos <命令> -h 查看命令的参数")
+}
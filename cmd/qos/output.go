@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// printer 按输出格式打印记录，每条记录由与列名一一对应的单元格组成
+type printer interface {
+	Print(cells []string) error
+	// Flush 输出缓冲的内容，table和json格式在Flush时才输出
+	Flush() error
+}
+
+// newPrinter 创建printer，stream为true时每条记录立即输出，json格式按ndjson输出
+func newPrinter(format string, w io.Writer, columns []string, stream bool) (printer, error) {
+	switch format {
+	case "table":
+		return newTablePrinter(w, columns, stream), nil
+	case "json":
+		if stream {
+			return &ndjsonPrinter{w: w, columns: columns}, nil
+		}
+		return &jsonPrinter{w: w, columns: columns}, nil
+	case "ndjson":
+		return &ndjsonPrinter{w: w, columns: columns}, nil
+	case "csv":
+		p := &csvPrinter{w: csv.NewWriter(w)}
+		return p, p.Print(columns)
+	}
+	return nil, fmt.Errorf("unknown format %q, expected table, json, ndjson or csv", format)
+}
+
+// tablePrinter 以对齐的文本表格输出
+type tablePrinter struct {
+	w      *tabwriter.Writer
+	stream bool
+}
+
+func newTablePrinter(w io.Writer, columns []string, stream bool) *tablePrinter {
+	p := &tablePrinter{w: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0), stream: stream}
+	fmt.Fprintln(p.w, strings.Join(columns, "\t"))
+	return p
+}
+
+func (p *tablePrinter) Print(cells []string) error {
+	if _, err := fmt.Fprintln(p.w, strings.Join(cells, "\t")); err != nil {
+		return err
+	}
+	if p.stream {
+		return p.w.Flush()
+	}
+	return nil
+}
+
+func (p *tablePrinter) Flush() error {
+	return p.w.Flush()
+}
+
+// record 按列顺序编码为JSON对象
+func record(columns, cells []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, col := range columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(col)
+		v, _ := json.Marshal(cells[i])
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
+
+// jsonPrinter 将所有记录输出为一个JSON数组
+type jsonPrinter struct {
+	w       io.Writer
+	columns []string
+	records []json.RawMessage
+}
+
+func (p *jsonPrinter) Print(cells []string) error {
+	p.records = append(p.records, record(p.columns, cells))
+	return nil
+}
+
+func (p *jsonPrinter) Flush() error {
+	if p.records == nil {
+		p.records = []json.RawMessage{}
+	}
+	data, err := json.MarshalIndent(p.records, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(p.w, "%s\n", data)
+	return err
+}
+
+// ndjsonPrinter 每行输出一条JSON记录
+type ndjsonPrinter struct {
+	w       io.Writer
+	columns []string
+}
+
+func (p *ndjsonPrinter) Print(cells []string) error {
+	_, err := fmt.Fprintf(p.w, "%s\n", record(p.columns, cells))
+	return err
+}
+
+func (p *ndjsonPrinter) Flush() error {
+	return nil
+}
+
+// csvPrinter 以CSV输出，第一行为列名
+type csvPrinter struct {
+	w *csv.Writer
+}
+
+func (p *csvPrinter) Print(cells []string) error {
+	if err := p.w.Write(cells); err != nil {
+		return err
+	}
+	p.w.Flush()
+	return p.w.Error()
+}
+
+func (p *csvPrinter) Flush() error {
+	p.w.Flush()
+	return p.w.Error()
+}
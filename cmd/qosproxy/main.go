@@ -0,0 +1,130 @@
+// qosproxy 是本地行情代理，使用少量上游连接为多个本地服务提供与QOS相同的/ws协议和HTTP接口，
+// 只向上游订阅所有本地客户端订阅的并集。
+//
+// 用法:
+//
+//	QOS_API_KEY=your-api-key qosproxy -listen 127.0.0.1:8090 -upstreams 2 -keys svc-a,svc-b
+//
+// 未设置-keys时不校验本地客户端，此时只能监听回环地址。浏览器发起的WebSocket连接默认只允许同源，
+// 其他来源需要通过-origins指定。
+//
+// 本地客户端将地址指向代理即可：
+//
+//	qosapi.NewWSClient("svc-a", qosapi.WithWSURL("ws://127.0.0.1:8090/ws"))
+//	qosapi.NewClient("svc-a", qosapi.WithBaseURL("http://127.0.0.1:8090"))
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	_ "time/tzdata" // 系统缺少时区数据库时使用内置数据
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+	"github.com/qos-max/qos-quote-api-go-sdk/qosproxy"
+)
+
+func main() {
+	var (
+		listen    = flag.String("listen", "127.0.0.1:8090", "监听地址")
+		key       = flag.String("key", "", "上游API Key，默认读取QOS_API_KEY环境变量")
+		upstreams = flag.Int("upstreams", 1, "上游WebSocket连接数")
+		keys      = flag.String("keys", "", "本地客户端可使用的key，多个用逗号分隔，为空时不校验(只能监听回环地址)")
+		origins   = flag.String("origins", "", "允许建立WebSocket连接的浏览器来源，多个用逗号分隔，默认只允许同源")
+		baseURL   = flag.String("base-url", qosapi.HTTPBaseURL, "上游HTTP接口地址")
+		wsURL     = flag.String("ws-url", qosapi.WSBaseURL, "上游WebSocket地址")
+		stats     = flag.Duration("stats", time.Minute, "打印运行状态的间隔，0表示不打印")
+	)
+	flag.Parse()
+
+	if *key == "" {
+		*key = os.Getenv("QOS_API_KEY")
+	}
+	if *key == "" {
+		fmt.Fprintln(os.Stderr, "qosproxy: API key is required: use -key or QOS_API_KEY")
+		os.Exit(2)
+	}
+
+	logger := log.Default()
+	opts := qosproxy.Options{
+		APIKey:        *key,
+		Upstreams:     *upstreams,
+		ClientOptions: []qosapi.Option{qosapi.WithBaseURL(*baseURL), qosapi.WithWSURL(*wsURL), qosapi.WithLogger(logger)},
+		Logger:        logger,
+	}
+	opts.Keys = splitList(*keys)
+	opts.AllowedOrigins = splitList(*origins)
+	if len(opts.Keys) == 0 && !isLoopback(*listen) {
+		fmt.Fprintf(os.Stderr, "qosproxy: refusing to listen on %s without -keys: only loopback addresses may skip client authentication\n", *listen)
+		os.Exit(2)
+	}
+
+	proxy, err := qosproxy.New(opts)
+	if err != nil {
+		log.Fatalf("qosproxy: connect upstream: %v", err)
+	}
+	server := &http.Server{Addr: *listen, Handler: proxy}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		proxy.Close()
+		server.Shutdown(shutdownCtx)
+	}()
+	if *stats > 0 {
+		go func() {
+			ticker := time.NewTicker(*stats)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					s := proxy.Stats()
+					logger.Printf("qosproxy: %d clients, %d topics, upstream topics %v", s.Clients, s.Topics, s.Upstreams)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	logger.Printf("qosproxy: listening on %s with %d upstream connections", *listen, *upstreams)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("qosproxy: %v", err)
+	}
+}
+
+// splitList 拆分逗号分隔的列表，忽略空项
+func splitList(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// isLoopback 监听地址是否只在回环接口上，主机为空(监听所有接口)或无法解析时返回false
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestIsLoopback(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1:8090", true},
+		{"127.1.2.3:8090", true},
+		{"[::1]:8090", true},
+		{"localhost:8090", true},
+		{":8090", false},
+		{"0.0.0.0:8090", false},
+		{"[::]:8090", false},
+		{"192.168.1.10:8090", false},
+		{"proxy.internal:8090", false},
+		{"127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := isLoopback(tt.addr); got != tt.want {
+			t.Errorf("isLoopback(%q) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
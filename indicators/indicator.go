@@ -0,0 +1,123 @@
+// Package indicators 基于K线序列计算技术指标，包括SMA、EMA、RSI、MACD、布林带、ATR和VWAP。
+//
+// 每个指标既可以用Run批量计算历史K线，也可以在收到实时K线时调用Update增量计算。
+// 与上一根K线时间戳相同的更新视为对未完成K线的修订，会替换而不是追加这根K线。
+// 结果与输入K线的时间戳一一对应：
+//
+//	rsi, err := indicators.Run(indicators.NewRSI(14), klines)
+//
+//	ema := indicators.NewEMA(20)
+//	client.SubscribeKLine(codes, qosapi.KLineTypeMin1, func(k qosapi.WSKLine) {
+//		v, err := indicators.UpdateKLine(ema, k.KLine())
+//	})
+package indicators
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+// Bar 指标计算使用的K线
+type Bar struct {
+	Timestamp int64
+	Open      float64
+	High      float64
+	Low       float64
+	Close     float64
+	Volume    float64
+}
+
+// BarOf 将KLine的价格和数量解析为float64
+func BarOf(k qosapi.KLine) (Bar, error) {
+	b := Bar{Timestamp: k.Timestamp}
+	for _, f := range []struct {
+		name string
+		s    string
+		dst  *float64
+	}{
+		{"Open", k.Open, &b.Open},
+		{"High", k.High, &b.High},
+		{"Low", k.Low, &b.Low},
+		{"Close", k.Close, &b.Close},
+		{"Volume", k.Volume, &b.Volume},
+	} {
+		if f.s == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(f.s, 64)
+		if err != nil {
+			return Bar{}, fmt.Errorf("kline %s at %d: %s: %w", k.Code, k.Timestamp, f.name, err)
+		}
+		*f.dst = v
+	}
+	return b, nil
+}
+
+// Value 单值指标的结果
+type Value struct {
+	Timestamp int64   // 对应K线的时间戳
+	Value     float64 // 指标值
+	Ready     bool    // K线数量是否已足够计算指标，为false时Value无意义
+}
+
+// Indicator 可增量计算的指标
+type Indicator[T any] interface {
+	// Update 加入一根K线并返回这根K线上的指标值。时间戳与上一根相同时替换上一根，
+	// 早于上一根的K线被忽略并返回上一次的结果
+	Update(b Bar) T
+}
+
+// Run 按顺序将K线加入指标，返回与klines一一对应的结果
+func Run[T any](ind Indicator[T], klines []qosapi.KLine) ([]T, error) {
+	result := make([]T, 0, len(klines))
+	for _, k := range klines {
+		v, err := UpdateKLine(ind, k)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+// UpdateKLine 将一根KLine加入指标
+func UpdateKLine[T any](ind Indicator[T], k qosapi.KLine) (T, error) {
+	b, err := BarOf(k)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return ind.Update(b), nil
+}
+
+// series 处理K线修订的通用实现。step根据前一根K线之后的状态和当前K线计算新状态和指标值，
+// 不能修改传入的状态
+type series[S, T any] struct {
+	step func(S, Bar) (S, T)
+
+	prev    S // 当前K线之前的状态
+	cur     S // 包含当前K线的状态
+	last    T
+	ts      int64
+	started bool
+}
+
+func newSeries[S, T any](initial S, step func(S, Bar) (S, T)) series[S, T] {
+	return series[S, T]{step: step, prev: initial}
+}
+
+func (s *series[S, T]) update(b Bar) T {
+	switch {
+	case !s.started:
+		s.started = true
+	case b.Timestamp < s.ts:
+		return s.last
+	case b.Timestamp > s.ts:
+		s.prev = s.cur
+	}
+	s.ts = b.Timestamp
+	s.cur, s.last = s.step(s.prev, b)
+	return s.last
+}
@@ -0,0 +1,194 @@
+package indicators_test
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/indicators"
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+// closes 收盘价依次为closes的K线，高低价为收盘价±1，成交量为1，间隔一分钟
+func closes(closes ...float64) []indicators.Bar {
+	bars := make([]indicators.Bar, len(closes))
+	for i, c := range closes {
+		bars[i] = indicators.Bar{Timestamp: int64(i) * 60, Open: c, High: c + 1, Low: c - 1, Close: c, Volume: 1}
+	}
+	return bars
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestWarmUp(t *testing.T) {
+	bars := closes(1, 2, 3, 4, 5, 6)
+	valueReady := func(v indicators.Value) bool { return v.Ready }
+	tests := []struct {
+		name  string
+		ready []bool // 每根K线上的Ready
+		want  int    // 第一根Ready的K线
+	}{
+		{"SMA(3)", readiness(indicators.NewSMA(3), bars, valueReady), 2},
+		{"EMA(3)", readiness(indicators.NewEMA(3), bars, valueReady), 2},
+		// 需要period个涨跌幅，即period+1根K线
+		{"RSI(3)", readiness(indicators.NewRSI(3), bars, valueReady), 3},
+		// 慢线需要3根，信号线再需要2个MACD值
+		{"MACD(2,3,2)", readiness(indicators.NewMACD(2, 3, 2), bars, func(v indicators.MACDValue) bool { return v.Ready }), 3},
+		{"Bollinger(3,2)", readiness(indicators.NewBollinger(3, 2), bars, func(v indicators.BandsValue) bool { return v.Ready }), 2},
+		{"ATR(2)", readiness(indicators.NewATR(2), bars, valueReady), 1},
+		{"VWAP", readiness(indicators.NewVWAP(nil), bars, valueReady), 0},
+	}
+	for _, tt := range tests {
+		for i, r := range tt.ready {
+			if r != (i >= tt.want) {
+				t.Errorf("%s: Ready at bar %d = %v, want ready from bar %d", tt.name, i, r, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func readiness[T any](ind indicators.Indicator[T], bars []indicators.Bar, ready func(T) bool) []bool {
+	var result []bool
+	for _, b := range bars {
+		result = append(result, ready(ind.Update(b)))
+	}
+	return result
+}
+
+func TestValues(t *testing.T) {
+	bars := closes(1, 2, 3, 4)
+
+	sma := indicators.NewSMA(3)
+	var v indicators.Value
+	for _, b := range bars {
+		v = sma.Update(b)
+	}
+	if !near(v.Value, 3) {
+		t.Errorf("SMA = %v, want 3", v.Value)
+	}
+
+	ema := indicators.NewEMA(3)
+	for _, b := range bars {
+		v = ema.Update(b)
+	}
+	// 初始值为前3根的平均2，alpha=0.5
+	if !near(v.Value, 3) {
+		t.Errorf("EMA = %v, want 3", v.Value)
+	}
+
+	rsi := indicators.NewRSI(2)
+	var rsis []float64
+	for _, b := range closes(1, 2, 1, 2) {
+		rsis = append(rsis, rsi.Update(b).Value)
+	}
+	// 涨跌为+1、-1、+1：初始平均涨跌各0.5，之后涨0.75、跌0.25
+	if !near(rsis[2], 50) || !near(rsis[3], 75) {
+		t.Errorf("RSI = %v, want 50 then 75", rsis)
+	}
+
+	bb := indicators.NewBollinger(3, 2)
+	var bv indicators.BandsValue
+	for _, b := range bars[:3] {
+		bv = bb.Update(b)
+	}
+	sd := math.Sqrt(2.0 / 3)
+	if !near(bv.Middle, 2) || !near(bv.Upper, 2+2*sd) || !near(bv.Lower, 2-2*sd) {
+		t.Errorf("Bollinger = %+v", bv)
+	}
+
+	atr := indicators.NewATR(2)
+	for _, b := range bars {
+		v = atr.Update(b)
+	}
+	// 每根真实波幅为2
+	if !near(v.Value, 2) {
+		t.Errorf("ATR = %v, want 2", v.Value)
+	}
+}
+
+func TestVWAPResetsDaily(t *testing.T) {
+	day := time.Date(2026, 3, 9, 23, 58, 0, 0, time.UTC).Unix()
+	bar := func(ts int64, price, volume float64) indicators.Bar {
+		return indicators.Bar{Timestamp: ts, High: price, Low: price, Close: price, Volume: volume}
+	}
+	vwap := indicators.NewVWAP(time.UTC)
+	vwap.Update(bar(day, 10, 1))
+	if v := vwap.Update(bar(day+60, 20, 3)); !near(v.Value, 17.5) {
+		t.Fatalf("VWAP = %v, want 17.5", v.Value)
+	}
+	if v := vwap.Update(bar(day+120, 30, 1)); !near(v.Value, 30) {
+		t.Fatalf("VWAP after midnight = %v, want 30", v.Value)
+	}
+	if v := indicators.NewVWAP(nil).Update(bar(day, 10, 0)); v.Ready {
+		t.Fatal("VWAP without volume should not be ready")
+	}
+}
+
+// TestRevisingLastBar 修订最后一根K线的结果应与直接传入修订后的K线相同，且不影响后续K线
+func TestRevisingLastBar(t *testing.T) {
+	bars := closes(10, 11, 9, 12, 13, 12, 14, 15)
+	revised := bars[5]
+	revised.Close, revised.High, revised.Volume = 8, 13, 5
+
+	checkRevision(t, "SMA", func() indicators.Indicator[indicators.Value] { return indicators.NewSMA(3) }, bars, revised)
+	checkRevision(t, "EMA", func() indicators.Indicator[indicators.Value] { return indicators.NewEMA(3) }, bars, revised)
+	checkRevision(t, "RSI", func() indicators.Indicator[indicators.Value] { return indicators.NewRSI(3) }, bars, revised)
+	checkRevision(t, "MACD", func() indicators.Indicator[indicators.MACDValue] { return indicators.NewMACD(2, 3, 2) }, bars, revised)
+	checkRevision(t, "Bollinger", func() indicators.Indicator[indicators.BandsValue] { return indicators.NewBollinger(3, 2) }, bars, revised)
+	checkRevision(t, "ATR", func() indicators.Indicator[indicators.Value] { return indicators.NewATR(3) }, bars, revised)
+	checkRevision(t, "VWAP", func() indicators.Indicator[indicators.Value] { return indicators.NewVWAP(time.UTC) }, bars, revised)
+}
+
+func checkRevision[T comparable](t *testing.T, name string, newInd func() indicators.Indicator[T], bars []indicators.Bar, revised indicators.Bar) {
+	t.Helper()
+	i := slices.IndexFunc(bars, func(b indicators.Bar) bool { return b.Timestamp == revised.Timestamp })
+
+	// 直接传入修订后的K线
+	want := newInd()
+	for _, b := range bars[:i] {
+		want.Update(b)
+	}
+	wantAt := want.Update(revised)
+	wantNext := want.Update(bars[i+1])
+
+	// 先传入原K线，再多次修订
+	got := newInd()
+	for _, b := range bars[:i+1] {
+		got.Update(b)
+	}
+	interim := bars[i]
+	interim.Close += 100
+	got.Update(interim)
+	if v := got.Update(revised); v != wantAt {
+		t.Errorf("%s: revised bar = %+v, want %+v", name, v, wantAt)
+	}
+	// 早于最后一根的K线被忽略
+	if v := got.Update(bars[0]); v != wantAt {
+		t.Errorf("%s: stale bar = %+v, want the last result %+v", name, v, wantAt)
+	}
+	if v := got.Update(bars[i+1]); v != wantNext {
+		t.Errorf("%s: bar after revision = %+v, want %+v", name, v, wantNext)
+	}
+}
+
+func TestRunParsesKLines(t *testing.T) {
+	klines := []qosapi.KLine{
+		{Code: "US:AAPL", Timestamp: 0, Close: "1"},
+		{Code: "US:AAPL", Timestamp: 60, Close: "3"},
+	}
+	got, err := indicators.Run(indicators.NewSMA(2), klines)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(got) != 2 || got[0].Ready || !got[1].Ready || !near(got[1].Value, 2) || got[1].Timestamp != 60 {
+		t.Fatalf("Run = %+v", got)
+	}
+	klines = append(klines, qosapi.KLine{Code: "US:AAPL", Timestamp: 120, Close: "abc"})
+	if _, err := indicators.Run(indicators.NewSMA(2), klines); err == nil {
+		t.Fatal("Run with an invalid close succeeded")
+	}
+}
@@ -0,0 +1,82 @@
+package indicators
+
+// window 最近n个值组成的滑动窗口，push返回新窗口，不修改原窗口
+type window struct {
+	vals []float64
+	sum  float64
+}
+
+func (w window) push(x float64, n int) window {
+	vals := make([]float64, 0, n)
+	sum := w.sum + x
+	if len(w.vals) >= n {
+		sum -= w.vals[len(w.vals)-n]
+		vals = append(vals, w.vals[len(w.vals)-n+1:]...)
+	} else {
+		vals = append(vals, w.vals...)
+	}
+	return window{vals: append(vals, x), sum: sum}
+}
+
+func (w window) mean() float64 {
+	return w.sum / float64(len(w.vals))
+}
+
+// SMA 简单移动平均线，对收盘价计算
+type SMA struct {
+	s series[window, Value]
+}
+
+// NewSMA 创建period周期的简单移动平均线
+func NewSMA(period int) *SMA {
+	period = max(period, 1)
+	return &SMA{s: newSeries(window{}, func(w window, b Bar) (window, Value) {
+		w = w.push(b.Close, period)
+		return w, Value{Timestamp: b.Timestamp, Value: w.mean(), Ready: len(w.vals) == period}
+	})}
+}
+
+// Update 实现Indicator
+func (m *SMA) Update(b Bar) Value {
+	return m.s.update(b)
+}
+
+// emaState 指数移动平均的状态，前period个值使用简单平均作为初始值
+type emaState struct {
+	seed  window
+	value float64
+	ready bool
+}
+
+func (s emaState) push(x float64, period int) emaState {
+	if s.ready {
+		alpha := 2 / float64(period+1)
+		s.value += alpha * (x - s.value)
+		return s
+	}
+	s.seed = s.seed.push(x, period)
+	s.value = s.seed.mean()
+	if len(s.seed.vals) == period {
+		s.ready, s.seed = true, window{}
+	}
+	return s
+}
+
+// EMA 指数移动平均线，对收盘价计算，前period根K线的简单平均作为初始值
+type EMA struct {
+	s series[emaState, Value]
+}
+
+// NewEMA 创建period周期的指数移动平均线
+func NewEMA(period int) *EMA {
+	period = max(period, 1)
+	return &EMA{s: newSeries(emaState{}, func(st emaState, b Bar) (emaState, Value) {
+		st = st.push(b.Close, period)
+		return st, Value{Timestamp: b.Timestamp, Value: st.value, Ready: st.ready}
+	})}
+}
+
+// Update 实现Indicator
+func (m *EMA) Update(b Bar) Value {
+	return m.s.update(b)
+}
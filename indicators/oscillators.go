@@ -0,0 +1,107 @@
+package indicators
+
+// wilderState Wilder平滑的状态，前period个值使用简单平均作为初始值
+type wilderState struct {
+	seed  window
+	value float64
+	ready bool
+}
+
+func (s wilderState) push(x float64, period int) wilderState {
+	if s.ready {
+		s.value = (s.value*float64(period-1) + x) / float64(period)
+		return s
+	}
+	s.seed = s.seed.push(x, period)
+	s.value = s.seed.mean()
+	if len(s.seed.vals) == period {
+		s.ready, s.seed = true, window{}
+	}
+	return s
+}
+
+type rsiState struct {
+	prevClose float64
+	started   bool
+	gain      wilderState
+	loss      wilderState
+}
+
+// RSI 相对强弱指数(Wilder平滑)，取值范围[0, 100]
+type RSI struct {
+	s series[rsiState, Value]
+}
+
+// NewRSI 创建period周期的相对强弱指数，通常period为14
+func NewRSI(period int) *RSI {
+	period = max(period, 1)
+	return &RSI{s: newSeries(rsiState{}, func(st rsiState, b Bar) (rsiState, Value) {
+		v := Value{Timestamp: b.Timestamp}
+		if !st.started {
+			st.started, st.prevClose = true, b.Close
+			return st, v
+		}
+		change := b.Close - st.prevClose
+		st.prevClose = b.Close
+		st.gain = st.gain.push(max(change, 0), period)
+		st.loss = st.loss.push(max(-change, 0), period)
+
+		v.Ready = st.gain.ready
+		switch {
+		case st.loss.value == 0 && st.gain.value == 0:
+			v.Value = 50
+		case st.loss.value == 0:
+			v.Value = 100
+		default:
+			v.Value = 100 - 100/(1+st.gain.value/st.loss.value)
+		}
+		return st, v
+	})}
+}
+
+// Update 实现Indicator
+func (r *RSI) Update(b Bar) Value {
+	return r.s.update(b)
+}
+
+// MACDValue MACD指标的结果
+type MACDValue struct {
+	Timestamp int64
+	MACD      float64 // 快线EMA - 慢线EMA
+	Signal    float64 // MACD的EMA
+	Histogram float64 // MACD - Signal
+	Ready     bool
+}
+
+type macdState struct {
+	fast, slow, signal emaState
+}
+
+// MACD 指数平滑异同移动平均线
+type MACD struct {
+	s series[macdState, MACDValue]
+}
+
+// NewMACD 创建MACD指标，通常参数为12、26、9
+func NewMACD(fast, slow, signal int) *MACD {
+	fast, slow, signal = max(fast, 1), max(slow, 1), max(signal, 1)
+	return &MACD{s: newSeries(macdState{}, func(st macdState, b Bar) (macdState, MACDValue) {
+		st.fast = st.fast.push(b.Close, fast)
+		st.slow = st.slow.push(b.Close, slow)
+		v := MACDValue{Timestamp: b.Timestamp}
+		if !st.fast.ready || !st.slow.ready {
+			return st, v
+		}
+		v.MACD = st.fast.value - st.slow.value
+		st.signal = st.signal.push(v.MACD, signal)
+		v.Signal = st.signal.value
+		v.Histogram = v.MACD - v.Signal
+		v.Ready = st.signal.ready
+		return st, v
+	})}
+}
+
+// Update 实现Indicator
+func (m *MACD) Update(b Bar) MACDValue {
+	return m.s.update(b)
+}
@@ -0,0 +1,73 @@
+package indicators
+
+import "math"
+
+// BandsValue 布林带的结果
+type BandsValue struct {
+	Timestamp int64
+	Middle    float64 // 中轨，收盘价的简单移动平均
+	Upper     float64 // 上轨，中轨 + k * 标准差
+	Lower     float64 // 下轨，中轨 - k * 标准差
+	Ready     bool
+}
+
+// Bollinger 布林带，标准差为总体标准差
+type Bollinger struct {
+	s series[window, BandsValue]
+}
+
+// NewBollinger 创建period周期、k倍标准差的布林带，通常参数为20、2
+func NewBollinger(period int, k float64) *Bollinger {
+	period = max(period, 1)
+	return &Bollinger{s: newSeries(window{}, func(w window, b Bar) (window, BandsValue) {
+		w = w.push(b.Close, period)
+		mean := w.mean()
+		variance := 0.0
+		for _, x := range w.vals {
+			variance += (x - mean) * (x - mean)
+		}
+		sd := math.Sqrt(variance / float64(len(w.vals)))
+		return w, BandsValue{
+			Timestamp: b.Timestamp,
+			Middle:    mean,
+			Upper:     mean + k*sd,
+			Lower:     mean - k*sd,
+			Ready:     len(w.vals) == period,
+		}
+	})}
+}
+
+// Update 实现Indicator
+func (bb *Bollinger) Update(b Bar) BandsValue {
+	return bb.s.update(b)
+}
+
+type atrState struct {
+	prevClose float64
+	started   bool
+	tr        wilderState
+}
+
+// ATR 平均真实波幅(Wilder平滑)
+type ATR struct {
+	s series[atrState, Value]
+}
+
+// NewATR 创建period周期的平均真实波幅，通常period为14
+func NewATR(period int) *ATR {
+	period = max(period, 1)
+	return &ATR{s: newSeries(atrState{}, func(st atrState, b Bar) (atrState, Value) {
+		tr := b.High - b.Low
+		if st.started {
+			tr = max(tr, math.Abs(b.High-st.prevClose), math.Abs(b.Low-st.prevClose))
+		}
+		st.started, st.prevClose = true, b.Close
+		st.tr = st.tr.push(tr, period)
+		return st, Value{Timestamp: b.Timestamp, Value: st.tr.value, Ready: st.tr.ready}
+	})}
+}
+
+// Update 实现Indicator
+func (a *ATR) Update(b Bar) Value {
+	return a.s.update(b)
+}
@@ -0,0 +1,40 @@
+package indicators
+
+import "time"
+
+type vwapState struct {
+	day    int64 // 当前累计周期所在的日期(距1970-01-01的天数)
+	pv     float64
+	volume float64
+}
+
+// VWAP 成交量加权平均价，使用典型价格(最高价+最低价+收盘价)/3，按日重新累计
+type VWAP struct {
+	s series[vwapState, Value]
+}
+
+// NewVWAP 创建VWAP指标，loc为划分交易日使用的时区，通常为qosapi.MarketLocation(market)，
+// 为nil时不按日重新累计
+func NewVWAP(loc *time.Location) *VWAP {
+	return &VWAP{s: newSeries(vwapState{day: -1}, func(st vwapState, b Bar) (vwapState, Value) {
+		if loc != nil {
+			y, m, d := time.Unix(b.Timestamp, 0).In(loc).Date()
+			day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400
+			if day != st.day {
+				st = vwapState{day: day}
+			}
+		}
+		st.pv += (b.High + b.Low + b.Close) / 3 * b.Volume
+		st.volume += b.Volume
+		v := Value{Timestamp: b.Timestamp}
+		if st.volume > 0 {
+			v.Value, v.Ready = st.pv/st.volume, true
+		}
+		return st, v
+	})}
+}
+
+// Update 实现Indicator
+func (v *VWAP) Update(b Bar) Value {
+	return v.s.update(b)
+}
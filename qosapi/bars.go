@@ -0,0 +1,325 @@
+package qosapi
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BarKind K线的划分方式
+type BarKind int
+
+const (
+	BarTime     BarKind = iota // 按时间周期
+	BarTick                    // 按成交笔数
+	BarVolume                  // 按成交量
+	BarTurnover                // 按成交金额(价格*数量)
+)
+
+// BarSpec K线的划分规则
+type BarSpec struct {
+	Kind BarKind
+
+	// Interval 时间K线的周期，按当地时钟和日期划分，不受夏令时切换影响。
+	// 小于一天的周期须为整数秒且能整除24小时：Location为nil时，代码所在市场常规交易时段内的K线
+	// 从时段开始时间对齐并在时段结束时截断，如港股60分钟K线为09:30、10:30、11:30(半小时)、13:00...；
+	// 时段外(或指定了Location时)从当地零点对齐，并在下一个时段开始时截断，K线不会跨越零点。
+	// 大于等于一天的周期须为整数天，按当地日期对齐，从周一开始，如7天的K线为周一到周日
+	Interval time.Duration
+
+	// Threshold 笔数、成交量或成交金额K线的阈值，累计值达到阈值时K线结束
+	Threshold Decimal
+
+	// Location 时间K线对齐使用的时区，为nil时使用代码所在市场的时区
+	Location *time.Location
+
+	// KLineType 写入生成K线的KLineType字段
+	KLineType int
+
+	// Calendar 不为nil时忽略不在常规交易时段内的成交(如美股盘前盘后和午休期间的成交)
+	Calendar *Calendar
+}
+
+// TimeBars 按时间周期划分K线，如TimeBars(3*time.Minute)
+func TimeBars(interval time.Duration) BarSpec {
+	return BarSpec{Kind: BarTime, Interval: interval}
+}
+
+// TickBars 每n笔成交生成一根K线
+func TickBars(n int) BarSpec {
+	return BarSpec{Kind: BarTick, Threshold: NewDecimalFromInt(int64(n))}
+}
+
+// VolumeBars 成交量每达到volume生成一根K线
+func VolumeBars(volume Decimal) BarSpec {
+	return BarSpec{Kind: BarVolume, Threshold: volume}
+}
+
+// TurnoverBars 成交金额每达到turnover生成一根K线
+func TurnoverBars(turnover Decimal) BarSpec {
+	return BarSpec{Kind: BarTurnover, Threshold: turnover}
+}
+
+// validate 校验划分规则
+func (s BarSpec) validate() error {
+	switch s.Kind {
+	case BarTime:
+		if s.Interval <= 0 {
+			return fmt.Errorf("bar interval must be positive, got %v", s.Interval)
+		}
+		if s.Interval >= 24*time.Hour && s.Interval%(24*time.Hour) != 0 {
+			return fmt.Errorf("bar interval of a day or longer must be whole days, got %v", s.Interval)
+		}
+		if s.Interval < 24*time.Hour && (s.Interval%time.Second != 0 || (24*time.Hour)%s.Interval != 0) {
+			return fmt.Errorf("bar interval shorter than a day must be whole seconds dividing 24h, got %v", s.Interval)
+		}
+	case BarTick, BarVolume, BarTurnover:
+		if !s.Threshold.IsPositive() {
+			return fmt.Errorf("bar threshold must be positive, got %s", s.Threshold)
+		}
+	default:
+		return fmt.Errorf("unknown bar kind %d", s.Kind)
+	}
+	return nil
+}
+
+// bucket 返回时间戳ts(秒)所在时间K线的开始和结束时间，sessions为当地交易时段，按时间顺序排列
+func (s BarSpec) bucket(ts int64, loc *time.Location, sessions []Session) (start, end int64) {
+	t := time.Unix(ts, 0).In(loc)
+	y, m, d := t.Date()
+	if s.Interval >= 24*time.Hour {
+		// 从1970-01-05(周一)起按当地日期计数
+		days := int(s.Interval / (24 * time.Hour))
+		dayNum := int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix()/86400) - 4
+		dayNum -= ((dayNum % days) + days) % days
+		return localTime(1970, 1, 5+dayNum, 0, loc).Unix(), localTime(1970, 1, 5+dayNum+days, 0, loc).Unix()
+	}
+
+	// 按当地时钟计算：在交易时段内从时段开始对齐并截断到时段结束，
+	// 时段外从零点对齐，不早于上一个时段的结束，并截断到下一个时段开始
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	origin, floor, limit := time.Duration(0), time.Duration(0), 24*time.Hour
+	for _, sess := range sessions {
+		if clock < sess.Start {
+			limit = sess.Start
+			break
+		}
+		if clock < sess.End {
+			origin, limit = sess.Start, sess.End
+			break
+		}
+		floor = sess.End
+	}
+	grid := origin + (clock - origin).Truncate(s.Interval)
+	begin, finish := max(grid, floor), min(grid+s.Interval, limit)
+	return localTime(y, m, d, begin, loc).Unix(), localTime(y, m, d, finish, loc).Unix()
+}
+
+// localTime 返回当地日期的clock时刻。该时刻因夏令时开始而不存在时，返回跳过的时间段之后的第一个时刻
+func localTime(y int, m time.Month, d int, clock time.Duration, loc *time.Location) time.Time {
+	t := time.Date(y, m, d, 0, 0, int(clock/time.Second), 0, loc)
+	want := time.Date(y, m, d, 0, 0, int(clock/time.Second), 0, time.UTC)
+	got := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return t.Add(want.Sub(got))
+}
+
+// barState 正在生成的K线
+type barState struct {
+	bar      KLineDecimal
+	end      int64   // 时间K线的结束时间，为0表示没有未完成的K线
+	closed   int64   // 已结束的时间K线的结束时间，早于它的成交被忽略
+	count    Decimal // 累计笔数、成交量或成交金额
+	loc      *time.Location
+	sessions []Session // 时间K线对齐使用的交易时段
+}
+
+// BarBuilder 由逐笔成交生成K线，每个代码分别生成。K线结束时调用onBar，
+// onBar在调用Add*或Advance的goroutine中执行，执行时不持有锁
+type BarBuilder struct {
+	spec  BarSpec
+	onBar func(KLine)
+
+	mu   sync.Mutex
+	bars map[string]*barState
+}
+
+// NewBarBuilder 创建K线生成器
+func NewBarBuilder(spec BarSpec, onBar func(KLine)) (*BarBuilder, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return &BarBuilder{
+		spec:  spec,
+		onBar: onBar,
+		bars:  make(map[string]*barState),
+	}, nil
+}
+
+// AddTrade 加入一笔HTTP接口返回的逐笔成交
+func (b *BarBuilder) AddTrade(t Trade) error {
+	dec, err := t.Decimal()
+	if err != nil {
+		return err
+	}
+	b.emit(b.add(dec))
+	return nil
+}
+
+// AddWSTrade 加入一笔WebSocket推送的逐笔成交
+func (b *BarBuilder) AddWSTrade(t WSTrade) error {
+	dec, err := t.Decimal()
+	if err != nil {
+		return err
+	}
+	b.emit(b.add(dec))
+	return nil
+}
+
+// add 加入一笔成交，返回因此结束的K线。时间早于当前时间K线的成交和交易时段外的成交被忽略
+func (b *BarBuilder) add(t TradeDecimal) []KLine {
+	if b.spec.Calendar != nil && !b.spec.Calendar.IsOpen(time.Unix(t.Timestamp, 0)) {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var done []KLine
+	code := canonicalCode(t.Code)
+	st := b.bars[code]
+
+	if b.spec.Kind == BarTime {
+		if st == nil {
+			st = &barState{loc: b.spec.Location}
+			if st.loc == nil {
+				st.loc = codeLocation(code)
+				if sym, err := ParseSymbol(code); err == nil {
+					st.sessions = sym.Sessions()
+				}
+			}
+			b.bars[code] = st
+		} else if t.Timestamp < st.closed || (st.end > 0 && t.Timestamp < st.bar.Timestamp) {
+			return nil
+		}
+		if st.end > 0 && t.Timestamp >= st.end {
+			done = append(done, st.close())
+		}
+		if st.end == 0 {
+			start, end := b.spec.bucket(t.Timestamp, st.loc, st.sessions)
+			st.bar = b.newBar(code, t, start)
+			st.end = end
+			return done
+		}
+		st.update(t)
+		return done
+	}
+
+	if st == nil {
+		st = &barState{bar: b.newBar(code, t, t.Timestamp), count: DecimalZero}
+		b.bars[code] = st
+	} else {
+		st.update(t)
+	}
+	switch b.spec.Kind {
+	case BarTick:
+		st.count = st.count.Add(DecimalOne)
+	case BarVolume:
+		st.count = st.count.Add(t.Volume)
+	case BarTurnover:
+		st.count = st.count.Add(t.Price.Mul(t.Volume))
+	}
+	if st.count.Cmp(b.spec.Threshold) >= 0 {
+		done = append(done, st.bar.KLine())
+		delete(b.bars, code)
+	}
+	return done
+}
+
+func (b *BarBuilder) newBar(code string, t TradeDecimal, start int64) KLineDecimal {
+	return KLineDecimal{
+		Code:      code,
+		Open:      t.Price,
+		Close:     t.Price,
+		High:      t.Price,
+		Low:       t.Price,
+		Volume:    t.Volume,
+		Timestamp: start,
+		KLineType: b.spec.KLineType,
+	}
+}
+
+// close 结束时间K线
+func (st *barState) close() KLine {
+	st.closed, st.end = st.end, 0
+	return st.bar.KLine()
+}
+
+func (st *barState) update(t TradeDecimal) {
+	st.bar.Close = t.Price
+	st.bar.High = MaxDecimal(st.bar.High, t.Price)
+	st.bar.Low = MinDecimal(st.bar.Low, t.Price)
+	st.bar.Volume = st.bar.Volume.Add(t.Volume)
+}
+
+// Advance 结束所有在now之前(含)到期的时间K线。没有新成交时K线不会自动结束，
+// 可以用定时器调用Advance及时产出K线
+func (b *BarBuilder) Advance(now time.Time) {
+	if b.spec.Kind != BarTime {
+		return
+	}
+	b.mu.Lock()
+	var done []KLine
+	for _, st := range b.bars {
+		if st.end > 0 && now.Unix() >= st.end {
+			done = append(done, st.close())
+		}
+	}
+	b.mu.Unlock()
+	b.emit(done)
+}
+
+// Flush 结束所有未完成的K线
+func (b *BarBuilder) Flush() {
+	b.mu.Lock()
+	var done []KLine
+	for code, st := range b.bars {
+		if b.spec.Kind == BarTime && st.end == 0 {
+			continue
+		}
+		done = append(done, st.bar.KLine())
+		delete(b.bars, code)
+	}
+	b.mu.Unlock()
+	b.emit(done)
+}
+
+// Current 返回代码当前未完成的K线
+func (b *BarBuilder) Current(code string) (KLine, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st, ok := b.bars[canonicalCode(code)]
+	if !ok || (b.spec.Kind == BarTime && st.end == 0) {
+		return KLine{}, false
+	}
+	return st.bar.KLine(), true
+}
+
+func (b *BarBuilder) emit(bars []KLine) {
+	if b.onBar == nil {
+		return
+	}
+	for _, bar := range bars {
+		b.onBar(bar)
+	}
+}
+
+// Subscribe 通过行情来源(WSClient或Replayer)订阅逐笔成交并生成K线，无法解析的推送会被记录到日志并忽略
+func (b *BarBuilder) Subscribe(src MarketDataSource, codes []string) (*Subscription, error) {
+	logger := sourceLogger(src)
+	return src.OnTrade(codes, func(t WSTrade) {
+		if err := b.AddWSTrade(t); err != nil {
+			logger.Printf("Failed to add trade for %s: %v", t.Code, err)
+		}
+	})
+}
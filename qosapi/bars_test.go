@@ -0,0 +1,256 @@
+package qosapi_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+func TestBarSpecBucket(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	hk, err := time.LoadLocation("Asia/Hong_Kong")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	at := func(loc *time.Location, y int, m time.Month, d, hh, mm int) time.Time {
+		return time.Date(y, m, d, hh, mm, 0, 0, loc)
+	}
+	us, hkSessions := qosapi.MarketSessions(qosapi.MarketUS), qosapi.MarketSessions(qosapi.MarketHK)
+
+	tests := []struct {
+		name       string
+		interval   time.Duration
+		loc        *time.Location
+		sessions   []qosapi.Session
+		t          time.Time
+		start, end time.Time
+	}{
+		{"5m in session", 5 * time.Minute, ny, us, at(ny, 2026, 3, 9, 10, 7), at(ny, 2026, 3, 9, 10, 5), at(ny, 2026, 3, 9, 10, 10)},
+		{"60m from session open", time.Hour, ny, us, at(ny, 2026, 3, 9, 10, 0), at(ny, 2026, 3, 9, 9, 30), at(ny, 2026, 3, 9, 10, 30)},
+		{"60m cut at session close", time.Hour, ny, us, at(ny, 2026, 3, 9, 15, 45), at(ny, 2026, 3, 9, 15, 30), at(ny, 2026, 3, 9, 16, 0)},
+		{"60m pre-market cut at open", time.Hour, ny, us, at(ny, 2026, 3, 9, 9, 10), at(ny, 2026, 3, 9, 9, 0), at(ny, 2026, 3, 9, 9, 30)},
+		{"90m after hours starts at close", 90 * time.Minute, ny, us, at(ny, 2026, 3, 9, 16, 10), at(ny, 2026, 3, 9, 16, 0), at(ny, 2026, 3, 9, 16, 30)},
+		{"90m after hours on grid", 90 * time.Minute, ny, us, at(ny, 2026, 3, 9, 17, 40), at(ny, 2026, 3, 9, 16, 30), at(ny, 2026, 3, 9, 18, 0)},
+		{"HK 60m before lunch", time.Hour, hk, hkSessions, at(hk, 2026, 3, 9, 11, 45), at(hk, 2026, 3, 9, 11, 30), at(hk, 2026, 3, 9, 12, 0)},
+		{"HK lunch break", time.Hour, hk, hkSessions, at(hk, 2026, 3, 9, 12, 30), at(hk, 2026, 3, 9, 12, 0), at(hk, 2026, 3, 9, 13, 0)},
+		{"HK 60m after lunch", time.Hour, hk, hkSessions, at(hk, 2026, 3, 9, 13, 20), at(hk, 2026, 3, 9, 13, 0), at(hk, 2026, 3, 9, 14, 0)},
+		// 2026-03-08 02:00 美东时间跳到03:00
+		{"DST day 4h on wall clock", 4 * time.Hour, ny, nil, at(ny, 2026, 3, 8, 10, 0), at(ny, 2026, 3, 8, 8, 0), at(ny, 2026, 3, 8, 12, 0)},
+		{"DST day hour before the gap", time.Hour, ny, nil, at(ny, 2026, 3, 8, 1, 30), at(ny, 2026, 3, 8, 1, 0), at(ny, 2026, 3, 8, 3, 0)},
+		{"DST day bucket starting in the gap", 2 * time.Hour, ny, nil, at(ny, 2026, 3, 8, 3, 30), at(ny, 2026, 3, 8, 3, 0), at(ny, 2026, 3, 8, 4, 0)},
+		{"DST day hour after the gap", time.Hour, ny, nil, at(ny, 2026, 3, 8, 3, 30), at(ny, 2026, 3, 8, 3, 0), at(ny, 2026, 3, 8, 4, 0)},
+		{"DST day daily", 24 * time.Hour, ny, nil, at(ny, 2026, 3, 8, 10, 0), at(ny, 2026, 3, 8, 0, 0), at(ny, 2026, 3, 9, 0, 0)},
+		{"weekly starts on Monday", 7 * 24 * time.Hour, ny, nil, at(ny, 2026, 3, 12, 10, 0), at(ny, 2026, 3, 9, 0, 0), at(ny, 2026, 3, 16, 0, 0)},
+		{"weekly across DST", 7 * 24 * time.Hour, ny, nil, at(ny, 2026, 3, 8, 23, 0), at(ny, 2026, 3, 2, 0, 0), at(ny, 2026, 3, 9, 0, 0)},
+		{"weekly before 1970-01-05", 7 * 24 * time.Hour, time.UTC, nil, at(time.UTC, 1970, 1, 1, 0, 0), at(time.UTC, 1969, 12, 29, 0, 0), at(time.UTC, 1970, 1, 5, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := qosapi.TimeBars(tt.interval)
+			start, end := spec.Bucket(tt.t.Unix(), tt.loc, tt.sessions)
+			if start != tt.start.Unix() || end != tt.end.Unix() {
+				t.Fatalf("bucket(%v) = [%v, %v), want [%v, %v)", tt.t,
+					time.Unix(start, 0).In(tt.loc), time.Unix(end, 0).In(tt.loc), tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestTimeBarsIntervalValidation(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		ok       bool
+	}{
+		{90 * time.Second, true},
+		{time.Hour, true},
+		{7 * 24 * time.Hour, true},
+		{7 * time.Minute, false},
+		{1500 * time.Millisecond, false},
+		{36 * time.Hour, false},
+		{0, false},
+	}
+	for _, tt := range tests {
+		_, err := qosapi.NewBarBuilder(qosapi.TimeBars(tt.interval), nil)
+		if (err == nil) != tt.ok {
+			t.Errorf("NewBarBuilder(TimeBars(%v)) error = %v, want ok=%v", tt.interval, err, tt.ok)
+		}
+	}
+}
+
+// collectBars 返回记录生成K线的回调和读取结果的函数
+func collectBars() (func(qosapi.KLine), func() []qosapi.KLine) {
+	var bars []qosapi.KLine
+	return func(k qosapi.KLine) { bars = append(bars, k) }, func() []qosapi.KLine { return bars }
+}
+
+func trade(code string, ts int64, price, volume string) qosapi.Trade {
+	return qosapi.Trade{Code: code, Price: price, Volume: volume, Timestamp: ts}
+}
+
+// ohlcv 将K线格式化为"时间戳 开 高 低 收 量"
+func ohlcv(k qosapi.KLine) string {
+	return fmt.Sprintf("%d %s %s %s %s %s", k.Timestamp, k.Open, k.High, k.Low, k.Close, k.Volume)
+}
+
+func TestBarBuilderTimeBars(t *testing.T) {
+	spec := qosapi.TimeBars(time.Minute)
+	spec.Location = time.UTC
+	spec.KLineType = qosapi.KLineTypeMin1
+	onBar, bars := collectBars()
+	b, err := qosapi.NewBarBuilder(spec, onBar)
+	if err != nil {
+		t.Fatalf("NewBarBuilder: %v", err)
+	}
+
+	const base = 1700000040 // 整分钟
+	for _, tr := range []qosapi.Trade{
+		trade("US:AAPL", base, "10", "1"),
+		trade("US:AAPL", base+30, "12", "2"),
+		trade("US:AAPL", base+59, "9", "1"),
+		trade("US:AAPL", base+60, "11", "3"),
+		trade("US:AAPL", base+10, "100", "1"), // 早于当前K线，忽略
+		trade("US:AAPL", base+185, "13", "1"), // 跳过一分钟没有成交
+	} {
+		if err := b.AddTrade(tr); err != nil {
+			t.Fatalf("AddTrade: %v", err)
+		}
+	}
+	if cur, ok := b.Current("us:aapl"); !ok || ohlcv(cur) != fmt.Sprintf("%d 13 13 13 13 1", base+180) {
+		t.Fatalf("Current = %s, %v", ohlcv(cur), ok)
+	}
+	b.Advance(time.Unix(base+239, 0))
+	if n := len(bars()); n != 2 {
+		t.Fatalf("Advance before the bar ends emitted %d bars, want 2", n)
+	}
+	b.Advance(time.Unix(base+240, 0))
+	if _, ok := b.Current("US:AAPL"); ok {
+		t.Fatal("Current after Advance should have no open bar")
+	}
+	// 已结束K线内的成交被忽略
+	b.AddTrade(trade("US:AAPL", base+200, "50", "1"))
+
+	want := []string{
+		fmt.Sprintf("%d 10 12 9 9 4", base),
+		fmt.Sprintf("%d 11 11 11 11 3", base+60),
+		fmt.Sprintf("%d 13 13 13 13 1", base+180),
+	}
+	got := bars()
+	if len(got) != len(want) {
+		t.Fatalf("got %d bars, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if ohlcv(got[i]) != want[i] || got[i].KLineType != qosapi.KLineTypeMin1 || got[i].Code != "US:AAPL" {
+			t.Errorf("bar %d = %s %s type %d, want %s", i, got[i].Code, ohlcv(got[i]), got[i].KLineType, want[i])
+		}
+	}
+}
+
+func TestBarBuilderThresholdBars(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   qosapi.BarSpec
+		trades [][2]string // 价格、数量
+		want   []string    // Flush之前产生的K线
+		rest   string      // Flush产生的K线
+	}{
+		{
+			name:   "ticks",
+			spec:   qosapi.TickBars(2),
+			trades: [][2]string{{"10", "1"}, {"11", "1"}, {"12", "1"}, {"9", "1"}, {"8", "5"}},
+			want:   []string{"0 10 11 10 11 2", "2 12 12 9 9 2"},
+			rest:   "4 8 8 8 8 5",
+		},
+		{
+			name:   "volume",
+			spec:   qosapi.VolumeBars(qosapi.NewDecimalFromInt(5)),
+			trades: [][2]string{{"10", "2"}, {"11", "2"}, {"12", "2"}, {"13", "7"}, {"14", "1"}},
+			want:   []string{"0 10 12 10 12 6", "3 13 13 13 13 7"},
+			rest:   "4 14 14 14 14 1",
+		},
+		{
+			name:   "turnover",
+			spec:   qosapi.TurnoverBars(qosapi.NewDecimalFromInt(100)),
+			trades: [][2]string{{"10", "5"}, {"10", "4.9"}, {"10", "0.1"}, {"20", "1"}},
+			want:   []string{"0 10 10 10 10 10.0"},
+			rest:   "3 20 20 20 20 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			onBar, bars := collectBars()
+			b, err := qosapi.NewBarBuilder(tt.spec, onBar)
+			if err != nil {
+				t.Fatalf("NewBarBuilder: %v", err)
+			}
+			for i, tr := range tt.trades {
+				if err := b.AddTrade(trade("US:AAPL", int64(i), tr[0], tr[1])); err != nil {
+					t.Fatalf("AddTrade: %v", err)
+				}
+			}
+			got := bars()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d bars, want %d", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if ohlcv(got[i]) != tt.want[i] {
+					t.Errorf("bar %d = %s, want %s", i, ohlcv(got[i]), tt.want[i])
+				}
+			}
+			b.Flush()
+			if got := bars(); len(got) != len(tt.want)+1 || ohlcv(got[len(got)-1]) != tt.rest {
+				t.Fatalf("Flush emitted %v, want %s", got[len(tt.want):], tt.rest)
+			}
+		})
+	}
+}
+
+func TestBarBuilderSeparatesCodes(t *testing.T) {
+	onBar, bars := collectBars()
+	b, err := qosapi.NewBarBuilder(qosapi.TickBars(2), onBar)
+	if err != nil {
+		t.Fatalf("NewBarBuilder: %v", err)
+	}
+	b.AddTrade(trade("US:AAPL", 1, "10", "1"))
+	b.AddTrade(trade("HK:700", 2, "300", "1"))
+	b.AddTrade(trade("us:aapl", 3, "11", "1"))
+	if got := bars(); len(got) != 1 || got[0].Code != "US:AAPL" || ohlcv(got[0]) != "1 10 11 10 11 2" {
+		t.Fatalf("bars = %v, want one US:AAPL bar", got)
+	}
+	if cur, ok := b.Current("HK:00700"); !ok || cur.Open != "300" {
+		t.Fatalf("Current(HK:00700) = %v, %v", cur, ok)
+	}
+}
+
+func TestBarBuilderCalendarSkipsClosedSessions(t *testing.T) {
+	cal, err := qosapi.NewCalendar(qosapi.MarketUS)
+	if err != nil {
+		t.Fatalf("NewCalendar: %v", err)
+	}
+	ny := cal.Location()
+	if ny == time.UTC {
+		t.Skip("time zone data unavailable")
+	}
+	spec := qosapi.TickBars(1)
+	spec.Calendar = cal
+	onBar, bars := collectBars()
+	b, err := qosapi.NewBarBuilder(spec, onBar)
+	if err != nil {
+		t.Fatalf("NewBarBuilder: %v", err)
+	}
+	for _, ts := range []time.Time{
+		time.Date(2026, 3, 9, 8, 0, 0, 0, ny),   // 盘前
+		time.Date(2026, 3, 9, 10, 0, 0, 0, ny),  // 盘中
+		time.Date(2026, 3, 9, 17, 0, 0, 0, ny),  // 盘后
+		time.Date(2026, 3, 14, 10, 0, 0, 0, ny), // 周六
+	} {
+		b.AddTrade(trade("US:AAPL", ts.Unix(), "10", "1"))
+	}
+	if got := bars(); len(got) != 1 || got[0].Timestamp != time.Date(2026, 3, 9, 10, 0, 0, 0, ny).Unix() {
+		t.Fatalf("bars = %v, want only the regular-session trade", got)
+	}
+}
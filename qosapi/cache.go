@@ -0,0 +1,711 @@
+package qosapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheKey K线缓存的键
+type CacheKey struct {
+	Code      string // 股票代码，只能是单个代码
+	KLineType int    // K线类型
+	Adjust    int    // 复权类型 0:不复权 1:前复权
+}
+
+// normalize 规范化代码
+func (k CacheKey) normalize() (CacheKey, error) {
+	s, err := ParseSymbol(k.Code)
+	if err != nil {
+		return CacheKey{}, err
+	}
+	k.Code = s.String()
+	return k, nil
+}
+
+// CacheEntry 一个键下缓存的K线。[Start, End]范围内的K线已全部缓存，
+// Start为0表示已缓存到最早的数据
+type CacheEntry struct {
+	Start  int64   `json:"start"`  // 已缓存范围的起始时间戳(含)
+	End    int64   `json:"end"`    // 已缓存范围的结束时间戳(含)
+	KLines []KLine `json:"klines"` // 按时间从旧到新排列
+}
+
+// KLineStore K线缓存的存储接口，实现需要支持并发调用
+type KLineStore interface {
+	// Load 读取缓存，不存在时返回nil, nil
+	Load(key CacheKey) (*CacheEntry, error)
+	// Save 保存缓存，替换已有数据
+	Save(key CacheKey, entry *CacheEntry) error
+	// Delete 删除缓存，不存在时不返回错误
+	Delete(key CacheKey) error
+	// Keys 返回所有已缓存的键
+	Keys() ([]CacheKey, error)
+}
+
+// MemoryStore 保存在内存中的K线缓存
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[CacheKey]*CacheEntry
+}
+
+// NewMemoryStore 创建内存缓存
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[CacheKey]*CacheEntry)}
+}
+
+// Load 实现KLineStore
+func (s *MemoryStore) Load(key CacheKey) (*CacheEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	return &CacheEntry{Start: entry.Start, End: entry.End, KLines: slices.Clone(entry.KLines)}, nil
+}
+
+// Save 实现KLineStore
+func (s *MemoryStore) Save(key CacheKey, entry *CacheEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = &CacheEntry{Start: entry.Start, End: entry.End, KLines: slices.Clone(entry.KLines)}
+	return nil
+}
+
+// Delete 实现KLineStore
+func (s *MemoryStore) Delete(key CacheKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// Keys 实现KLineStore
+func (s *MemoryStore) Keys() ([]CacheKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]CacheKey, 0, len(s.entries))
+	for key := range s.entries {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// KLineAppender KLineStore的可选接口，实现后KLineCache只写入新获取的K线，不再重写整个缓存
+type KLineAppender interface {
+	// Append 将klines合并到已有缓存(时间戳相同的K线被替换)，并将缓存范围更新为[start, end]，
+	// 缓存不存在时创建
+	Append(key CacheKey, start, end int64, klines []KLine) error
+}
+
+// fileStoreMaxRecords 缓存文件中的记录数超过该值时，在下次Load时合并为一条记录
+const fileStoreMaxRecords = 32
+
+// FileStore 保存在磁盘上的K线缓存，每个键对应一个文件：
+// <dir>/<市场>/<代码>/<K线类型>_<复权类型>.jsonl。
+// 文件每行是一条CacheEntry记录，Save写入一条完整记录，Append只追加新获取的K线，
+// 读取时按顺序合并所有记录，记录过多时自动合并。
+// 市场或代码为空、为"."或".."、或包含路径分隔符的键会被拒绝，不会访问dir之外的文件
+type FileStore struct {
+	dir string
+	mu  sync.Mutex // 串行化对文件的写入
+}
+
+// NewFileStore 创建磁盘缓存，目录不存在时自动创建
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path 返回键对应的文件路径，市场和代码必须是安全的单级路径
+func (s *FileStore) path(key CacheKey) (string, error) {
+	market, ticker, _ := strings.Cut(key.Code, ":")
+	if !isPathSegment(market) || !isPathSegment(ticker) {
+		return "", fmt.Errorf("%w %q: not usable as a cache path", ErrInvalidCode, key.Code)
+	}
+	return filepath.Join(s.dir, market, ticker, fmt.Sprintf("%d_%d.jsonl", key.KLineType, key.Adjust)), nil
+}
+
+// isPathSegment 是否为非空、不含路径分隔符且不是"."或".."的单级路径
+func isPathSegment(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, `/\:`+"\x00")
+}
+
+// Load 实现KLineStore
+func (s *FileStore) Load(key CacheKey) (*CacheEntry, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	size := int64(len(data))
+
+	var entry CacheEntry
+	var series [][]KLine
+	records := 0
+	for len(data) > 0 {
+		line, rest, ok := bytes.Cut(data, []byte("\n"))
+		if !ok {
+			// 追加时被中断的最后一行，忽略，下次Append前会被截掉
+			break
+		}
+		data = rest
+		var rec CacheEntry
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("decode cache %s: %w", path, err)
+		}
+		entry.Start, entry.End = rec.Start, rec.End
+		series = append(series, rec.KLines)
+		records++
+	}
+	if records == 0 {
+		return nil, nil
+	}
+	entry.KLines = MergeKLines(series...)
+
+	if records > fileStoreMaxRecords {
+		if err := s.compact(path, size, &entry); err != nil {
+			return nil, fmt.Errorf("compact cache %s: %w", path, err)
+		}
+	}
+	return &entry, nil
+}
+
+// compact 将文件合并为一条记录，读取后文件被其他调用修改过时跳过
+func (s *FileStore) compact(path string, size int64, entry *CacheEntry) error {
+	data, err := encodeCacheRecord(entry)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fi, err := os.Stat(path); err != nil || fi.Size() != size {
+		return nil
+	}
+	return replaceFile(path, data)
+}
+
+// Save 实现KLineStore，替换文件中的所有记录
+func (s *FileStore) Save(key CacheKey, entry *CacheEntry) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	data, err := encodeCacheRecord(entry)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return replaceFile(path, data)
+}
+
+// replaceFile 先写入临时文件再重命名，避免写入中断时损坏已有缓存
+func replaceFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Append 实现KLineAppender，在文件末尾追加一条只包含klines的记录
+func (s *FileStore) Append(key CacheKey, start, end int64, klines []KLine) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	data, err := encodeCacheRecord(&CacheEntry{Start: start, End: end, KLines: klines})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := truncatePartialRecord(f); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// truncatePartialRecord 截掉文件末尾没有换行符的不完整记录(上次追加时被中断)，
+// 否则新记录会接在它后面，导致之后的Load都无法解码
+func truncatePartialRecord(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	end := fi.Size()
+	buf := make([]byte, 4096)
+	for pos := end; pos > 0; {
+		n := int64(len(buf))
+		if n > pos {
+			n = pos
+		}
+		pos -= n
+		if _, err := f.ReadAt(buf[:n], pos); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			if keep := pos + int64(i) + 1; keep < end {
+				return f.Truncate(keep)
+			}
+			return nil
+		}
+	}
+	if end > 0 {
+		return f.Truncate(0)
+	}
+	return nil
+}
+
+// encodeCacheRecord 将记录编码为一行JSON
+func encodeCacheRecord(entry *CacheEntry) ([]byte, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Delete 实现KLineStore
+func (s *FileStore) Delete(key CacheKey) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Keys 实现KLineStore
+func (s *FileStore) Keys() ([]CacheKey, error) {
+	var keys []CacheKey
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".jsonl") {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) != 3 || !isPathSegment(parts[0]) || !isPathSegment(parts[1]) {
+			return nil
+		}
+		kt, adjust, ok := strings.Cut(strings.TrimSuffix(parts[2], ".jsonl"), "_")
+		if !ok {
+			return nil
+		}
+		klineType, err1 := strconv.Atoi(kt)
+		adj, err2 := strconv.Atoi(adjust)
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		keys = append(keys, CacheKey{Code: parts[0] + ":" + parts[1], KLineType: klineType, Adjust: adj})
+		return nil
+	})
+	return keys, err
+}
+
+// CacheInfo 缓存概况
+type CacheInfo struct {
+	Key   CacheKey
+	Start int64 // 已缓存范围的起始时间戳(含)，0表示已缓存到最早的数据
+	End   int64 // 已缓存范围的结束时间戳(含)
+	Count int   // 已缓存的K线数量
+}
+
+// KLineCache 位于历史K线接口前的缓存，按代码、K线类型和复权类型保存K线，
+// 只请求缓存范围之外的数据。尚未结束的最后一根K线会返回给调用方，但不会写入缓存
+type KLineCache struct {
+	fetch HistoryFunc
+	store KLineStore
+	now   func() time.Time
+
+	mu    sync.Mutex
+	locks map[CacheKey]*keyLock
+}
+
+// keyLock 单个键的锁，refs为持有或等待该锁的调用数，为0时从locks中删除
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// NewKLineCache 创建K线缓存，fetch通常为QOSClient.GetHistoryKLineContext
+// 或WSClient.RequestHistoryKLineContext
+func NewKLineCache(fetch HistoryFunc, store KLineStore) *KLineCache {
+	return &KLineCache{
+		fetch: fetch,
+		store: store,
+		now:   time.Now,
+		locks: make(map[CacheKey]*keyLock),
+	}
+}
+
+// lock 锁定一个键，避免并发请求重复获取同一范围，返回的函数解锁并在无人使用时释放该锁
+func (c *KLineCache) lock(key CacheKey) func() {
+	c.mu.Lock()
+	l, ok := c.locks[key]
+	if !ok {
+		l = &keyLock{}
+		c.locks[key] = l
+	}
+	l.refs++
+	c.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		c.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(c.locks, key)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// Range 返回时间戳在[start, end]范围内的K线，按时间从旧到新排列。end为0表示到最新
+func (c *KLineCache) Range(ctx context.Context, key CacheKey, start, end int64) ([]KLine, error) {
+	key, err := key.normalize()
+	if err != nil {
+		return nil, err
+	}
+	defer c.lock(key)()
+
+	now := c.now().Unix()
+	if end <= 0 || end > now {
+		end = now
+	}
+	entry, err := c.store.Load(key)
+	if err != nil {
+		return nil, err
+	}
+	prev := entryRange(entry)
+
+	var added []KLine
+	if entry == nil {
+		klines, _, err := c.collect(ctx, key, start, end, nil)
+		if err != nil {
+			return nil, err
+		}
+		entry = &CacheEntry{Start: start, End: end, KLines: klines}
+		added = klines
+	} else {
+		if end > entry.End {
+			tail, _, err := c.collect(ctx, key, entry.End, end, nil)
+			if err != nil {
+				return nil, err
+			}
+			entry.KLines = MergeKLines(entry.KLines, tail)
+			entry.End = end
+			added = append(added, tail...)
+		}
+		if start < entry.Start {
+			head, _, err := c.collect(ctx, key, start, entry.Start, nil)
+			if err != nil {
+				return nil, err
+			}
+			entry.KLines = MergeKLines(head, entry.KLines)
+			entry.Start = start
+			added = append(added, head...)
+		}
+	}
+
+	result, err := c.save(key, prev, entry, added, now)
+	if err != nil {
+		return nil, err
+	}
+	return filterKLines(result, start, end), nil
+}
+
+// Before 返回时间戳不晚于end的最后count根K线，按时间从旧到新排列。end为0表示到最新
+func (c *KLineCache) Before(ctx context.Context, key CacheKey, end int64, count int) ([]KLine, error) {
+	key, err := key.normalize()
+	if err != nil {
+		return nil, err
+	}
+	if count <= 0 {
+		return nil, nil
+	}
+	defer c.lock(key)()
+
+	now := c.now().Unix()
+	if end <= 0 || end > now {
+		end = now
+	}
+	entry, err := c.store.Load(key)
+	if err != nil {
+		return nil, err
+	}
+	prev := entryRange(entry)
+	enough := func(klines []KLine) bool { return len(filterKLines(klines, 0, end)) >= count }
+
+	var added []KLine
+	if entry == nil {
+		klines, exhausted, err := c.collect(ctx, key, 0, end, func(got []KLine) bool { return len(got) >= count })
+		if err != nil {
+			return nil, err
+		}
+		entry = &CacheEntry{End: end, KLines: klines}
+		added = klines
+		if !exhausted && len(klines) > 0 {
+			entry.Start = klines[0].Timestamp
+		}
+	} else {
+		if end > entry.End {
+			tail, _, err := c.collect(ctx, key, entry.End, end, nil)
+			if err != nil {
+				return nil, err
+			}
+			entry.KLines = MergeKLines(entry.KLines, tail)
+			entry.End = end
+			added = append(added, tail...)
+		}
+		if entry.Start > 0 && !enough(entry.KLines) {
+			missing, oldest := count-len(filterKLines(entry.KLines, 0, end)), entry.Start
+			head, exhausted, err := c.collect(ctx, key, 0, entry.Start, func(got []KLine) bool {
+				// 第一根可能与缓存中最早的K线重复，不计入
+				n := 0
+				for _, k := range got {
+					if k.Timestamp <= end && k.Timestamp < oldest {
+						n++
+					}
+				}
+				return n >= missing
+			})
+			if err != nil {
+				return nil, err
+			}
+			entry.KLines = MergeKLines(head, entry.KLines)
+			entry.Start = 0
+			if !exhausted && len(head) > 0 {
+				entry.Start = head[0].Timestamp
+			}
+			added = append(added, head...)
+		}
+	}
+
+	result, err := c.save(key, prev, entry, added, now)
+	if err != nil {
+		return nil, err
+	}
+	result = filterKLines(result, 0, end)
+	if len(result) > count {
+		result = result[len(result)-count:]
+	}
+	return result, nil
+}
+
+// Latest 返回最新的count根K线，按时间从旧到新排列
+func (c *KLineCache) Latest(ctx context.Context, key CacheKey, count int) ([]KLine, error) {
+	return c.Before(ctx, key, 0, count)
+}
+
+// GetHistoryKLineContext 与QOSClient.GetHistoryKLineContext相同，但优先使用缓存，
+// 因此KLineCache本身也可以作为HistoryFunc使用
+func (c *KLineCache) GetHistoryKLineContext(ctx context.Context, requests []KLineRequest) ([][]KLine, error) {
+	requests, err := normalizeKLineRequests(requests)
+	if err != nil {
+		return nil, err
+	}
+	var result [][]KLine
+	for _, r := range requests {
+		symbols, err := ParseSymbols(r.Codes)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range symbols {
+			key := CacheKey{Code: s.String(), KLineType: r.KLineType, Adjust: r.Adjust}
+			klines, err := c.Before(ctx, key, r.EndTime, r.Count)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, klines)
+		}
+	}
+	return result, nil
+}
+
+// GetKLineContext 与QOSClient.GetKLineContext相同，返回最新的K线，但优先使用缓存
+func (c *KLineCache) GetKLineContext(ctx context.Context, requests []KLineRequest) ([][]KLine, error) {
+	latest := make([]KLineRequest, len(requests))
+	for i, r := range requests {
+		r.EndTime = 0
+		latest[i] = r
+	}
+	return c.GetHistoryKLineContext(ctx, latest)
+}
+
+// Warm 预先缓存从start到最新的K线
+func (c *KLineCache) Warm(ctx context.Context, start int64, keys ...CacheKey) error {
+	for _, key := range keys {
+		if _, err := c.Range(ctx, key, start, 0); err != nil {
+			return fmt.Errorf("warm %s: %w", key.Code, err)
+		}
+	}
+	return nil
+}
+
+// Info 返回缓存概况，没有缓存时ok为false
+func (c *KLineCache) Info(key CacheKey) (info CacheInfo, ok bool, err error) {
+	key, err = key.normalize()
+	if err != nil {
+		return CacheInfo{}, false, err
+	}
+	entry, err := c.store.Load(key)
+	if err != nil || entry == nil {
+		return CacheInfo{}, false, err
+	}
+	return CacheInfo{Key: key, Start: entry.Start, End: entry.End, Count: len(entry.KLines)}, true, nil
+}
+
+// Keys 返回所有已缓存的键
+func (c *KLineCache) Keys() ([]CacheKey, error) {
+	return c.store.Keys()
+}
+
+// Invalidate 删除一个键的缓存
+func (c *KLineCache) Invalidate(key CacheKey) error {
+	key, err := key.normalize()
+	if err != nil {
+		return err
+	}
+	defer c.lock(key)()
+	return c.store.Delete(key)
+}
+
+// collect 从end向前获取K线直到start，enough返回true时提前结束。
+// 返回的K线按时间从旧到新排列，exhausted表示在start之前已没有更早的数据
+func (c *KLineCache) collect(ctx context.Context, key CacheKey, start, end int64, enough func([]KLine) bool) (klines []KLine, exhausted bool, err error) {
+	q := HistoryQuery{Code: key.Code, KLineType: key.KLineType, Adjust: key.Adjust, Start: start, End: end}
+	done := false
+	for k, err := range HistoryKLines(ctx, c.fetch, q) {
+		if err != nil {
+			return nil, false, err
+		}
+		klines = append(klines, k)
+		if enough != nil && enough(klines) {
+			done = true
+			break
+		}
+	}
+	slices.Reverse(klines)
+	return klines, !done && start == 0, nil
+}
+
+// entryRange 返回只包含缓存范围的副本，entry为nil时返回nil
+func entryRange(entry *CacheEntry) *CacheEntry {
+	if entry == nil {
+		return nil
+	}
+	return &CacheEntry{Start: entry.Start, End: entry.End}
+}
+
+// save 保存缓存，尚未结束的最后一根K线不写入缓存，缓存范围截止到它之前。
+// prev为读取时的缓存范围，added为本次新获取的K线；存储实现了KLineAppender时只追加added，
+// 没有新数据且范围不变时不写入。返回包含未结束K线的全部K线
+func (c *KLineCache) save(key CacheKey, prev, entry *CacheEntry, added []KLine, now int64) ([]KLine, error) {
+	result := entry.KLines
+	if n := len(entry.KLines); n > 0 {
+		last := entry.KLines[n-1]
+		if klineEnd(last.Timestamp, key.KLineType, codeLocation(key.Code)) > now {
+			entry.KLines = entry.KLines[:n-1]
+			entry.End = min(entry.End, last.Timestamp-1)
+			added = slices.DeleteFunc(slices.Clone(added), func(k KLine) bool { return k.Timestamp == last.Timestamp })
+		}
+	}
+
+	if prev != nil && len(added) == 0 && prev.Start == entry.Start && prev.End == entry.End {
+		return result, nil
+	}
+	var err error
+	if appender, ok := c.store.(KLineAppender); ok && prev != nil {
+		err = appender.Append(key, entry.Start, entry.End, added)
+	} else {
+		err = c.store.Save(key, entry)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// klineEnd 返回开始于ts的klineType类型K线的结束时间
+func klineEnd(ts int64, klineType int, loc *time.Location) int64 {
+	if isIntradayKLineType(klineType) {
+		return ts + int64(klineType)*60
+	}
+	t := time.Unix(resampleBucket(time.Unix(ts, 0).In(loc), klineType, nil), 0).In(loc)
+	switch klineType {
+	case KLineTypeDay:
+		return t.AddDate(0, 0, 1).Unix()
+	case KLineTypeWeek:
+		return t.AddDate(0, 0, 7).Unix()
+	case KLineTypeMonth:
+		return t.AddDate(0, 1, 0).Unix()
+	case KLineTypeYear:
+		return t.AddDate(1, 0, 0).Unix()
+	}
+	return ts
+}
+
+// filterKLines 返回时间戳在[start, end]范围内的K线
+func filterKLines(klines []KLine, start, end int64) []KLine {
+	var result []KLine
+	for _, k := range klines {
+		if k.Timestamp >= start && k.Timestamp <= end {
+			result = append(result, k)
+		}
+	}
+	return result
+}
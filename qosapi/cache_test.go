@@ -0,0 +1,286 @@
+package qosapi_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+	"github.com/qos-max/qos-quote-api-go-sdk/qostest"
+)
+
+func TestFileStoreRejectsUnsafeKeys(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "cache")
+	store, err := qosapi.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+
+	entry := &qosapi.CacheEntry{End: 1, KLines: []qosapi.KLine{{Timestamp: 1}}}
+	for _, code := range []string{"US:..", "US:.", "..:AAPL", "US:../../x", `US:..\x`, "US:", ":AAPL", "AAPL", "US:A:B"} {
+		key := qosapi.CacheKey{Code: code, KLineType: qosapi.KLineTypeDay}
+		if err := store.Save(key, entry); !errors.Is(err, qosapi.ErrInvalidCode) {
+			t.Errorf("Save(%q) error = %v, want ErrInvalidCode", code, err)
+		}
+		if _, err := store.Load(key); !errors.Is(err, qosapi.ErrInvalidCode) {
+			t.Errorf("Load(%q) error = %v, want ErrInvalidCode", code, err)
+		}
+		if err := store.Delete(key); !errors.Is(err, qosapi.ErrInvalidCode) {
+			t.Errorf("Delete(%q) error = %v, want ErrInvalidCode", code, err)
+		}
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "cache" {
+		t.Fatalf("files written outside the cache directory: %v", entries)
+	}
+	keys, err := store.Keys()
+	if err != nil || len(keys) != 0 {
+		t.Fatalf("Keys = %v, %v; want none", keys, err)
+	}
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	store, err := qosapi.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	key := qosapi.CacheKey{Code: "US:BRK.B", KLineType: qosapi.KLineTypeDay, Adjust: 1}
+	entry := &qosapi.CacheEntry{Start: 100, End: 300, KLines: []qosapi.KLine{{Code: "US:BRK.B", Timestamp: 100}, {Code: "US:BRK.B", Timestamp: 200}}}
+	if err := store.Save(key, entry); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := store.Load(key)
+	if err != nil || got == nil {
+		t.Fatalf("Load = %v, %v", got, err)
+	}
+	if got.Start != 100 || got.End != 300 || len(got.KLines) != 2 {
+		t.Fatalf("Load = %+v", got)
+	}
+	keys, err := store.Keys()
+	if err != nil || len(keys) != 1 || keys[0] != key {
+		t.Fatalf("Keys = %v, %v; want [%v]", keys, err, key)
+	}
+	if err := store.Delete(key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got, err := store.Load(key); err != nil || got != nil {
+		t.Fatalf("Load after Delete = %v, %v", got, err)
+	}
+}
+
+// storeFile 返回FileStore中键对应文件的各行
+func storeFile(t *testing.T, dir string, key qosapi.CacheKey) []string {
+	t.Helper()
+	market, ticker, _ := strings.Cut(key.Code, ":")
+	data, err := os.ReadFile(filepath.Join(dir, market, ticker, fmt.Sprintf("%d_%d.jsonl", key.KLineType, key.Adjust)))
+	if err != nil {
+		t.Fatalf("read cache file: %v", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestFileStoreAppend(t *testing.T) {
+	dir := t.TempDir()
+	store, err := qosapi.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	key := qosapi.CacheKey{Code: "US:AAPL", KLineType: qosapi.KLineTypeMin1}
+	k := func(ts int64, close string) qosapi.KLine {
+		return qosapi.KLine{Code: "US:AAPL", Timestamp: ts, Close: close, KLineType: qosapi.KLineTypeMin1}
+	}
+
+	if err := store.Save(key, &qosapi.CacheEntry{Start: 60, End: 180, KLines: []qosapi.KLine{k(60, "1"), k(120, "2"), k(180, "3")}}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := store.Append(key, 0, 240, []qosapi.KLine{k(180, "3.5"), k(240, "4"), k(0, "0")}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if lines := storeFile(t, dir, key); len(lines) != 2 {
+		t.Fatalf("file has %d records, want 2", len(lines))
+	}
+
+	got, err := store.Load(key)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var closes []string
+	for _, kl := range got.KLines {
+		closes = append(closes, kl.Close)
+	}
+	if got.Start != 0 || got.End != 240 || strings.Join(closes, ",") != "0,1,2,3.5,4" {
+		t.Fatalf("Load = [%d, %d] %v, want [0, 240] 0,1,2,3.5,4", got.Start, got.End, closes)
+	}
+
+	// 被中断的追加不影响已有数据
+	path := filepath.Join(dir, "US", "AAPL", "1_0.jsonl")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	f.WriteString(`{"start":0,"end":300,"klines":[{"c":"US:AA`)
+	f.Close()
+	if got, err := store.Load(key); err != nil || got.End != 240 || len(got.KLines) != 5 {
+		t.Fatalf("Load with torn record = %+v, %v", got, err)
+	}
+}
+
+func TestFileStoreAppendAfterTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	store, err := qosapi.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	key := qosapi.CacheKey{Code: "US:AAPL", KLineType: qosapi.KLineTypeMin1}
+	k := func(ts int64) qosapi.KLine {
+		return qosapi.KLine{Code: "US:AAPL", Timestamp: ts, KLineType: qosapi.KLineTypeMin1}
+	}
+
+	tests := []struct {
+		name string
+		torn string
+	}{
+		{"short", `{"start":0,"end":300,"klines":[{"c":"US:AA`},
+		{"longer than a read block", `{"start":0,"end":300,"klines":[` + strings.Repeat(`{"c":"US:AAPL","ts":1},`, 500)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store.Save(key, &qosapi.CacheEntry{Start: 60, End: 120, KLines: []qosapi.KLine{k(60), k(120)}}); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			path := filepath.Join(dir, "US", "AAPL", "1_0.jsonl")
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			f.WriteString(tt.torn)
+			f.Close()
+
+			if err := store.Append(key, 60, 180, []qosapi.KLine{k(180)}); err != nil {
+				t.Fatalf("Append: %v", err)
+			}
+			for i := range 2 {
+				got, err := store.Load(key)
+				if err != nil {
+					t.Fatalf("Load %d after torn record and Append: %v", i, err)
+				}
+				if got.End != 180 || len(got.KLines) != 3 {
+					t.Fatalf("Load = [%d, %d] %d klines, want [60, 180] 3 klines", got.Start, got.End, len(got.KLines))
+				}
+			}
+			if lines := storeFile(t, dir, key); len(lines) != 2 {
+				t.Fatalf("file has %d records, want 2", len(lines))
+			}
+		})
+	}
+}
+
+func TestFileStoreCompactsRecords(t *testing.T) {
+	dir := t.TempDir()
+	store, err := qosapi.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	key := qosapi.CacheKey{Code: "US:AAPL", KLineType: qosapi.KLineTypeMin1}
+	for i := range 40 {
+		ts := int64(i) * 60
+		if err := store.Append(key, 0, ts, []qosapi.KLine{{Code: "US:AAPL", Timestamp: ts, KLineType: qosapi.KLineTypeMin1}}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	got, err := store.Load(key)
+	if err != nil || len(got.KLines) != 40 || got.End != 39*60 {
+		t.Fatalf("Load = %+v, %v", got, err)
+	}
+	if lines := storeFile(t, dir, key); len(lines) != 1 {
+		t.Fatalf("file has %d records after compaction, want 1", len(lines))
+	}
+	if again, err := store.Load(key); err != nil || len(again.KLines) != 40 {
+		t.Fatalf("Load after compaction = %+v, %v", again, err)
+	}
+}
+
+func TestKLineCacheAppendsOnlyNewKLines(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	const base = 1700000000
+	srv.SetKLines("US:AAPL", qosapi.KLineTypeMin1, minuteKLines("US:AAPL", base, 100)...)
+	client := srv.Client()
+
+	dir := t.TempDir()
+	store, err := qosapi.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	cache := qosapi.NewKLineCache(client.GetHistoryKLineContext, store)
+	key := qosapi.CacheKey{Code: "US:AAPL", KLineType: qosapi.KLineTypeMin1}
+	ctx := context.Background()
+
+	klines, err := cache.Range(ctx, key, base, base+99*60)
+	if err != nil || len(klines) != 100 {
+		t.Fatalf("Range = %d klines, %v; want 100", len(klines), err)
+	}
+	first := storeFile(t, dir, key)
+	if len(first) != 1 {
+		t.Fatalf("file has %d records, want 1", len(first))
+	}
+
+	// 缓存之后服务端有了新的K线
+	srv.SetKLines("US:AAPL", qosapi.KLineTypeMin1, minuteKLines("US:AAPL", base, 110)...)
+	klines, err = cache.Range(ctx, key, base, 0)
+	if err != nil || len(klines) != 110 {
+		t.Fatalf("Range = %d klines, %v; want 110", len(klines), err)
+	}
+	lines := storeFile(t, dir, key)
+	if len(lines) != 2 || lines[0] != first[0] {
+		t.Fatalf("cache file was rewritten instead of appended: %d records", len(lines))
+	}
+	if len(lines[1]) >= len(lines[0]) {
+		t.Fatalf("appended record (%d bytes) is not smaller than the full record (%d bytes)", len(lines[1]), len(lines[0]))
+	}
+
+	// 缓存范围内的请求不访问服务端
+	requests := srv.RequestCount("/history")
+	if klines, err := cache.Range(ctx, key, base+10*60, base+20*60); err != nil || len(klines) != 11 {
+		t.Fatalf("Range = %d klines, %v; want 11", len(klines), err)
+	}
+	if n := srv.RequestCount("/history"); n != requests {
+		t.Fatalf("cached range made %d requests", n-requests)
+	}
+	if n := cache.LockCount(); n != 0 {
+		t.Fatalf("%d key locks left after all calls returned", n)
+	}
+}
+
+func TestKLineCacheReleasesKeyLocks(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := srv.Client()
+	cache := qosapi.NewKLineCache(client.GetHistoryKLineContext, qosapi.NewMemoryStore())
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := qosapi.CacheKey{Code: fmt.Sprintf("HK:%d", i%10+1), KLineType: qosapi.KLineTypeDay}
+			if _, err := cache.Latest(context.Background(), key, 10); err != nil {
+				t.Errorf("Latest: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := cache.LockCount(); n != 0 {
+		t.Fatalf("%d key locks left after all calls returned", n)
+	}
+}
@@ -0,0 +1,365 @@
+package qosapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Holiday 休市日或提前收市日
+type Holiday struct {
+	Date  string `json:"date"`            // 日期，格式为2006-01-02
+	Name  string `json:"name,omitempty"`  // 名称
+	Close string `json:"close,omitempty"` // 提前收市时间(当地时间)，格式为15:04，为空表示全天休市
+}
+
+// date 当地日期
+type date struct {
+	y int
+	m time.Month
+	d int
+}
+
+func dateOf(t time.Time) date {
+	y, m, d := t.Date()
+	return date{y, m, d}
+}
+
+// at 返回当地日期零点之后clock(当地时钟)对应的时间
+func (d date) at(clock time.Duration, loc *time.Location) time.Time {
+	return time.Date(d.y, d.m, d.d, 0, 0, int(clock/time.Second), 0, loc)
+}
+
+func (d date) addDays(n int) date {
+	return dateOf(time.Date(d.y, d.m, d.d+n, 0, 0, 0, 0, time.UTC))
+}
+
+func (d date) weekday() time.Weekday {
+	return time.Date(d.y, d.m, d.d, 0, 0, 0, 0, time.UTC).Weekday()
+}
+
+// calendarSearchDays 查找下一个交易日或交易时段时最多查找的天数
+const calendarSearchDays = 3660
+
+// Calendar 市场交易日历，包括常规交易时段、午休、周末、节假日和提前收市日。
+// 内置交易时段，节假日需要通过AddHolidays或LoadHolidays加载。可以在多个goroutine中使用
+type Calendar struct {
+	market   string
+	loc      *time.Location
+	sessions []Session
+	extended []Session
+	alwaysOn bool
+
+	mu       sync.RWMutex
+	holidays map[date]Holiday
+	closes   map[date]time.Duration
+}
+
+// NewCalendar 创建市场的交易日历，market为Market*常量
+func NewCalendar(market string) (*Calendar, error) {
+	sessions, ok := marketSessions[market]
+	if !ok {
+		return nil, fmt.Errorf("unknown market %q", market)
+	}
+	c := &Calendar{
+		market:   market,
+		loc:      MarketLocation(market),
+		sessions: sessions,
+		alwaysOn: market == MarketCF,
+		holidays: make(map[date]Holiday),
+		closes:   make(map[date]time.Duration),
+	}
+	if market == MarketUS {
+		c.extended = usExtendedSessions
+	}
+	return c, nil
+}
+
+var (
+	calendarsMu sync.Mutex
+	calendars   = make(map[string]*Calendar)
+)
+
+// MarketCalendar 返回市场共享的交易日历，加载到共享日历的节假日对所有使用者生效
+func MarketCalendar(market string) (*Calendar, error) {
+	calendarsMu.Lock()
+	defer calendarsMu.Unlock()
+	if c, ok := calendars[market]; ok {
+		return c, nil
+	}
+	c, err := NewCalendar(market)
+	if err != nil {
+		return nil, err
+	}
+	calendars[market] = c
+	return c, nil
+}
+
+// Market 返回市场代码
+func (c *Calendar) Market() string {
+	return c.market
+}
+
+// Location 返回市场所在时区
+func (c *Calendar) Location() *time.Location {
+	return c.loc
+}
+
+// AddHolidays 添加休市日或提前收市日，已有的同一日期会被替换
+func (c *Calendar) AddHolidays(holidays ...Holiday) error {
+	type parsed struct {
+		d     date
+		h     Holiday
+		close time.Duration
+	}
+	items := make([]parsed, 0, len(holidays))
+	for _, h := range holidays {
+		t, err := time.Parse(time.DateOnly, h.Date)
+		if err != nil {
+			return fmt.Errorf("holiday %q: %w", h.Date, err)
+		}
+		p := parsed{d: dateOf(t), h: h}
+		if h.Close != "" {
+			ct, err := time.Parse("15:04", h.Close)
+			if err != nil {
+				return fmt.Errorf("holiday %s close %q: %w", h.Date, h.Close, err)
+			}
+			p.close = hm(ct.Hour(), ct.Minute())
+		}
+		items = append(items, p)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range items {
+		delete(c.holidays, p.d)
+		delete(c.closes, p.d)
+		if p.close > 0 {
+			c.closes[p.d] = p.close
+		} else {
+			c.holidays[p.d] = p.h
+		}
+	}
+	return nil
+}
+
+// LoadHolidays 从JSON读取节假日，格式为Holiday数组：
+//
+//	[{"date": "2026-12-25", "name": "Christmas"}, {"date": "2026-11-27", "close": "13:00"}]
+func (c *Calendar) LoadHolidays(r io.Reader) error {
+	var holidays []Holiday
+	if err := json.NewDecoder(r).Decode(&holidays); err != nil {
+		return fmt.Errorf("decode holidays: %w", err)
+	}
+	return c.AddHolidays(holidays...)
+}
+
+// LoadHolidayFile 从JSON文件读取节假日，格式同LoadHolidays
+func (c *Calendar) LoadHolidayFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := c.LoadHolidays(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Holiday 返回t所在日期的休市日信息
+func (c *Calendar) Holiday(t time.Time) (Holiday, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	h, ok := c.holidays[dateOf(t.In(c.loc))]
+	return h, ok
+}
+
+// isTradingDate 是否为交易日
+func (c *Calendar) isTradingDate(d date) bool {
+	if c.alwaysOn {
+		return true
+	}
+	if wd := d.weekday(); wd == time.Saturday || wd == time.Sunday {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, holiday := c.holidays[d]
+	return !holiday
+}
+
+// sessionsOn 返回某个交易日的交易时段，提前收市日截断到收市时间，非交易日返回nil。
+// extended为true时包含美股盘前盘后时段，按时间顺序排列
+func (c *Calendar) sessionsOn(d date, extended bool) []Session {
+	if !c.isTradingDate(d) {
+		return nil
+	}
+	sessions := slices.Clone(c.sessions)
+	if extended && len(c.extended) > 0 {
+		sessions = append(sessions, c.extended...)
+		slices.SortFunc(sessions, func(a, b Session) int { return int(a.Start - b.Start) })
+	}
+
+	c.mu.RLock()
+	closeAt, early := c.closes[d]
+	c.mu.RUnlock()
+	if !early {
+		return sessions
+	}
+	result := sessions[:0]
+	for _, s := range sessions {
+		if s.Start >= closeAt {
+			continue
+		}
+		s.End = min(s.End, closeAt)
+		result = append(result, s)
+	}
+	return result
+}
+
+// IsTradingDay t所在的当地日期是否为交易日
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	return c.isTradingDate(dateOf(t.In(c.loc)))
+}
+
+// IsOpen t时刻是否处于常规交易时段内(不含午休和美股盘前盘后)
+func (c *Calendar) IsOpen(t time.Time) bool {
+	s, ok := c.sessionAt(t, false)
+	return ok && s.Type == USTradeSessionIntraday
+}
+
+// SessionAt 返回t时刻所处的交易时段(包含美股盘前盘后)，不在任何时段内时ok为false。
+// 返回的时段已按提前收市截断
+func (c *Calendar) SessionAt(t time.Time) (Session, bool) {
+	return c.sessionAt(t, true)
+}
+
+func (c *Calendar) sessionAt(t time.Time, extended bool) (Session, bool) {
+	t = t.In(c.loc)
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	for _, s := range c.sessionsOn(dateOf(t), extended) {
+		if clock >= s.Start && clock < s.End {
+			return s, true
+		}
+	}
+	return Session{}, false
+}
+
+// SessionType 返回t时刻的交易时段类型(USTradeSession*)。美股交易日20:00到次日04:00的夜盘时段
+// 返回USTradeSessionNight，不在任何时段内时返回USTradeSessionUnknown
+func (c *Calendar) SessionType(t time.Time) int {
+	if s, ok := c.SessionAt(t); ok {
+		return s.Type
+	}
+	if c.market == MarketUS {
+		t = t.In(c.loc)
+		d := dateOf(t)
+		switch {
+		case t.Hour() < 4 && c.isTradingDate(d):
+			return USTradeSessionNight
+		case t.Hour() >= 20 && c.isTradingDate(d.addDays(1)):
+			return USTradeSessionNight
+		}
+	}
+	return USTradeSessionUnknown
+}
+
+// NextOpen 返回t之后(不含t)下一个常规交易时段的开始时间，午休后的开盘也算作开盘。
+// 全天交易的市场(加密货币)返回t，找不到时返回零值
+func (c *Calendar) NextOpen(t time.Time) time.Time {
+	if c.alwaysOn {
+		return t
+	}
+	t = t.In(c.loc)
+	d := dateOf(t)
+	for i := 0; i < calendarSearchDays; i++ {
+		for _, s := range c.sessionsOn(d, false) {
+			if open := d.at(s.Start, c.loc); open.After(t) {
+				return open
+			}
+		}
+		d = d.addDays(1)
+	}
+	return time.Time{}
+}
+
+// NextClose 返回t之后(不含t)下一个常规交易时段的结束时间，午休开始也算作收盘。
+// 全天交易的市场(加密货币)和找不到时返回零值
+func (c *Calendar) NextClose(t time.Time) time.Time {
+	if c.alwaysOn {
+		return time.Time{}
+	}
+	t = t.In(c.loc)
+	d := dateOf(t)
+	for i := 0; i < calendarSearchDays; i++ {
+		for _, s := range c.sessionsOn(d, false) {
+			if end := d.at(s.End, c.loc); end.After(t) {
+				return end
+			}
+		}
+		d = d.addDays(1)
+	}
+	return time.Time{}
+}
+
+// NextTradingDay 返回t所在日期之后的下一个交易日(当地零点)
+func (c *Calendar) NextTradingDay(t time.Time) time.Time {
+	return c.AddTradingDays(t, 1)
+}
+
+// PrevTradingDay 返回t所在日期之前的上一个交易日(当地零点)
+func (c *Calendar) PrevTradingDay(t time.Time) time.Time {
+	return c.AddTradingDays(t, -1)
+}
+
+// AddTradingDays 返回t所在日期之后第n个交易日(当地零点)，n为负数时向前查找，
+// n为0时返回t所在日期的零点。找不到时返回零值
+func (c *Calendar) AddTradingDays(t time.Time, n int) time.Time {
+	d := dateOf(t.In(c.loc))
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	for i := 0; n > 0; i++ {
+		if i >= calendarSearchDays*(n+1) {
+			return time.Time{}
+		}
+		d = d.addDays(step)
+		if c.isTradingDate(d) {
+			n--
+		}
+	}
+	return d.at(0, c.loc)
+}
+
+// TradingDaysBetween 返回[from, to)之间按当地日期计算的交易日数量，to早于from时返回负数
+func (c *Calendar) TradingDaysBetween(from, to time.Time) int {
+	a, b := dateOf(from.In(c.loc)), dateOf(to.In(c.loc))
+	sign := 1
+	if b.at(0, time.UTC).Before(a.at(0, time.UTC)) {
+		a, b, sign = b, a, -1
+	}
+	n := 0
+	for d := a; d != b; d = d.addDays(1) {
+		if c.isTradingDate(d) {
+			n++
+		}
+	}
+	return sign * n
+}
+
+// TradingSessions 返回t所在日期的常规交易时段的开始和结束时间，非交易日返回nil
+func (c *Calendar) TradingSessions(t time.Time) [][2]time.Time {
+	d := dateOf(t.In(c.loc))
+	var result [][2]time.Time
+	for _, s := range c.sessionsOn(d, false) {
+		result = append(result, [2]time.Time{d.at(s.Start, c.loc), d.at(s.End, c.loc)})
+	}
+	return result
+}
@@ -0,0 +1,185 @@
+package qosapi_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+// usCalendar 美股日历，2026-11-26感恩节休市，2026-11-27提前到13:00收市，2026-12-25圣诞节休市
+func usCalendar(t *testing.T) (*qosapi.Calendar, func(month time.Month, day, hour, min int) time.Time) {
+	t.Helper()
+	cal, err := qosapi.NewCalendar(qosapi.MarketUS)
+	if err != nil {
+		t.Fatalf("NewCalendar: %v", err)
+	}
+	if cal.Location() == time.UTC {
+		t.Skip("time zone data unavailable")
+	}
+	err = cal.LoadHolidays(strings.NewReader(`[
+		{"date": "2026-11-26", "name": "Thanksgiving"},
+		{"date": "2026-11-27", "close": "13:00"},
+		{"date": "2026-12-25", "name": "Christmas"}
+	]`))
+	if err != nil {
+		t.Fatalf("LoadHolidays: %v", err)
+	}
+	return cal, func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, cal.Location())
+	}
+}
+
+func TestCalendarTradingDays(t *testing.T) {
+	cal, at := usCalendar(t)
+
+	for _, tt := range []struct {
+		t    time.Time
+		want bool
+	}{
+		{at(11, 25, 12, 0), true},
+		{at(11, 26, 12, 0), false}, // 休市
+		{at(11, 27, 12, 0), true},  // 提前收市仍是交易日
+		{at(11, 28, 12, 0), false}, // 周六
+		{at(11, 26, 3, 0).UTC(), false},
+	} {
+		if got := cal.IsTradingDay(tt.t); got != tt.want {
+			t.Errorf("IsTradingDay(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+	if h, ok := cal.Holiday(at(11, 26, 10, 0)); !ok || h.Name != "Thanksgiving" {
+		t.Errorf("Holiday = %+v, %v", h, ok)
+	}
+
+	for _, tt := range []struct {
+		from time.Time
+		n    int
+		want time.Time
+	}{
+		{at(11, 25, 15, 0), 1, at(11, 27, 0, 0)},
+		{at(11, 25, 15, 0), 2, at(11, 30, 0, 0)},
+		{at(11, 30, 10, 0), -1, at(11, 27, 0, 0)},
+		{at(11, 30, 10, 0), -2, at(11, 25, 0, 0)},
+		{at(11, 26, 10, 0), 0, at(11, 26, 0, 0)},
+		{at(12, 24, 10, 0), 1, at(12, 28, 0, 0)},
+	} {
+		if got := cal.AddTradingDays(tt.from, tt.n); !got.Equal(tt.want) {
+			t.Errorf("AddTradingDays(%v, %d) = %v, want %v", tt.from, tt.n, got, tt.want)
+		}
+	}
+	if got := cal.NextTradingDay(at(11, 25, 10, 0)); !got.Equal(at(11, 27, 0, 0)) {
+		t.Errorf("NextTradingDay = %v", got)
+	}
+	if got := cal.PrevTradingDay(at(11, 27, 10, 0)); !got.Equal(at(11, 25, 0, 0)) {
+		t.Errorf("PrevTradingDay = %v", got)
+	}
+	if got := cal.TradingDaysBetween(at(11, 23, 0, 0), at(11, 30, 0, 0)); got != 4 {
+		t.Errorf("TradingDaysBetween = %d, want 4", got)
+	}
+	if got := cal.TradingDaysBetween(at(11, 30, 0, 0), at(11, 23, 0, 0)); got != -4 {
+		t.Errorf("TradingDaysBetween reversed = %d, want -4", got)
+	}
+}
+
+func TestCalendarSessions(t *testing.T) {
+	cal, at := usCalendar(t)
+
+	for _, tt := range []struct {
+		t           time.Time
+		open        bool
+		sessionType int
+	}{
+		{at(11, 25, 9, 29), false, qosapi.USTradeSessionPreMarket},
+		{at(11, 25, 9, 30), true, qosapi.USTradeSessionIntraday},
+		{at(11, 25, 15, 59), true, qosapi.USTradeSessionIntraday},
+		{at(11, 25, 16, 0), false, qosapi.USTradeSessionAfterHours},
+		{at(11, 24, 21, 0), false, qosapi.USTradeSessionNight},
+		{at(11, 25, 21, 0), false, qosapi.USTradeSessionUnknown}, // 次日休市，没有夜盘
+		{at(11, 26, 10, 0), false, qosapi.USTradeSessionUnknown},
+		{at(11, 27, 12, 59), true, qosapi.USTradeSessionIntraday},
+		{at(11, 27, 13, 0), false, qosapi.USTradeSessionUnknown}, // 提前收市后没有盘后
+	} {
+		if got := cal.IsOpen(tt.t); got != tt.open {
+			t.Errorf("IsOpen(%v) = %v, want %v", tt.t, got, tt.open)
+		}
+		if got := cal.SessionType(tt.t); got != tt.sessionType {
+			t.Errorf("SessionType(%v) = %d, want %d", tt.t, got, tt.sessionType)
+		}
+	}
+
+	for _, tt := range []struct {
+		from, open, close time.Time
+	}{
+		{at(11, 25, 8, 0), at(11, 25, 9, 30), at(11, 25, 16, 0)},
+		{at(11, 25, 9, 30), at(11, 27, 9, 30), at(11, 25, 16, 0)}, // 不含from本身
+		{at(11, 25, 17, 0), at(11, 27, 9, 30), at(11, 27, 13, 0)},
+		{at(11, 27, 13, 0), at(11, 30, 9, 30), at(11, 30, 16, 0)},
+		// 夏令时开始前的周五到之后的周一
+		{time.Date(2026, 3, 6, 17, 0, 0, 0, cal.Location()), time.Date(2026, 3, 9, 9, 30, 0, 0, cal.Location()), time.Date(2026, 3, 9, 16, 0, 0, 0, cal.Location())},
+	} {
+		if got := cal.NextOpen(tt.from); !got.Equal(tt.open) {
+			t.Errorf("NextOpen(%v) = %v, want %v", tt.from, got, tt.open)
+		}
+		if got := cal.NextClose(tt.from); !got.Equal(tt.close) {
+			t.Errorf("NextClose(%v) = %v, want %v", tt.from, got, tt.close)
+		}
+	}
+
+	if got := cal.TradingSessions(at(11, 27, 0, 0)); len(got) != 1 || !got[0][0].Equal(at(11, 27, 9, 30)) || !got[0][1].Equal(at(11, 27, 13, 0)) {
+		t.Errorf("TradingSessions on a half day = %v", got)
+	}
+	if got := cal.TradingSessions(at(11, 26, 0, 0)); got != nil {
+		t.Errorf("TradingSessions on a holiday = %v, want nil", got)
+	}
+}
+
+func TestCalendarLunchBreakAndAlwaysOpen(t *testing.T) {
+	hk, err := qosapi.NewCalendar(qosapi.MarketHK)
+	if err != nil {
+		t.Fatalf("NewCalendar: %v", err)
+	}
+	if hk.Location() == time.UTC {
+		t.Skip("time zone data unavailable")
+	}
+	at := func(hour, min int) time.Time { return time.Date(2026, 3, 9, hour, min, 0, 0, hk.Location()) }
+	if hk.IsOpen(at(12, 30)) {
+		t.Error("HK market should be closed during lunch")
+	}
+	if got := hk.NextOpen(at(12, 0)); !got.Equal(at(13, 0)) {
+		t.Errorf("NextOpen during lunch = %v, want 13:00", got)
+	}
+	if got := hk.NextClose(at(10, 0)); !got.Equal(at(12, 0)) {
+		t.Errorf("NextClose before lunch = %v, want 12:00", got)
+	}
+
+	cf, err := qosapi.NewCalendar(qosapi.MarketCF)
+	if err != nil {
+		t.Fatalf("NewCalendar: %v", err)
+	}
+	saturday := time.Date(2026, 3, 14, 3, 0, 0, 0, time.UTC)
+	if !cf.IsOpen(saturday) || !cf.IsTradingDay(saturday) || !cf.NextOpen(saturday).Equal(saturday) || !cf.NextClose(saturday).IsZero() {
+		t.Error("crypto calendar should always be open")
+	}
+}
+
+func TestCalendarRejectsInvalidHolidays(t *testing.T) {
+	cal, err := qosapi.NewCalendar(qosapi.MarketUS)
+	if err != nil {
+		t.Fatalf("NewCalendar: %v", err)
+	}
+	for _, h := range []qosapi.Holiday{
+		{Date: "2026/12/25"},
+		{Date: "2026-11-27", Close: "1pm"},
+	} {
+		if err := cal.AddHolidays(h); err == nil {
+			t.Errorf("AddHolidays(%+v) succeeded", h)
+		}
+	}
+	if err := cal.LoadHolidays(strings.NewReader(`{"date": "2026-12-25"}`)); err == nil {
+		t.Error("LoadHolidays with an object instead of an array succeeded")
+	}
+	if _, err := qosapi.NewCalendar("XX"); err == nil {
+		t.Error("NewCalendar for an unknown market succeeded")
+	}
+}
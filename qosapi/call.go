@@ -0,0 +1,95 @@
+package qosapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// rawResponse API响应，data保留原始JSON，由调用方按需解码
+type rawResponse struct {
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// codesRequest 按代码请求的请求体
+type codesRequest struct {
+	Codes []string `json:"codes"`
+}
+
+// tradeRequest 逐笔成交请求体
+type tradeRequest struct {
+	Codes []string `json:"codes"`
+	Count int      `json:"count"`
+}
+
+// klineRequest K线请求体
+type klineRequest struct {
+	KLineReqs []KLineRequest `json:"kline_reqs"`
+}
+
+// klineGroup K线接口返回的单个代码的K线
+type klineGroup struct {
+	Code string  `json:"c"`
+	K    []KLine `json:"k"`
+}
+
+// klineLines 将K线接口返回的数据转换为按请求顺序排列的K线
+func klineLines(groups []klineGroup) [][]KLine {
+	result := make([][]KLine, len(groups))
+	for i, item := range groups {
+		result[i] = item.K
+	}
+	return result
+}
+
+// Call 调用HTTP接口并将响应的data解码为T，path为接口路径(如"/snapshot")，
+// req为请求体。可用于调用SDK尚未封装的接口，与内置方法一样经过限流、重试和错误处理
+func Call[T any](ctx context.Context, c *QOSClient, path string, req any) (T, error) {
+	var result T
+	data, err := c.doRequest(ctx, http.MethodPost, path, requestCodesOf(req), req)
+	if err != nil {
+		return result, err
+	}
+	if err := decodeData(data, &result); err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
+// CallWS 发送WebSocket请求并将响应的data解码为T，req.ReqID由客户端自动分配。
+// 可用于调用SDK尚未封装的请求类型
+func CallWS[T any](ctx context.Context, c *WSClient, req WSRequest) (T, error) {
+	var result T
+	data, err := c.call(ctx, req)
+	if err != nil {
+		return result, err
+	}
+	if err := decodeData(data, &result); err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
+// decodeData 解码响应的data，data为空时保持v不变
+func decodeData(data json.RawMessage, v any) error {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// requestCodesOf 提取请求体中的品种代码，用于错误信息
+func requestCodesOf(req any) []string {
+	switch r := req.(type) {
+	case codesRequest:
+		return r.Codes
+	case tradeRequest:
+		return r.Codes
+	case klineRequest:
+		return requestCodes(nil, r.KLineReqs)
+	}
+	return nil
+}
@@ -2,25 +2,35 @@ package qosapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
 )
 
+// maxErrorBodySize 读取错误响应体的最大字节数
+const maxErrorBodySize = 64 << 10
+
 // QOSClient QOS行情API客户端
 type QOSClient struct {
-	apiKey     string
-	httpClient *http.Client
-	baseURL    string
+	apiKey      string
+	httpClient  *http.Client
+	baseURL     string
+	userAgent   string
+	retryPolicy *RetryPolicy
+	limiter     *RateLimiter
 }
 
-// NewClient 创建新的QOS客户端
-func NewClient(apiKey string) *QOSClient {
+// NewClient 创建新的QOS客户端，opts可配置基础URL、HTTP客户端、重试策略和限流器等
+func NewClient(apiKey string, opts ...Option) *QOSClient {
+	o := newClientOptions(opts)
 	return &QOSClient{
-		apiKey:     apiKey,
-		httpClient: &http.Client{},
-		baseURL:    HTTPBaseURL,
+		apiKey:      apiKey,
+		httpClient:  o.httpClient,
+		baseURL:     o.baseURL,
+		userAgent:   o.userAgent,
+		retryPolicy: o.retryPolicy,
+		limiter:     o.limiter,
 	}
 }
 
@@ -34,18 +44,52 @@ func (c *QOSClient) SetBaseURL(baseURL string) {
 	c.baseURL = baseURL
 }
 
-// doRequest 执行HTTP请求
-func (c *QOSClient) doRequest(method, path string, body interface{}) (*http.Response, error) {
-	var reqBody io.Reader
+// SetRetryPolicy 设置请求重试策略，nil表示不重试
+func (c *QOSClient) SetRetryPolicy(policy *RetryPolicy) {
+	c.retryPolicy = policy
+}
+
+// SetRateLimiter 设置客户端限流器，可与WSClient共享同一个限流器，nil表示不限流
+func (c *QOSClient) SetRateLimiter(limiter *RateLimiter) {
+	c.limiter = limiter
+}
+
+// doRequest 按重试策略执行HTTP请求，返回响应中的data
+func (c *QOSClient) doRequest(ctx context.Context, method, path string, codes []string, body interface{}) (json.RawMessage, error) {
+	var jsonData []byte
 	if body != nil {
-		jsonData, err := json.Marshal(body)
+		var err error
+		jsonData, err = json.Marshal(body)
 		if err != nil {
 			return nil, err
 		}
-		reqBody = bytes.NewBuffer(jsonData)
 	}
 
-	req, err := http.NewRequest(method, c.baseURL+path, reqBody)
+	policy := c.retryPolicy
+	for attempt := 1; ; attempt++ {
+		if c.limiter != nil {
+			if err := c.limiter.Wait(ctx, path); err != nil {
+				return nil, err
+			}
+		}
+		data, err := c.doRequestOnce(ctx, method, path, codes, jsonData)
+		if err == nil || policy == nil || attempt >= policy.MaxAttempts || !policy.shouldRetry(err) {
+			return data, err
+		}
+		if err := sleepContext(ctx, policy.delay(attempt, err)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// doRequestOnce 执行一次HTTP请求并解码响应，非2xx响应或msg不为OK时返回*APIError
+func (c *QOSClient) doRequestOnce(ctx context.Context, method, path string, codes []string, jsonData []byte) (json.RawMessage, error) {
+	var reqBody io.Reader
+	if jsonData != nil {
+		reqBody = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
 	if err != nil {
 		return nil, err
 	}
@@ -53,204 +97,121 @@ func (c *QOSClient) doRequest(method, path string, body interface{}) (*http.Resp
 	// 添加API Key到请求头
 	req.Header.Add("key", c.apiKey)
 	req.Header.Add("Content-Type", "application/json")
-
-	return c.httpClient.Do(req)
-}
-
-// GetInstrumentInfo 获取交易品种的基础信息
-func (c *QOSClient) GetInstrumentInfo(codes []string) ([]InstrumentInfo, error) {
-	req := struct {
-		Codes []string `json:"codes"`
-	}{
-		Codes: codes,
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
 	}
 
-	resp, err := c.doRequest("POST", "/instrument-info", req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	var result struct {
-		Msg  string           `json:"msg"`
-		Data []InstrumentInfo `json:"data"`
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return nil, newHTTPError(resp, data, path, codes)
 	}
 
+	var result rawResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
 	}
 
 	if result.Msg != "OK" {
-		return nil, fmt.Errorf("API error: %s", result.Msg)
+		return nil, newAPIError(resp.StatusCode, result.Msg, path, codes)
 	}
 
 	return result.Data, nil
 }
 
-// GetSnapshot 获取交易品种的实时行情快照
-func (c *QOSClient) GetSnapshot(codes []string) ([]Snapshot, error) {
-	req := struct {
-		Codes []string `json:"codes"`
-	}{
-		Codes: codes,
-	}
+// GetInstrumentInfo 获取交易品种的基础信息
+func (c *QOSClient) GetInstrumentInfo(codes []string) ([]InstrumentInfo, error) {
+	return c.GetInstrumentInfoContext(context.Background(), codes)
+}
 
-	resp, err := c.doRequest("POST", "/snapshot", req)
+// GetInstrumentInfoContext 获取交易品种的基础信息，ctx可用于超时和取消
+func (c *QOSClient) GetInstrumentInfoContext(ctx context.Context, codes []string) ([]InstrumentInfo, error) {
+	codes, err := NormalizeCodes(codes)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return Call[[]InstrumentInfo](ctx, c, "/instrument-info", codesRequest{Codes: codes})
+}
 
-	var result struct {
-		Msg  string     `json:"msg"`
-		Data []Snapshot `json:"data"`
-	}
+// GetSnapshot 获取交易品种的实时行情快照
+func (c *QOSClient) GetSnapshot(codes []string) ([]Snapshot, error) {
+	return c.GetSnapshotContext(context.Background(), codes)
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+// GetSnapshotContext 获取交易品种的实时行情快照，ctx可用于超时和取消
+func (c *QOSClient) GetSnapshotContext(ctx context.Context, codes []string) ([]Snapshot, error) {
+	codes, err := NormalizeCodes(codes)
+	if err != nil {
 		return nil, err
 	}
-
-	if result.Msg != "OK" {
-		return nil, fmt.Errorf("API error: %s", result.Msg)
-	}
-
-	return result.Data, nil
+	return Call[[]Snapshot](ctx, c, "/snapshot", codesRequest{Codes: codes})
 }
 
 // GetDepth 获取交易品种的实时最新盘口深度
 func (c *QOSClient) GetDepth(codes []string) ([]Depth, error) {
-	req := struct {
-		Codes []string `json:"codes"`
-	}{
-		Codes: codes,
-	}
+	return c.GetDepthContext(context.Background(), codes)
+}
 
-	resp, err := c.doRequest("POST", "/depth", req)
+// GetDepthContext 获取交易品种的实时最新盘口深度，ctx可用于超时和取消
+func (c *QOSClient) GetDepthContext(ctx context.Context, codes []string) ([]Depth, error) {
+	codes, err := NormalizeCodes(codes)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	var result struct {
-		Msg  string  `json:"msg"`
-		Data []Depth `json:"data"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-
-	if result.Msg != "OK" {
-		return nil, fmt.Errorf("API error: %s", result.Msg)
-	}
-
-	return result.Data, nil
+	return Call[[]Depth](ctx, c, "/depth", codesRequest{Codes: codes})
 }
 
 // GetTrade 获取交易品种的实时最新逐笔成交明细
 func (c *QOSClient) GetTrade(codes []string, count int) ([]Trade, error) {
-	req := struct {
-		Codes []string `json:"codes"`
-		Count int      `json:"count"`
-	}{
-		Codes: codes,
-		Count: count,
-	}
+	return c.GetTradeContext(context.Background(), codes, count)
+}
 
-	resp, err := c.doRequest("POST", "/trade", req)
+// GetTradeContext 获取交易品种的实时最新逐笔成交明细，ctx可用于超时和取消
+func (c *QOSClient) GetTradeContext(ctx context.Context, codes []string, count int) ([]Trade, error) {
+	codes, err := NormalizeCodes(codes)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	var result struct {
-		Msg  string  `json:"msg"`
-		Data []Trade `json:"data"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-
-	if result.Msg != "OK" {
-		return nil, fmt.Errorf("API error: %s", result.Msg)
-	}
-
-	return result.Data, nil
+	return Call[[]Trade](ctx, c, "/trade", tradeRequest{Codes: codes, Count: count})
 }
 
 // GetKLine 获取交易品种的K线
 func (c *QOSClient) GetKLine(requests []KLineRequest) ([][]KLine, error) {
-	req := struct {
-		KLineReqs []KLineRequest `json:"kline_reqs"`
-	}{
-		KLineReqs: requests,
-	}
+	return c.GetKLineContext(context.Background(), requests)
+}
 
-	resp, err := c.doRequest("POST", "/kline", req)
+// GetKLineContext 获取交易品种的K线，ctx可用于超时和取消
+func (c *QOSClient) GetKLineContext(ctx context.Context, requests []KLineRequest) ([][]KLine, error) {
+	requests, err := normalizeKLineRequests(requests)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	var result struct {
-		Msg  string `json:"msg"`
-		Data []struct {
-			Code string  `json:"c"`
-			K    []KLine `json:"k"`
-		} `json:"data"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	groups, err := Call[[]klineGroup](ctx, c, "/kline", klineRequest{KLineReqs: requests})
+	if err != nil {
 		return nil, err
 	}
-
-	if result.Msg != "OK" {
-		return nil, fmt.Errorf("API error: %s", result.Msg)
-	}
-
-	klineData := make([][]KLine, len(result.Data))
-	for i, item := range result.Data {
-		klineData[i] = item.K
-	}
-
-	return klineData, nil
+	return klineLines(groups), nil
 }
 
 // GetHistoryKLine 获取交易品种的历史K线
 func (c *QOSClient) GetHistoryKLine(requests []KLineRequest) ([][]KLine, error) {
-	req := struct {
-		KLineReqs []KLineRequest `json:"kline_reqs"`
-	}{
-		KLineReqs: requests,
-	}
+	return c.GetHistoryKLineContext(context.Background(), requests)
+}
 
-	resp, err := c.doRequest("POST", "/history", req)
+// GetHistoryKLineContext 获取交易品种的历史K线，ctx可用于超时和取消
+func (c *QOSClient) GetHistoryKLineContext(ctx context.Context, requests []KLineRequest) ([][]KLine, error) {
+	requests, err := normalizeKLineRequests(requests)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	var result struct {
-		Msg  string `json:"msg"`
-		Data []struct {
-			Code string  `json:"c"`
-			K    []KLine `json:"k"`
-		} `json:"data"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	groups, err := Call[[]klineGroup](ctx, c, "/history", klineRequest{KLineReqs: requests})
+	if err != nil {
 		return nil, err
 	}
-
-	if result.Msg != "OK" {
-		return nil, fmt.Errorf("API error: %s", result.Msg)
-	}
-
-	klineData := make([][]KLine, len(result.Data))
-	for i, item := range result.Data {
-		klineData[i] = item.K
-	}
-
-	return klineData, nil
+	return klineLines(groups), nil
 }
@@ -0,0 +1,399 @@
+package qosapi
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal 精确的定点十进制数，值为coef×10^-scale，用于价格和数量等字段，避免浮点误差。
+// 零值表示0，所有运算都返回新值，可安全地在goroutine之间共享
+type Decimal struct {
+	coef  *big.Int // 为nil时表示0
+	scale int32    // 小数位数，总是大于等于0
+}
+
+// 常用的Decimal值
+var (
+	DecimalZero = Decimal{}
+	DecimalOne  = NewDecimal(1, 0)
+)
+
+// NewDecimal 创建值为value×10^-scale的Decimal，如NewDecimal(12345, 2)表示123.45
+func NewDecimal(value int64, scale int32) Decimal {
+	if scale < 0 {
+		return Decimal{coef: new(big.Int).Mul(big.NewInt(value), pow10(-scale))}
+	}
+	return Decimal{coef: big.NewInt(value), scale: scale}
+}
+
+// NewDecimalFromInt 创建整数值的Decimal
+func NewDecimalFromInt(value int64) Decimal {
+	return NewDecimal(value, 0)
+}
+
+// NewDecimalFromFloat 将float64转换为Decimal，使用能精确还原该浮点数的最短十进制表示
+func NewDecimalFromFloat(value float64) Decimal {
+	d, err := ParseDecimal(strconv.FormatFloat(value, 'f', -1, 64))
+	if err != nil {
+		// NaN和Inf无法表示
+		return Decimal{}
+	}
+	return d
+}
+
+// maxDecimalScale 解析时允许的最大小数位数(或整数末尾0的个数)，避免超大指数导致内存耗尽
+const maxDecimalScale = 1 << 20
+
+// ParseDecimal 解析十进制字符串，支持符号、小数点和指数形式(如"-1.5e3")，空字符串解析为0。
+// 指数折算后的位数超过±2^20时返回错误
+func ParseDecimal(s string) (Decimal, error) {
+	str := strings.TrimSpace(s)
+	if str == "" {
+		return Decimal{}, nil
+	}
+
+	mantissa, expPart, hasExp := str, "", false
+	if i := strings.IndexAny(str, "eE"); i >= 0 {
+		mantissa, expPart, hasExp = str[:i], str[i+1:], true
+	}
+
+	neg := false
+	switch {
+	case strings.HasPrefix(mantissa, "-"):
+		neg = true
+		mantissa = mantissa[1:]
+	case strings.HasPrefix(mantissa, "+"):
+		mantissa = mantissa[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(mantissa, ".")
+	digits := intPart + fracPart
+	if digits == "" || strings.ContainsFunc(digits, func(r rune) bool { return r < '0' || r > '9' }) {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	coef, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	if neg {
+		coef.Neg(coef)
+	}
+
+	scale := int64(len(fracPart))
+	if hasExp {
+		exp, err := strconv.ParseInt(expPart, 10, 32)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+		}
+		scale -= exp
+	}
+	if scale > maxDecimalScale || scale < -maxDecimalScale {
+		return Decimal{}, fmt.Errorf("invalid decimal %q: exponent out of range", s)
+	}
+	if scale < 0 {
+		coef.Mul(coef, pow10(int32(-scale)))
+		scale = 0
+	}
+	return Decimal{coef: coef, scale: int32(scale)}, nil
+}
+
+// MustParseDecimal 解析十进制字符串，失败时panic，用于常量初始化
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// pow10 返回10^n
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// bigInt 返回系数，零值返回新的0
+func (d Decimal) bigInt() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// rescale 返回按scale表示的系数，scale必须大于等于d.scale
+func (d Decimal) rescale(scale int32) *big.Int {
+	if scale == d.scale {
+		return d.bigInt()
+	}
+	return new(big.Int).Mul(d.bigInt(), pow10(scale-d.scale))
+}
+
+// Scale 返回小数位数
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Sign 返回符号：-1、0或1
+func (d Decimal) Sign() int {
+	if d.coef == nil {
+		return 0
+	}
+	return d.coef.Sign()
+}
+
+// IsZero 是否为0
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// IsNegative 是否小于0
+func (d Decimal) IsNegative() bool {
+	return d.Sign() < 0
+}
+
+// IsPositive 是否大于0
+func (d Decimal) IsPositive() bool {
+	return d.Sign() > 0
+}
+
+// Neg 返回-d
+func (d Decimal) Neg() Decimal {
+	return Decimal{coef: new(big.Int).Neg(d.bigInt()), scale: d.scale}
+}
+
+// Abs 返回|d|
+func (d Decimal) Abs() Decimal {
+	return Decimal{coef: new(big.Int).Abs(d.bigInt()), scale: d.scale}
+}
+
+// Add 返回d+d2
+func (d Decimal) Add(d2 Decimal) Decimal {
+	scale := max(d.scale, d2.scale)
+	return Decimal{coef: new(big.Int).Add(d.rescale(scale), d2.rescale(scale)), scale: scale}
+}
+
+// Sub 返回d-d2
+func (d Decimal) Sub(d2 Decimal) Decimal {
+	scale := max(d.scale, d2.scale)
+	return Decimal{coef: new(big.Int).Sub(d.rescale(scale), d2.rescale(scale)), scale: scale}
+}
+
+// Mul 返回d×d2，结果的小数位数为两者之和
+func (d Decimal) Mul(d2 Decimal) Decimal {
+	return Decimal{coef: new(big.Int).Mul(d.bigInt(), d2.bigInt()), scale: d.scale + d2.scale}
+}
+
+// Div 返回d÷d2，结果保留scale位小数并四舍五入，d2为0时panic
+func (d Decimal) Div(d2 Decimal, scale int32) Decimal {
+	if d2.IsZero() {
+		panic("qosapi: decimal division by zero")
+	}
+	num, den := d.bigInt(), d2.bigInt()
+	if e := scale + d2.scale - d.scale; e >= 0 {
+		num = new(big.Int).Mul(num, pow10(e))
+	} else {
+		den = new(big.Int).Mul(den, pow10(-e))
+	}
+	return Decimal{coef: divRound(num, den), scale: scale}
+}
+
+// divRound 整数除法，四舍五入(远离0)
+func divRound(num, den *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	r2 := new(big.Int).Abs(r)
+	r2.Lsh(r2, 1)
+	if r2.Cmp(new(big.Int).Abs(den)) >= 0 {
+		if num.Sign()*den.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+// Cmp 比较d和d2，d<d2返回-1，相等返回0，d>d2返回1
+func (d Decimal) Cmp(d2 Decimal) int {
+	scale := max(d.scale, d2.scale)
+	return d.rescale(scale).Cmp(d2.rescale(scale))
+}
+
+// Equal 数值是否相等，"1.50"与"1.5"相等
+func (d Decimal) Equal(d2 Decimal) bool {
+	return d.Cmp(d2) == 0
+}
+
+// LessThan 是否d<d2
+func (d Decimal) LessThan(d2 Decimal) bool {
+	return d.Cmp(d2) < 0
+}
+
+// GreaterThan 是否d>d2
+func (d Decimal) GreaterThan(d2 Decimal) bool {
+	return d.Cmp(d2) > 0
+}
+
+// MinDecimal 返回较小值
+func MinDecimal(first Decimal, rest ...Decimal) Decimal {
+	result := first
+	for _, d := range rest {
+		if d.LessThan(result) {
+			result = d
+		}
+	}
+	return result
+}
+
+// MaxDecimal 返回较大值
+func MaxDecimal(first Decimal, rest ...Decimal) Decimal {
+	result := first
+	for _, d := range rest {
+		if d.GreaterThan(result) {
+			result = d
+		}
+	}
+	return result
+}
+
+// Round 四舍五入(远离0)保留places位小数，places不小于当前小数位数时原样返回
+func (d Decimal) Round(places int32) Decimal {
+	if places < 0 {
+		places = 0
+	}
+	if places >= d.scale {
+		return d
+	}
+	return Decimal{coef: divRound(d.bigInt(), pow10(d.scale-places)), scale: places}
+}
+
+// Truncate 向0截断保留places位小数
+func (d Decimal) Truncate(places int32) Decimal {
+	if places < 0 {
+		places = 0
+	}
+	if places >= d.scale {
+		return d
+	}
+	return Decimal{coef: new(big.Int).Quo(d.bigInt(), pow10(d.scale-places)), scale: places}
+}
+
+// Floor 向下取整保留places位小数
+func (d Decimal) Floor(places int32) Decimal {
+	t := d.Truncate(places)
+	if d.IsNegative() && !t.Equal(d) {
+		t = t.Sub(NewDecimal(1, t.scale))
+	}
+	return t
+}
+
+// Ceil 向上取整保留places位小数
+func (d Decimal) Ceil(places int32) Decimal {
+	t := d.Truncate(places)
+	if d.IsPositive() && !t.Equal(d) {
+		t = t.Add(NewDecimal(1, t.scale))
+	}
+	return t
+}
+
+// Normalize 去除小数部分末尾的0，如"1.500"变为"1.5"
+func (d Decimal) Normalize() Decimal {
+	if d.IsZero() {
+		return Decimal{}
+	}
+	coef := new(big.Int).Set(d.coef)
+	scale := d.scale
+	ten := big.NewInt(10)
+	q, r := new(big.Int), new(big.Int)
+	for scale > 0 {
+		q.QuoRem(coef, ten, r)
+		if r.Sign() != 0 {
+			break
+		}
+		coef.Set(q)
+		scale--
+	}
+	return Decimal{coef: coef, scale: scale}
+}
+
+// String 返回十进制字符串，保留原有的小数位数，如"150.2300"
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.bigInt()).String()
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		point := len(digits) - int(d.scale)
+		digits = digits[:point] + "." + digits[point:]
+	}
+	if d.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// StringFixed 四舍五入保留places位小数并格式化，不足时补0，如StringFixed(2)得到"1.50"
+func (d Decimal) StringFixed(places int32) string {
+	r := d.Round(places)
+	if r.scale < places {
+		r = Decimal{coef: r.rescale(places), scale: places}
+	}
+	return r.String()
+}
+
+// Float64 转换为float64，可能损失精度
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// IntPart 返回整数部分，超出int64范围时结果未定义
+func (d Decimal) IntPart() int64 {
+	return d.Truncate(0).bigInt().Int64()
+}
+
+// MarshalJSON 序列化为带引号的字符串，与接口返回格式一致
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// UnmarshalJSON 支持字符串、数字和null
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*d = Decimal{}
+		return nil
+	}
+	str := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		var err error
+		if str, err = strconv.Unquote(str); err != nil {
+			return fmt.Errorf("invalid decimal %s", data)
+		}
+	}
+	v, err := ParseDecimal(str)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// MarshalText 实现encoding.TextMarshaler
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText 实现encoding.TextUnmarshaler
+func (d *Decimal) UnmarshalText(text []byte) error {
+	v, err := ParseDecimal(string(text))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
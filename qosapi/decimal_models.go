@@ -0,0 +1,258 @@
+package qosapi
+
+import "fmt"
+
+// 以下类型与models.go中的数据结构一一对应，价格和数量字段为Decimal。
+// JSON标签与接口一致，既可以通过各类型的Decimal方法转换得到，也可以直接反序列化接口数据
+
+// 行情快照(Decimal)
+type SnapshotDecimal struct {
+	Code             string               `json:"c"`  // 股票代码
+	LastPrice        Decimal              `json:"lp"` // 当前价格
+	PrevClose        Decimal              `json:"yp"` // 昨日收盘价
+	Open             Decimal              `json:"o"`  // 开盘价
+	High             Decimal              `json:"h"`  // 最高价
+	Low              Decimal              `json:"l"`  // 最低价
+	Timestamp        int64                `json:"ts"` // 时间戳
+	Volume           Decimal              `json:"v"`  // 成交量
+	Turnover         Decimal              `json:"t"`  // 成交金额
+	Suspended        int                  `json:"s"`  // 是否停牌
+	PreMarket        *SessionQuoteDecimal `json:"pq"` // 盘前数据
+	AfterMarket      *SessionQuoteDecimal `json:"aq"` // 盘后数据
+	NightMarket      *SessionQuoteDecimal `json:"nq"` // 夜盘数据
+	TradeSessionType int                  `json:"tt"` // 交易时段类型
+}
+
+// 交易时段行情(Decimal)
+type SessionQuoteDecimal struct {
+	LastPrice Decimal `json:"lp"` // 当前价格
+	PrevClose Decimal `json:"yp"` // 上次收盘价
+	High      Decimal `json:"h"`  // 最高价
+	Low       Decimal `json:"l"`  // 最低价
+	Timestamp int64   `json:"ts"` // 时间戳
+	Volume    Decimal `json:"v"`  // 成交量
+	Turnover  Decimal `json:"t"`  // 成交金额
+}
+
+// 盘口深度(Decimal)
+type DepthDecimal struct {
+	Code      string             `json:"c"`  // 股票代码
+	Bids      []DepthItemDecimal `json:"b"`  // 买单数组
+	Asks      []DepthItemDecimal `json:"a"`  // 卖单数组
+	Timestamp int64              `json:"ts"` // 时间戳
+}
+
+// 盘口项(Decimal)
+type DepthItemDecimal struct {
+	Price  Decimal `json:"p"` // 价格
+	Volume Decimal `json:"v"` // 数量
+}
+
+// 逐笔成交(Decimal)
+type TradeDecimal struct {
+	Code      string  `json:"c"`  // 股票代码
+	Price     Decimal `json:"p"`  // 当前价格
+	Volume    Decimal `json:"v"`  // 当前成交量
+	Timestamp int64   `json:"ts"` // 时间戳
+	Direction int     `json:"d"`  // 交易方向
+}
+
+// K线数据(Decimal)
+type KLineDecimal struct {
+	Code      string  `json:"c"`  // 股票代码
+	Open      Decimal `json:"o"`  // 开盘价
+	Close     Decimal `json:"cl"` // 收盘价
+	High      Decimal `json:"h"`  // 最高价
+	Low       Decimal `json:"l"`  // 最低价
+	Volume    Decimal `json:"v"`  // 成交量
+	Timestamp int64   `json:"ts"` // 时间戳
+	KLineType int     `json:"kt"` // K线类型
+}
+
+// decimalParser 依次解析多个字段，记录第一个错误
+type decimalParser struct {
+	err error
+}
+
+func (p *decimalParser) parse(field, s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("%s: %w", field, err)
+	}
+	return d
+}
+
+// Decimal 将价格和数量字段解析为Decimal
+func (s Snapshot) Decimal() (SnapshotDecimal, error) {
+	var p decimalParser
+	result := SnapshotDecimal{
+		Code:             s.Code,
+		LastPrice:        p.parse("LastPrice", s.LastPrice),
+		PrevClose:        p.parse("PrevClose", s.PrevClose),
+		Open:             p.parse("Open", s.Open),
+		High:             p.parse("High", s.High),
+		Low:              p.parse("Low", s.Low),
+		Timestamp:        s.Timestamp,
+		Volume:           p.parse("Volume", s.Volume),
+		Turnover:         p.parse("Turnover", s.Turnover),
+		Suspended:        s.Suspended,
+		TradeSessionType: s.TradeSessionType,
+	}
+	for _, item := range []struct {
+		name string
+		src  *SessionQuote
+		dst  **SessionQuoteDecimal
+	}{
+		{"PreMarket", s.PreMarket, &result.PreMarket},
+		{"AfterMarket", s.AfterMarket, &result.AfterMarket},
+		{"NightMarket", s.NightMarket, &result.NightMarket},
+	} {
+		if item.src == nil {
+			continue
+		}
+		q, err := item.src.Decimal()
+		if err != nil && p.err == nil {
+			p.err = fmt.Errorf("%s.%w", item.name, err)
+		}
+		*item.dst = &q
+	}
+	return result, p.err
+}
+
+// Decimal 将价格和数量字段解析为Decimal
+func (s SessionQuote) Decimal() (SessionQuoteDecimal, error) {
+	var p decimalParser
+	result := SessionQuoteDecimal{
+		LastPrice: p.parse("LastPrice", s.LastPrice),
+		PrevClose: p.parse("PrevClose", s.PrevClose),
+		High:      p.parse("High", s.High),
+		Low:       p.parse("Low", s.Low),
+		Timestamp: s.Timestamp,
+		Volume:    p.parse("Volume", s.Volume),
+		Turnover:  p.parse("Turnover", s.Turnover),
+	}
+	return result, p.err
+}
+
+// Decimal 将价格和数量字段解析为Decimal
+func (s WSSnapshot) Decimal() (SnapshotDecimal, error) {
+	var p decimalParser
+	result := SnapshotDecimal{
+		Code:             s.Code,
+		LastPrice:        p.parse("LastPrice", s.LastPrice),
+		PrevClose:        p.parse("PrevClose", s.PrevClose),
+		Open:             p.parse("Open", s.Open),
+		High:             p.parse("High", s.High),
+		Low:              p.parse("Low", s.Low),
+		Timestamp:        s.Timestamp,
+		Volume:           p.parse("Volume", s.Volume),
+		Turnover:         p.parse("Turnover", s.Turnover),
+		Suspended:        s.Suspended,
+		TradeSessionType: s.TradeSessionType,
+	}
+	return result, p.err
+}
+
+// Decimal 将价格和数量字段解析为Decimal
+func (d DepthItem) Decimal() (DepthItemDecimal, error) {
+	var p decimalParser
+	result := DepthItemDecimal{
+		Price:  p.parse("Price", d.Price),
+		Volume: p.parse("Volume", d.Volume),
+	}
+	return result, p.err
+}
+
+// depthItemsDecimal 解析盘口档位
+func depthItemsDecimal(side string, items []DepthItem) ([]DepthItemDecimal, error) {
+	result := make([]DepthItemDecimal, len(items))
+	for i, item := range items {
+		d, err := item.Decimal()
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d].%w", side, i, err)
+		}
+		result[i] = d
+	}
+	return result, nil
+}
+
+// Decimal 将价格和数量字段解析为Decimal
+func (d Depth) Decimal() (DepthDecimal, error) {
+	return newDepthDecimal(d.Code, d.Bids, d.Asks, d.Timestamp)
+}
+
+// Decimal 将价格和数量字段解析为Decimal
+func (d WSDepth) Decimal() (DepthDecimal, error) {
+	return newDepthDecimal(d.Code, d.Bids, d.Asks, d.Timestamp)
+}
+
+func newDepthDecimal(code string, bids, asks []DepthItem, ts int64) (DepthDecimal, error) {
+	result := DepthDecimal{Code: code, Timestamp: ts}
+	var err error
+	if result.Bids, err = depthItemsDecimal("Bids", bids); err != nil {
+		return DepthDecimal{}, err
+	}
+	if result.Asks, err = depthItemsDecimal("Asks", asks); err != nil {
+		return DepthDecimal{}, err
+	}
+	return result, nil
+}
+
+// Decimal 将价格和数量字段解析为Decimal
+func (t Trade) Decimal() (TradeDecimal, error) {
+	var p decimalParser
+	result := TradeDecimal{
+		Code:      t.Code,
+		Price:     p.parse("Price", t.Price),
+		Volume:    p.parse("Volume", t.Volume),
+		Timestamp: t.Timestamp,
+		Direction: t.Direction,
+	}
+	return result, p.err
+}
+
+// Decimal 将价格和数量字段解析为Decimal
+func (t WSTrade) Decimal() (TradeDecimal, error) {
+	return Trade{
+		Code:      t.Code,
+		Price:     t.Price,
+		Volume:    t.Volume,
+		Timestamp: t.Timestamp,
+		Direction: t.Direction,
+	}.Decimal()
+}
+
+// Decimal 将价格和数量字段解析为Decimal
+func (k KLine) Decimal() (KLineDecimal, error) {
+	var p decimalParser
+	result := KLineDecimal{
+		Code:      k.Code,
+		Open:      p.parse("Open", k.Open),
+		Close:     p.parse("Close", k.Close),
+		High:      p.parse("High", k.High),
+		Low:       p.parse("Low", k.Low),
+		Volume:    p.parse("Volume", k.Volume),
+		Timestamp: k.Timestamp,
+		KLineType: k.KLineType,
+	}
+	return result, p.err
+}
+
+// Decimal 将价格和数量字段解析为Decimal
+func (k WSKLine) Decimal() (KLineDecimal, error) {
+	return k.KLine().Decimal()
+}
+
+// KLine 转换回接口使用的字符串格式
+func (k KLineDecimal) KLine() KLine {
+	return KLine{
+		Code:      k.Code,
+		Open:      k.Open.String(),
+		Close:     k.Close.String(),
+		High:      k.High.String(),
+		Low:       k.Low.String(),
+		Volume:    k.Volume.String(),
+		Timestamp: k.Timestamp,
+		KLineType: k.KLineType,
+	}
+}
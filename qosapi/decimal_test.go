@@ -0,0 +1,89 @@
+package qosapi_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "", want: "0"},
+		{in: "123.45", want: "123.45"},
+		{in: " -1.5e3 ", want: "-1500"},
+		{in: "1E2", want: "100"},
+		{in: "2.5e-1", want: "0.25"},
+		{in: "1e-5", want: "0.00001"},
+		{in: "-0.0", want: "0.0"},
+		{in: "+.5", want: "0.5"},
+		{in: "5.", want: "5"},
+		{in: "1e+3", want: "1000"},
+		{in: "1e1048576", want: "1" + strings.Repeat("0", 1<<20)},
+		{in: "1e-1048576", want: "0." + strings.Repeat("0", 1<<20-1) + "1"},
+		{in: ".", wantErr: true},
+		{in: "1e", wantErr: true},
+		{in: "e5", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "1,5", wantErr: true},
+		{in: "--1", wantErr: true},
+		{in: "1e5e3", wantErr: true},
+		{in: "NaN", wantErr: true},
+		{in: "1e1048577", wantErr: true},
+		{in: "1e-1048577", wantErr: true},
+		{in: "1e999999999", wantErr: true},
+		{in: "1e-999999999", wantErr: true},
+		{in: "1e99999999999", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := qosapi.ParseDecimal(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseDecimal(%q) = %s, want error", tt.in, shorten(got.String()))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDecimal(%q): %v", tt.in, err)
+			continue
+		}
+		if s := got.String(); s != tt.want {
+			t.Errorf("ParseDecimal(%q) = %s, want %s", tt.in, shorten(s), shorten(tt.want))
+		}
+	}
+}
+
+func TestParseDecimalNegativeZero(t *testing.T) {
+	d, err := qosapi.ParseDecimal("-0.0")
+	if err != nil {
+		t.Fatalf("ParseDecimal: %v", err)
+	}
+	if !d.IsZero() || d.IsNegative() || !d.Equal(qosapi.DecimalZero) {
+		t.Fatalf("ParseDecimal(-0.0) = %s, want zero without sign", d)
+	}
+}
+
+func TestDecimalJSONRoundTrip(t *testing.T) {
+	var d qosapi.Decimal
+	for _, in := range []string{`"1.50"`, `1.5`, `null`, `"-2e-3"`} {
+		if err := d.UnmarshalJSON([]byte(in)); err != nil {
+			t.Fatalf("UnmarshalJSON(%s): %v", in, err)
+		}
+	}
+	if err := d.UnmarshalJSON([]byte(`"1e99999999"`)); err == nil {
+		t.Fatal("UnmarshalJSON accepted an out-of-range exponent")
+	}
+}
+
+// shorten 截断过长的字符串，避免失败信息刷屏
+func shorten(s string) string {
+	if len(s) > 40 {
+		return s[:20] + "..." + s[len(s)-20:]
+	}
+	return s
+}
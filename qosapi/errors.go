@@ -0,0 +1,134 @@
+package qosapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 错误分类，可配合errors.Is使用
+var (
+	ErrAuthFailed  = errors.New("authentication failed") // API Key无效或无权限
+	ErrRateLimited = errors.New("rate limited")          // 请求过于频繁
+	ErrInvalidCode = errors.New("invalid code")          // 品种代码无效
+	ErrServerError = errors.New("server error")          // 服务端错误
+)
+
+// WebSocket连接相关错误
+var (
+	ErrNotConnected   = errors.New("WebSocket not connected")   // 未连接或正在重连
+	ErrConnectionLost = errors.New("WebSocket connection lost") // 等待响应期间连接断开，或数据流因断线且不再重连而结束
+	ErrClientClosed   = errors.New("WebSocket client closed")   // 等待响应期间或数据流存续期间客户端被关闭
+)
+
+// APIError QOS API返回的错误
+type APIError struct {
+	StatusCode int           // HTTP状态码，WebSocket请求为0
+	Msg        string        // 服务端返回的原始msg
+	Path       string        // HTTP请求路径或WebSocket请求类型
+	Codes      []string      // 请求的品种代码
+	Retryable  bool          // 是否可以重试
+	RetryAfter time.Duration // 服务端通过Retry-After要求的等待时间
+	Err        error         // 错误分类，为上面的哨兵错误之一或nil
+}
+
+// Error 实现error接口
+func (e *APIError) Error() string {
+	var details []string
+	if e.StatusCode != 0 {
+		details = append(details, fmt.Sprintf("status %d", e.StatusCode))
+	}
+	if e.Path != "" {
+		details = append(details, e.Path)
+	}
+	if len(details) == 0 {
+		return "API error: " + e.Msg
+	}
+	return fmt.Sprintf("API error: %s (%s)", e.Msg, strings.Join(details, ", "))
+}
+
+// Unwrap 返回错误分类，使errors.Is(err, ErrRateLimited)等判断生效
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// newAPIError 根据HTTP状态码和服务端msg创建APIError
+func newAPIError(statusCode int, msg, path string, codes []string) *APIError {
+	e := &APIError{
+		StatusCode: statusCode,
+		Msg:        msg,
+		Path:       path,
+		Codes:      codes,
+	}
+	e.Err, e.Retryable = classifyError(statusCode, msg)
+	return e
+}
+
+// newHTTPError 根据非2xx的HTTP响应创建APIError
+func newHTTPError(resp *http.Response, body []byte, path string, codes []string) *APIError {
+	var result struct {
+		Msg string `json:"msg"`
+	}
+	msg := ""
+	if err := json.Unmarshal(body, &result); err == nil {
+		msg = result.Msg
+	}
+	if msg == "" {
+		msg = strings.TrimSpace(string(body))
+	}
+	if msg == "" {
+		msg = http.StatusText(resp.StatusCode)
+	}
+	apiErr := newAPIError(resp.StatusCode, msg, path, codes)
+	apiErr.RetryAfter = parseRetryAfter(resp.Header)
+	return apiErr
+}
+
+// classifyError 根据HTTP状态码和msg判断错误分类及是否可重试
+func classifyError(statusCode int, msg string) (error, bool) {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrAuthFailed, false
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited, true
+	case statusCode >= 500:
+		return ErrServerError, true
+	}
+
+	m := strings.ToLower(msg)
+	switch {
+	case containsAny(m, "limit", "too many", "frequen", "频繁", "限流"):
+		return ErrRateLimited, true
+	case containsAny(m, "key", "auth", "permission", "forbidden", "权限", "密钥"):
+		return ErrAuthFailed, false
+	case containsAny(m, "code", "symbol", "代码"):
+		return ErrInvalidCode, false
+	case containsAny(m, "internal", "server", "timeout", "服务", "超时"):
+		return ErrServerError, true
+	}
+	return nil, false
+}
+
+func containsAny(s string, subs ...string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestCodes 提取请求中涉及的品种代码
+func requestCodes(codes []string, klineReqs []KLineRequest) []string {
+	if len(klineReqs) == 0 {
+		return codes
+	}
+	result := append([]string(nil), codes...)
+	for _, r := range klineReqs {
+		result = append(result, r.Codes)
+	}
+	return result
+}
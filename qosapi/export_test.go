@@ -0,0 +1,15 @@
+package qosapi
+
+import "time"
+
+// LockCount 返回KLineCache中仍保留的键锁数量，仅用于测试
+func (c *KLineCache) LockCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.locks)
+}
+
+// Bucket 返回时间K线的开始和结束时间，仅用于测试
+func (s BarSpec) Bucket(ts int64, loc *time.Location, sessions []Session) (start, end int64) {
+	return s.bucket(ts, loc, sessions)
+}
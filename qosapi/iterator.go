@@ -0,0 +1,152 @@
+package qosapi
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"time"
+)
+
+// DefaultHistoryPageSize 分页获取历史K线时每页的默认数量
+const DefaultHistoryPageSize = 1000
+
+// HistoryFunc 获取一页历史K线，QOSClient.GetHistoryKLineContext和
+// WSClient.RequestHistoryKLineContext都可以作为HistoryFunc使用
+type HistoryFunc func(ctx context.Context, requests []KLineRequest) ([][]KLine, error)
+
+// HistoryQuery 分页获取历史K线的查询条件
+type HistoryQuery struct {
+	Code      string // 股票代码，只能是单个代码
+	KLineType int    // K线类型
+	Adjust    int    // 复权类型 0:不复权 1:前复权
+	Start     int64  // 起始时间戳(含)，0表示一直获取到没有更早的数据
+	End       int64  // 结束时间戳(含)，0表示从最新的K线开始
+	PageSize  int    // 每页数量，小于等于0时使用DefaultHistoryPageSize
+
+	// PageDelay 两页请求之间的等待时间，用于降低请求频率
+	PageDelay time.Duration
+
+	// Retry 单页请求失败时在迭代器中的重试策略，为nil时不重试，只依赖fetch自身的重试
+	// (如QOSClient的RetryPolicy)。fetch本身会重试时不要再设置，否则两层的尝试次数相乘
+	Retry *RetryPolicy
+}
+
+// HistoryKLines 从End开始按EndTime向前分页获取历史K线，直到Start或没有更早的数据。
+// K线按时间从新到旧依次产出，相邻两页边界上重复的K线只产出一次。
+// 出错时产出错误并结束，调用方提前退出循环时不再请求后续页面：
+//
+//	for k, err := range qosapi.HistoryKLines(ctx, client.GetHistoryKLineContext, q) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func HistoryKLines(ctx context.Context, fetch HistoryFunc, q HistoryQuery) iter.Seq2[KLine, error] {
+	return func(yield func(KLine, error) bool) {
+		if _, err := ParseSymbol(q.Code); err != nil {
+			yield(KLine{}, err)
+			return
+		}
+		pageSize := q.PageSize
+		if pageSize <= 0 {
+			pageSize = DefaultHistoryPageSize
+		}
+		end := q.End
+		last := int64(-1) // 已产出的最早K线的时间戳，-1表示尚未产出
+		for page := 0; ; page++ {
+			if page > 0 && q.PageDelay > 0 {
+				if err := sleepContext(ctx, q.PageDelay); err != nil {
+					yield(KLine{}, err)
+					return
+				}
+			}
+
+			req := KLineRequest{Codes: q.Code, Count: pageSize, Adjust: q.Adjust, KLineType: q.KLineType, EndTime: end}
+			klines, err := fetchHistoryPage(ctx, fetch, req, q.Retry)
+			if err != nil {
+				yield(KLine{}, err)
+				return
+			}
+
+			// 按时间从新到旧产出，跳过与上一页重复的K线
+			slices.SortFunc(klines, func(a, b KLine) int { return cmp.Compare(b.Timestamp, a.Timestamp) })
+			progressed := false
+			for _, k := range klines {
+				if last >= 0 && k.Timestamp >= last {
+					continue
+				}
+				if k.Timestamp < q.Start {
+					return
+				}
+				progressed = true
+				last = k.Timestamp
+				if !yield(k, nil) {
+					return
+				}
+			}
+			if !progressed {
+				return
+			}
+			end = last
+		}
+	}
+}
+
+// fetchHistoryPage 获取一页K线，policy不为nil时按该策略重试
+func fetchHistoryPage(ctx context.Context, fetch HistoryFunc, req KLineRequest, policy *RetryPolicy) ([]KLine, error) {
+	for attempt := 1; ; attempt++ {
+		groups, err := fetch(ctx, []KLineRequest{req})
+		if err == nil {
+			if len(groups) == 0 {
+				return nil, nil
+			}
+			return groups[0], nil
+		}
+		if policy == nil || attempt >= policy.MaxAttempts || !policy.shouldRetry(err) {
+			return nil, fmt.Errorf("history %s before %d: %w", req.Codes, req.EndTime, err)
+		}
+		if err := sleepContext(ctx, policy.delay(attempt, err)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// CollectHistoryKLines 获取HistoryKLines产出的全部K线，按时间从旧到新排列
+func CollectHistoryKLines(ctx context.Context, fetch HistoryFunc, q HistoryQuery) ([]KLine, error) {
+	var result []KLine
+	for k, err := range HistoryKLines(ctx, fetch, q) {
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, k)
+	}
+	slices.Reverse(result)
+	return result, nil
+}
+
+// HistoryKLines 通过HTTP接口分页获取历史K线，参见包级函数HistoryKLines
+func (c *QOSClient) HistoryKLines(ctx context.Context, q HistoryQuery) iter.Seq2[KLine, error] {
+	return HistoryKLines(ctx, c.GetHistoryKLineContext, q)
+}
+
+// HistoryKLines 通过WebSocket分页获取历史K线，参见包级函数HistoryKLines。
+// 每页请求的超时时间由SetRequestTimeout设置
+func (c *WSClient) HistoryKLines(ctx context.Context, q HistoryQuery) iter.Seq2[KLine, error] {
+	return HistoryKLines(ctx, c.requestHistoryPage, q)
+}
+
+// requestHistoryPage 在请求超时时间内获取一页历史K线
+func (c *WSClient) requestHistoryPage(ctx context.Context, requests []KLineRequest) ([][]KLine, error) {
+	c.mu.Lock()
+	timeout := c.requestTimeout
+	c.mu.Unlock()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return c.RequestHistoryKLineContext(ctx, requests)
+}
@@ -0,0 +1,142 @@
+package qosapi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+	"github.com/qos-max/qos-quote-api-go-sdk/qostest"
+)
+
+// minuteKLines 生成从ts开始的n根1分钟K线
+func minuteKLines(code string, ts int64, n int) []qosapi.KLine {
+	klines := make([]qosapi.KLine, n)
+	for i := range klines {
+		klines[i] = qosapi.KLine{Code: code, Timestamp: ts + int64(i)*60, KLineType: qosapi.KLineTypeMin1}
+	}
+	return klines
+}
+
+func TestHistoryKLinesPaginates(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	const base = 1700000000
+	srv.SetKLines("US:AAPL", qosapi.KLineTypeMin1, minuteKLines("US:AAPL", base, 250)...)
+	client := srv.Client()
+
+	q := qosapi.HistoryQuery{Code: "US:AAPL", KLineType: qosapi.KLineTypeMin1, PageSize: 100}
+	var got []int64
+	for k, err := range qosapi.HistoryKLines(context.Background(), client.GetHistoryKLineContext, q) {
+		if err != nil {
+			t.Fatalf("HistoryKLines: %v", err)
+		}
+		got = append(got, k.Timestamp)
+	}
+	if len(got) != 250 {
+		t.Fatalf("got %d klines, want 250", len(got))
+	}
+	for i, ts := range got {
+		if want := int64(base + (249-i)*60); ts != want {
+			t.Fatalf("kline %d at %d, want %d (newest first, no duplicates)", i, ts, want)
+		}
+	}
+	// 3页数据，加上确认没有更早数据的最后一页
+	if n := srv.RequestCount("/history"); n != 4 {
+		t.Fatalf("server saw %d /history requests, want 4", n)
+	}
+}
+
+func TestHistoryKLinesStopsAtStart(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	const base = 1700000000
+	srv.SetKLines("US:AAPL", qosapi.KLineTypeMin1, minuteKLines("US:AAPL", base, 250)...)
+	client := srv.Client()
+
+	q := qosapi.HistoryQuery{
+		Code:      "US:AAPL",
+		KLineType: qosapi.KLineTypeMin1,
+		Start:     base + 200*60,
+		End:       base + 229*60,
+		PageSize:  20,
+	}
+	klines, err := qosapi.CollectHistoryKLines(context.Background(), client.GetHistoryKLineContext, q)
+	if err != nil {
+		t.Fatalf("CollectHistoryKLines: %v", err)
+	}
+	if len(klines) != 30 || klines[0].Timestamp != q.Start || klines[29].Timestamp != q.End {
+		t.Fatalf("got %d klines from %d to %d, want 30 from %d to %d",
+			len(klines), klines[0].Timestamp, klines[len(klines)-1].Timestamp, q.Start, q.End)
+	}
+	if n := srv.RequestCount("/history"); n != 2 {
+		t.Fatalf("server saw %d /history requests, want 2", n)
+	}
+}
+
+func TestHistoryKLinesEarlyBreak(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	srv.SetKLines("US:AAPL", qosapi.KLineTypeMin1, minuteKLines("US:AAPL", 1700000000, 250)...)
+	client := srv.Client()
+
+	q := qosapi.HistoryQuery{Code: "US:AAPL", KLineType: qosapi.KLineTypeMin1, PageSize: 100}
+	n := 0
+	for _, err := range qosapi.HistoryKLines(context.Background(), client.GetHistoryKLineContext, q) {
+		if err != nil {
+			t.Fatalf("HistoryKLines: %v", err)
+		}
+		if n++; n == 10 {
+			break
+		}
+	}
+	if n := srv.RequestCount("/history"); n != 1 {
+		t.Fatalf("server saw %d /history requests, want 1", n)
+	}
+}
+
+func TestHistoryKLinesUsesOnlyTheClientRetryPolicy(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	srv.SetKLines("US:AAPL", qosapi.KLineTypeMin1, minuteKLines("US:AAPL", 1700000000, 10)...)
+	srv.InjectError("/history", http.StatusTooManyRequests, "too many requests", 0)
+
+	policy := &qosapi.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	client := srv.Client(qosapi.WithRetryPolicy(policy))
+	q := qosapi.HistoryQuery{Code: "US:AAPL", KLineType: qosapi.KLineTypeMin1}
+
+	_, err := qosapi.CollectHistoryKLines(context.Background(), client.GetHistoryKLineContext, q)
+	if !errors.Is(err, qosapi.ErrRateLimited) {
+		t.Fatalf("error = %v, want ErrRateLimited", err)
+	}
+	if n := srv.RequestCount("/history"); n != 3 {
+		t.Fatalf("server saw %d /history requests, want 3 (client policy only)", n)
+	}
+}
+
+func TestHistoryKLinesQueryRetry(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	srv.SetKLines("US:AAPL", qosapi.KLineTypeMin1, minuteKLines("US:AAPL", 1700000000, 10)...)
+	srv.InjectError("/history", http.StatusServiceUnavailable, "server busy", 2)
+
+	client := srv.Client(qosapi.WithRetryPolicy(nil))
+	q := qosapi.HistoryQuery{
+		Code:      "US:AAPL",
+		KLineType: qosapi.KLineTypeMin1,
+		Retry:     &qosapi.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+	}
+	klines, err := qosapi.CollectHistoryKLines(context.Background(), client.GetHistoryKLineContext, q)
+	if err != nil {
+		t.Fatalf("CollectHistoryKLines: %v", err)
+	}
+	if len(klines) != 10 {
+		t.Fatalf("got %d klines, want 10", len(klines))
+	}
+	// 2次失败+第1页成功+确认没有更早数据的1页
+	if n := srv.RequestCount("/history"); n != 4 {
+		t.Fatalf("server saw %d /history requests, want 4", n)
+	}
+}
@@ -0,0 +1,110 @@
+package qosapi
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// marketZones 各市场所在时区
+var marketZones = map[string]string{
+	MarketUS: "America/New_York",
+	MarketHK: "Asia/Hong_Kong",
+	MarketSH: "Asia/Shanghai",
+	MarketSZ: "Asia/Shanghai",
+	MarketCF: "UTC",
+}
+
+// marketLocations 各市场时区的加载函数，每个时区只加载一次
+var marketLocations = func() map[string]func() (*time.Location, error) {
+	loaders := make(map[string]func() (*time.Location, error))
+	byName := make(map[string]func() (*time.Location, error))
+	for market, name := range marketZones {
+		load, ok := byName[name]
+		if !ok {
+			load = sync.OnceValues(func() (*time.Location, error) { return time.LoadLocation(name) })
+			byName[name] = load
+		}
+		loaders[market] = load
+	}
+	return loaders
+}()
+
+// Session 交易时段，Start和End为当地时间距零点的时长
+type Session struct {
+	Start time.Duration
+	End   time.Duration
+	Type  int // 交易时段类型，USTradeSession*常量，非美股市场的常规时段为USTradeSessionIntraday
+}
+
+// marketSessions 各市场常规交易时段(当地时间)，美股不含盘前盘后
+var marketSessions = map[string][]Session{
+	MarketUS: {intraday(hm(9, 30), hm(16, 0))},
+	MarketHK: {intraday(hm(9, 30), hm(12, 0)), intraday(hm(13, 0), hm(16, 0))},
+	MarketSH: {intraday(hm(9, 30), hm(11, 30)), intraday(hm(13, 0), hm(15, 0))},
+	MarketSZ: {intraday(hm(9, 30), hm(11, 30)), intraday(hm(13, 0), hm(15, 0))},
+	MarketCF: {intraday(0, 24*time.Hour)},
+}
+
+// usExtendedSessions 美股盘前盘后时段(当地时间)
+var usExtendedSessions = []Session{
+	{Start: hm(4, 0), End: hm(9, 30), Type: USTradeSessionPreMarket},
+	{Start: hm(16, 0), End: hm(20, 0), Type: USTradeSessionAfterHours},
+}
+
+func intraday(start, end time.Duration) Session {
+	return Session{Start: start, End: end, Type: USTradeSessionIntraday}
+}
+
+func hm(hour, minute int) time.Duration {
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute
+}
+
+// MarketSessions 返回市场的常规交易时段，按时间顺序排列，未知市场返回nil
+func MarketSessions(market string) []Session {
+	return append([]Session(nil), marketSessions[market]...)
+}
+
+// MarketLocation 返回市场所在时区，未知市场或时区加载失败时返回UTC，
+// 需要区分加载失败时使用LoadMarketLocation
+func MarketLocation(market string) *time.Location {
+	loc, err := LoadMarketLocation(market)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// LoadMarketLocation 返回市场所在时区。时区在第一次使用时从系统的时区数据库加载，之后复用结果；
+// 在没有zoneinfo的环境(如scratch镜像、部分Windows)中运行的程序需要在main包中import _ "time/tzdata"，
+// 或通过ZONEINFO环境变量指定时区数据，否则返回错误
+func LoadMarketLocation(market string) (*time.Location, error) {
+	load, ok := marketLocations[market]
+	if !ok {
+		return nil, fmt.Errorf("unknown market %q", market)
+	}
+	loc, err := load()
+	if err != nil {
+		return nil, fmt.Errorf("load time zone of market %s: %w", market, err)
+	}
+	return loc, nil
+}
+
+// Location 返回品种所在市场的时区
+func (s Symbol) Location() *time.Location {
+	return MarketLocation(s.Market)
+}
+
+// Sessions 返回品种所在市场的常规交易时段
+func (s Symbol) Sessions() []Session {
+	return MarketSessions(s.Market)
+}
+
+// codeLocation 返回代码所在市场的时区，无法解析的代码返回UTC
+func codeLocation(code string) *time.Location {
+	s, err := ParseSymbol(code)
+	if err != nil {
+		return time.UTC
+	}
+	return s.Location()
+}
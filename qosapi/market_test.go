@@ -0,0 +1,46 @@
+package qosapi_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+func TestLoadMarketLocation(t *testing.T) {
+	tests := []struct {
+		market  string
+		zone    string
+		wantErr bool
+	}{
+		{qosapi.MarketUS, "America/New_York", false},
+		{qosapi.MarketHK, "Asia/Hong_Kong", false},
+		{qosapi.MarketSZ, "Asia/Shanghai", false},
+		{qosapi.MarketCF, "UTC", false},
+		{"XX", "", true},
+	}
+	for _, tt := range tests {
+		loc, err := qosapi.LoadMarketLocation(tt.market)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("LoadMarketLocation(%q) error = %v, wantErr %v", tt.market, err, tt.wantErr)
+		}
+		if err != nil {
+			if got := qosapi.MarketLocation(tt.market); got != time.UTC {
+				t.Fatalf("MarketLocation(%q) = %v, want UTC", tt.market, got)
+			}
+			continue
+		}
+		if loc.String() != tt.zone {
+			t.Fatalf("LoadMarketLocation(%q) = %v, want %s", tt.market, loc, tt.zone)
+		}
+		// 时区只加载一次，之后返回同一个*time.Location
+		if again := qosapi.MarketLocation(tt.market); again != loc {
+			t.Fatalf("MarketLocation(%q) returned a different *time.Location", tt.market)
+		}
+	}
+	sh, _ := qosapi.LoadMarketLocation(qosapi.MarketSH)
+	sz, _ := qosapi.LoadMarketLocation(qosapi.MarketSZ)
+	if sh != sz {
+		t.Fatal("markets in the same time zone should share one *time.Location")
+	}
+}
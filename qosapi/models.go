@@ -1,5 +1,7 @@
 package qosapi
 
+import "encoding/json"
+
 // 基础信息
 type InstrumentInfo struct {
 	Code              string `json:"c"`  // 股票代码
@@ -106,12 +108,12 @@ type WSRequest struct {
 
 // WebSocket响应
 type WSResponse struct {
-	Type  string      `json:"type"`
-	TP    string      `json:"tp"`
-	Msg   string      `json:"msg"`
-	Time  int64       `json:"time,omitempty"`
-	ReqID int         `json:"reqid,omitempty"`
-	Data  interface{} `json:"data,omitempty"`
+	Type  string          `json:"type"`
+	TP    string          `json:"tp"`
+	Msg   string          `json:"msg"`
+	Time  int64           `json:"time,omitempty"`
+	ReqID int             `json:"reqid,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"` // 原始JSON，按请求类型解码
 }
 
 // WebSocket行情快照
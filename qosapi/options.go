@@ -0,0 +1,130 @@
+package qosapi
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Logger 日志接口，*log.Logger实现了该接口
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// Option 客户端配置项，NewClient和NewWSClient共用，各自忽略与自身无关的配置
+type Option func(*clientOptions)
+
+// clientOptions 客户端配置
+type clientOptions struct {
+	baseURL        string
+	wsURL          string
+	httpClient     *http.Client
+	dialer         *websocket.Dialer
+	userAgent      string
+	logger         Logger
+	retryPolicy    *RetryPolicy
+	limiter        *RateLimiter
+	reconnect      *ReconnectPolicy
+	requestTimeout time.Duration
+}
+
+// newClientOptions 返回应用opts后的配置
+func newClientOptions(opts []Option) *clientOptions {
+	o := &clientOptions{
+		baseURL:        HTTPBaseURL,
+		wsURL:          WSBaseURL,
+		httpClient:     &http.Client{},
+		dialer:         websocket.DefaultDialer,
+		logger:         log.Default(),
+		reconnect:      DefaultReconnectPolicy(),
+		requestTimeout: DefaultRequestTimeout,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithBaseURL 设置HTTP接口基础URL，默认为HTTPBaseURL
+func WithBaseURL(baseURL string) Option {
+	return func(o *clientOptions) {
+		o.baseURL = baseURL
+	}
+}
+
+// WithWSURL 设置WebSocket地址，默认为WSBaseURL
+func WithWSURL(wsURL string) Option {
+	return func(o *clientOptions) {
+		o.wsURL = wsURL
+	}
+}
+
+// WithHTTPClient 设置HTTP客户端
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *clientOptions) {
+		if client != nil {
+			o.httpClient = client
+		}
+	}
+}
+
+// WithDialer 设置WebSocket拨号器，可配置代理、TLS和握手超时等
+func WithDialer(dialer *websocket.Dialer) Option {
+	return func(o *clientOptions) {
+		if dialer != nil {
+			o.dialer = dialer
+		}
+	}
+}
+
+// WithUserAgent 设置请求的User-Agent
+func WithUserAgent(userAgent string) Option {
+	return func(o *clientOptions) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithLogger 设置日志输出，默认为log.Default()，nil表示不输出日志
+func WithLogger(logger Logger) Option {
+	return func(o *clientOptions) {
+		if logger == nil {
+			logger = nopLogger{}
+		}
+		o.logger = logger
+	}
+}
+
+// WithRetryPolicy 设置HTTP请求重试策略
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(o *clientOptions) {
+		o.retryPolicy = policy
+	}
+}
+
+// WithRateLimiter 设置客户端限流器
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(o *clientOptions) {
+		o.limiter = limiter
+	}
+}
+
+// WithReconnectPolicy 设置WebSocket断线重连策略，nil表示不自动重连
+func WithReconnectPolicy(policy *ReconnectPolicy) Option {
+	return func(o *clientOptions) {
+		o.reconnect = policy
+	}
+}
+
+// WithRequestTimeout 设置WebSocket不带ctx的Request*方法的超时时间
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.requestTimeout = timeout
+	}
+}
+
+// nopLogger 不输出任何日志
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...any) {}
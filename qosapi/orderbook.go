@@ -0,0 +1,245 @@
+package qosapi
+
+import (
+	"slices"
+	"sync"
+)
+
+// BookScale 订单簿中中间价、微观价格和不平衡度等除法结果保留的小数位数
+const BookScale int32 = 8
+
+// Book 某个代码在某一时刻的订单簿，创建后不再修改，可以在多个goroutine中安全读取
+type Book struct {
+	Code      string             // 股票代码
+	Bids      []DepthItemDecimal // 买盘，价格从高到低
+	Asks      []DepthItemDecimal // 卖盘，价格从低到高
+	Timestamp int64              // 时间戳
+}
+
+// NewBook 由盘口数据创建订单簿，档位按价格排序，数量为0的档位被忽略
+func NewBook(d DepthDecimal) *Book {
+	return &Book{
+		Code:      d.Code,
+		Bids:      sortLevels(d.Bids, true),
+		Asks:      sortLevels(d.Asks, false),
+		Timestamp: d.Timestamp,
+	}
+}
+
+// sortLevels 复制并排序档位，desc为true时价格从高到低
+func sortLevels(levels []DepthItemDecimal, desc bool) []DepthItemDecimal {
+	result := make([]DepthItemDecimal, 0, len(levels))
+	for _, level := range levels {
+		if level.Volume.IsPositive() {
+			result = append(result, level)
+		}
+	}
+	slices.SortStableFunc(result, func(a, b DepthItemDecimal) int {
+		if desc {
+			return b.Price.Cmp(a.Price)
+		}
+		return a.Price.Cmp(b.Price)
+	})
+	return result
+}
+
+// BestBid 返回最优买价档位，买盘为空时ok为false
+func (b *Book) BestBid() (level DepthItemDecimal, ok bool) {
+	if len(b.Bids) == 0 {
+		return DepthItemDecimal{}, false
+	}
+	return b.Bids[0], true
+}
+
+// BestAsk 返回最优卖价档位，卖盘为空时ok为false
+func (b *Book) BestAsk() (level DepthItemDecimal, ok bool) {
+	if len(b.Asks) == 0 {
+		return DepthItemDecimal{}, false
+	}
+	return b.Asks[0], true
+}
+
+// Spread 返回买卖价差(最优卖价-最优买价)，任意一侧为空时ok为false
+func (b *Book) Spread() (Decimal, bool) {
+	bid, ask, ok := b.top()
+	if !ok {
+		return Decimal{}, false
+	}
+	return ask.Price.Sub(bid.Price), true
+}
+
+// Mid 返回中间价(最优买价和最优卖价的平均值)，任意一侧为空时ok为false
+func (b *Book) Mid() (Decimal, bool) {
+	bid, ask, ok := b.top()
+	if !ok {
+		return Decimal{}, false
+	}
+	return bid.Price.Add(ask.Price).Div(NewDecimalFromInt(2), BookScale), true
+}
+
+// Microprice 返回按最优档位数量加权的微观价格：
+// (买价*卖量 + 卖价*买量) / (买量 + 卖量)，任意一侧为空时ok为false
+func (b *Book) Microprice() (Decimal, bool) {
+	bid, ask, ok := b.top()
+	if !ok {
+		return Decimal{}, false
+	}
+	num := bid.Price.Mul(ask.Volume).Add(ask.Price.Mul(bid.Volume))
+	return num.Div(bid.Volume.Add(ask.Volume), BookScale), true
+}
+
+func (b *Book) top() (bid, ask DepthItemDecimal, ok bool) {
+	if len(b.Bids) == 0 || len(b.Asks) == 0 {
+		return DepthItemDecimal{}, DepthItemDecimal{}, false
+	}
+	return b.Bids[0], b.Asks[0], true
+}
+
+// BidVolumeTo 返回价格不低于price的买盘累计数量
+func (b *Book) BidVolumeTo(price Decimal) Decimal {
+	total := DecimalZero
+	for _, level := range b.Bids {
+		if level.Price.LessThan(price) {
+			break
+		}
+		total = total.Add(level.Volume)
+	}
+	return total
+}
+
+// AskVolumeTo 返回价格不高于price的卖盘累计数量
+func (b *Book) AskVolumeTo(price Decimal) Decimal {
+	total := DecimalZero
+	for _, level := range b.Asks {
+		if level.Price.GreaterThan(price) {
+			break
+		}
+		total = total.Add(level.Volume)
+	}
+	return total
+}
+
+// BidPriceFor 返回卖出size数量需要吃到的买盘最低价格，买盘累计数量不足时ok为false
+func (b *Book) BidPriceFor(size Decimal) (Decimal, bool) {
+	return priceFor(b.Bids, size)
+}
+
+// AskPriceFor 返回买入size数量需要吃到的卖盘最高价格，卖盘累计数量不足时ok为false
+func (b *Book) AskPriceFor(size Decimal) (Decimal, bool) {
+	return priceFor(b.Asks, size)
+}
+
+func priceFor(levels []DepthItemDecimal, size Decimal) (Decimal, bool) {
+	total := DecimalZero
+	for _, level := range levels {
+		total = total.Add(level.Volume)
+		if total.Cmp(size) >= 0 {
+			return level.Price, true
+		}
+	}
+	return Decimal{}, false
+}
+
+// Imbalance 返回前levels档的买卖数量不平衡度：(买量-卖量)/(买量+卖量)，取值范围[-1, 1]，
+// 正数表示买盘更强。levels小于等于0时使用全部档位，两侧均为空时返回0
+func (b *Book) Imbalance(levels int) Decimal {
+	bidVol, askVol := sumVolume(b.Bids, levels), sumVolume(b.Asks, levels)
+	total := bidVol.Add(askVol)
+	if total.IsZero() {
+		return DecimalZero
+	}
+	return bidVol.Sub(askVol).Div(total, BookScale)
+}
+
+func sumVolume(levels []DepthItemDecimal, n int) Decimal {
+	if n > 0 && n < len(levels) {
+		levels = levels[:n]
+	}
+	total := DecimalZero
+	for _, level := range levels {
+		total = total.Add(level.Volume)
+	}
+	return total
+}
+
+// OrderBook 按代码维护最新的订单簿。每次更新都会替换为新的Book，
+// 读取方拿到的Book不会被后续更新修改，因此可以与WSClient的推送并发使用
+type OrderBook struct {
+	mu    sync.RWMutex
+	books map[string]*Book
+}
+
+// NewOrderBook 创建空的订单簿集合
+func NewOrderBook() *OrderBook {
+	return &OrderBook{books: make(map[string]*Book)}
+}
+
+// Update 使用WebSocket推送的盘口更新订单簿
+func (o *OrderBook) Update(d WSDepth) error {
+	dec, err := d.Decimal()
+	if err != nil {
+		return err
+	}
+	o.Apply(dec)
+	return nil
+}
+
+// UpdateDepth 使用HTTP接口返回的盘口更新订单簿
+func (o *OrderBook) UpdateDepth(d Depth) error {
+	dec, err := d.Decimal()
+	if err != nil {
+		return err
+	}
+	o.Apply(dec)
+	return nil
+}
+
+// Apply 使用盘口数据替换代码对应的订单簿，时间戳早于当前订单簿的数据被忽略
+func (o *OrderBook) Apply(d DepthDecimal) {
+	book := NewBook(d)
+	code := canonicalCode(d.Code)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if cur, ok := o.books[code]; ok && book.Timestamp < cur.Timestamp {
+		return
+	}
+	o.books[code] = book
+}
+
+// Book 返回代码对应的最新订单簿
+func (o *OrderBook) Book(code string) (*Book, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	book, ok := o.books[canonicalCode(code)]
+	return book, ok
+}
+
+// Codes 返回已有订单簿的代码，按字母顺序排列
+func (o *OrderBook) Codes() []string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	codes := make([]string, 0, len(o.books))
+	for code := range o.books {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	return codes
+}
+
+// Remove 删除代码对应的订单簿
+func (o *OrderBook) Remove(code string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.books, canonicalCode(code))
+}
+
+// Subscribe 通过行情来源(WSClient或Replayer)订阅盘口并用推送更新订单簿，无法解析的推送会被记录到日志并忽略
+func (o *OrderBook) Subscribe(src MarketDataSource, codes []string) (*Subscription, error) {
+	logger := sourceLogger(src)
+	return src.OnDepth(codes, func(d WSDepth) {
+		if err := o.Update(d); err != nil {
+			logger.Printf("Failed to update order book for %s: %v", d.Code, err)
+		}
+	})
+}
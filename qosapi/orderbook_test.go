@@ -0,0 +1,127 @@
+package qosapi_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+// testBook 由"价格:数量"形式的档位创建订单簿
+func testBook(t *testing.T, bids, asks []string) *qosapi.Book {
+	t.Helper()
+	d := qosapi.Depth{Code: "US:AAPL", Timestamp: 1}
+	for _, l := range bids {
+		d.Bids = append(d.Bids, depthItem(l))
+	}
+	for _, l := range asks {
+		d.Asks = append(d.Asks, depthItem(l))
+	}
+	dec, err := d.Decimal()
+	if err != nil {
+		t.Fatalf("Decimal: %v", err)
+	}
+	return qosapi.NewBook(dec)
+}
+
+func depthItem(level string) qosapi.DepthItem {
+	price, volume, _ := strings.Cut(level, ":")
+	return qosapi.DepthItem{Price: price, Volume: volume}
+}
+
+// decimalIs 判断ok为true且d等于want
+func decimalIs(d qosapi.Decimal, ok bool, want string) bool {
+	return ok && d.Cmp(qosapi.MustParseDecimal(want)) == 0
+}
+
+func TestBookMetrics(t *testing.T) {
+	// 档位乱序、包含数量为0的档位
+	book := testBook(t,
+		[]string{"9.98:300", "10.00:100", "9.99:0", "9.99:200"},
+		[]string{"10.03:500", "10.01:300", "10.02:0", "10.02:100"},
+	)
+	if bid, ok := book.BestBid(); !decimalIs(bid.Price, ok, "10.00") || len(book.Bids) != 3 {
+		t.Fatalf("BestBid = %v, %v; %d bid levels", bid, ok, len(book.Bids))
+	}
+	if ask, ok := book.BestAsk(); !decimalIs(ask.Price, ok, "10.01") || len(book.Asks) != 3 {
+		t.Fatalf("BestAsk = %v, %v; %d ask levels", ask, ok, len(book.Asks))
+	}
+
+	tests := []struct {
+		name string
+		got  func() (qosapi.Decimal, bool)
+		want string
+	}{
+		{"Spread", book.Spread, "0.01"},
+		{"Mid", book.Mid, "10.005"},
+		// (10.00*300 + 10.01*100) / 400
+		{"Microprice", book.Microprice, "10.0025"},
+		{"BidVolumeTo 9.99", func() (qosapi.Decimal, bool) { return book.BidVolumeTo(qosapi.MustParseDecimal("9.99")), true }, "300"},
+		{"BidVolumeTo above best", func() (qosapi.Decimal, bool) { return book.BidVolumeTo(qosapi.MustParseDecimal("10.5")), true }, "0"},
+		{"AskVolumeTo 10.02", func() (qosapi.Decimal, bool) { return book.AskVolumeTo(qosapi.MustParseDecimal("10.02")), true }, "400"},
+		{"BidPriceFor within best", func() (qosapi.Decimal, bool) { return book.BidPriceFor(qosapi.MustParseDecimal("100")) }, "10.00"},
+		{"BidPriceFor three levels", func() (qosapi.Decimal, bool) { return book.BidPriceFor(qosapi.MustParseDecimal("301")) }, "9.98"},
+		{"AskPriceFor exact level", func() (qosapi.Decimal, bool) { return book.AskPriceFor(qosapi.MustParseDecimal("400")) }, "10.02"},
+		{"Imbalance top level", func() (qosapi.Decimal, bool) { return book.Imbalance(1), true }, "-0.5"},
+		// 买600 卖900
+		{"Imbalance all levels", func() (qosapi.Decimal, bool) { return book.Imbalance(0), true }, "-0.2"},
+		{"Imbalance more levels than book", func() (qosapi.Decimal, bool) { return book.Imbalance(10), true }, "-0.2"},
+	}
+	for _, tt := range tests {
+		if got, ok := tt.got(); !decimalIs(got, ok, tt.want) {
+			t.Errorf("%s = %s, %v, want %s", tt.name, got, ok, tt.want)
+		}
+	}
+	if _, ok := book.AskPriceFor(qosapi.MustParseDecimal("901")); ok {
+		t.Error("AskPriceFor beyond book depth should not be ok")
+	}
+}
+
+func TestBookOneSided(t *testing.T) {
+	book := testBook(t, []string{"10:100"}, nil)
+	if _, ok := book.Spread(); ok {
+		t.Error("Spread of a one-sided book should not be ok")
+	}
+	if _, ok := book.Mid(); ok {
+		t.Error("Mid of a one-sided book should not be ok")
+	}
+	if _, ok := book.Microprice(); ok {
+		t.Error("Microprice of a one-sided book should not be ok")
+	}
+	if _, ok := book.BestAsk(); ok {
+		t.Error("BestAsk of an empty side should not be ok")
+	}
+	if got := book.Imbalance(0); got.Cmp(qosapi.DecimalOne) != 0 {
+		t.Errorf("Imbalance = %s, want 1", got)
+	}
+	if got := testBook(t, nil, nil).Imbalance(0); !got.IsZero() {
+		t.Errorf("Imbalance of an empty book = %s, want 0", got)
+	}
+}
+
+func TestOrderBookIgnoresStaleUpdates(t *testing.T) {
+	ob := qosapi.NewOrderBook()
+	update := func(ts int64, bid string) {
+		t.Helper()
+		if err := ob.Update(qosapi.WSDepth{Code: "hk:700", Bids: []qosapi.DepthItem{{Price: bid, Volume: "1"}}, Timestamp: ts}); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+	update(2, "300")
+	update(1, "299") // 较旧的推送
+	update(2, "301") // 相同时间戳替换
+	book, ok := ob.Book("HK:00700")
+	if bid, _ := book.BestBid(); !ok || bid.Price.String() != "301" {
+		t.Fatalf("Book = %v, %v, want best bid 301", book, ok)
+	}
+	if codes := ob.Codes(); len(codes) != 1 || codes[0] != "HK:00700" {
+		t.Fatalf("Codes = %v", codes)
+	}
+	if err := ob.Update(qosapi.WSDepth{Code: "HK:700", Bids: []qosapi.DepthItem{{Price: "x", Volume: "1"}}, Timestamp: 3}); err == nil {
+		t.Fatal("Update with an invalid price should fail")
+	}
+	ob.Remove("hk:00700")
+	if _, ok := ob.Book("HK:00700"); ok {
+		t.Fatal("Book after Remove should not exist")
+	}
+}
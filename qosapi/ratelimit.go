@@ -0,0 +1,155 @@
+package qosapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimitExceeded 客户端限流器拒绝了请求(RateLimitFailFast策略)
+var ErrRateLimitExceeded = errors.New("client rate limit exceeded")
+
+// RateLimitPolicy 超出限流时的处理方式
+type RateLimitPolicy int
+
+const (
+	RateLimitWait     RateLimitPolicy = iota // 阻塞等待直到获得令牌或ctx结束
+	RateLimitFailFast                        // 立即返回ErrRateLimitExceeded
+)
+
+// RateLimiter 令牌桶限流器，支持全局限流和按接口限流。
+// 同一个RateLimiter可以同时设置给QOSClient和WSClient，共享同一份配额。
+//
+// 接口名使用HTTP路径，如"/snapshot"、"/history"；WebSocket请求会映射到对应的HTTP路径
+// (RS对应"/snapshot"，RH对应"/history"等)，订阅类请求使用其类型，如"S"、"KC"。
+// 心跳请求不受限流。
+type RateLimiter struct {
+	mu        sync.Mutex
+	policy    RateLimitPolicy
+	global    *tokenBucket
+	endpoints map[string]*tokenBucket
+}
+
+// NewRateLimiter 创建限流器，rate为全局每秒请求数，burst为突发容量。
+// rate小于等于0表示不做全局限制，仅使用SetEndpointLimit设置的接口限制
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		global:    newTokenBucket(rate, burst),
+		endpoints: make(map[string]*tokenBucket),
+	}
+}
+
+// SetPolicy 设置超出限流时的处理方式，默认为RateLimitWait
+func (l *RateLimiter) SetPolicy(policy RateLimitPolicy) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.policy = policy
+}
+
+// SetEndpointLimit 设置单个接口的限流，rate小于等于0表示取消该接口的限制
+func (l *RateLimiter) SetEndpointLimit(endpoint string, rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rate <= 0 {
+		delete(l.endpoints, endpoint)
+		return
+	}
+	l.endpoints[endpoint] = newTokenBucket(rate, burst)
+}
+
+// Allow 尝试立即获取一个令牌，不等待
+func (l *RateLimiter) Allow(endpoint string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	wait, _ := l.reserve(endpoint, time.Now(), false)
+	return wait == 0
+}
+
+// Wait 按限流策略获取一个令牌
+func (l *RateLimiter) Wait(ctx context.Context, endpoint string) error {
+	l.mu.Lock()
+	wait, reserved := l.reserve(endpoint, time.Now(), l.policy == RateLimitWait)
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	if reserved == nil {
+		return ErrRateLimitExceeded
+	}
+
+	if err := sleepContext(ctx, wait); err != nil {
+		l.mu.Lock()
+		for _, b := range reserved {
+			b.tokens++
+		}
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// reserve 计算获取令牌需要等待的时间。令牌立即可用时直接扣除；
+// 否则当allowDebt为true时预支令牌并返回预支的桶，用于取消时归还
+func (l *RateLimiter) reserve(endpoint string, now time.Time, allowDebt bool) (time.Duration, []*tokenBucket) {
+	buckets := make([]*tokenBucket, 0, 2)
+	if l.global != nil {
+		buckets = append(buckets, l.global)
+	}
+	if b, ok := l.endpoints[endpoint]; ok {
+		buckets = append(buckets, b)
+	}
+
+	var wait time.Duration
+	for _, b := range buckets {
+		b.advance(now)
+		wait = max(wait, b.waitTime())
+	}
+	if wait > 0 && !allowDebt {
+		return wait, nil
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return wait, buckets
+}
+
+// tokenBucket 令牌桶
+type tokenBucket struct {
+	rate   float64 // 每秒生成的令牌数
+	burst  float64 // 桶容量
+	tokens float64 // 当前令牌数，预支时可为负
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// advance 按流逝的时间补充令牌
+func (b *tokenBucket) advance(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+// waitTime 返回获得一个完整令牌需要等待的时间
+func (b *tokenBucket) waitTime() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
@@ -0,0 +1,107 @@
+package qosapi_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+	"github.com/qos-max/qos-quote-api-go-sdk/qostest"
+)
+
+func TestRateLimiterFailFastStopsRequestsBeforeTheServer(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	srv.SetSnapshot(qosapi.Snapshot{Code: "US:AAPL", LastPrice: "200"})
+
+	limiter := qosapi.NewRateLimiter(0, 0)
+	limiter.SetEndpointLimit("/snapshot", 0.001, 2)
+	limiter.SetPolicy(qosapi.RateLimitFailFast)
+	client := srv.Client(qosapi.WithRateLimiter(limiter))
+
+	for i := range 2 {
+		if _, err := client.GetSnapshot([]string{"US:AAPL"}); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if _, err := client.GetSnapshot([]string{"US:AAPL"}); !errors.Is(err, qosapi.ErrRateLimitExceeded) {
+		t.Fatalf("third request error = %v, want ErrRateLimitExceeded", err)
+	}
+	if n := srv.RequestCount("/snapshot"); n != 2 {
+		t.Fatalf("server saw %d requests, want 2", n)
+	}
+
+	// 其他接口不受/snapshot的限制
+	if _, err := client.GetDepth([]string{"US:AAPL"}); err != nil {
+		t.Fatalf("GetDepth: %v", err)
+	}
+}
+
+func TestRateLimiterIsSharedBetweenRESTAndWebSocket(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	srv.SetSnapshot(qosapi.Snapshot{Code: "US:AAPL", LastPrice: "200"})
+
+	limiter := qosapi.NewRateLimiter(0, 0)
+	limiter.SetEndpointLimit("/snapshot", 0.001, 1)
+	limiter.SetPolicy(qosapi.RateLimitFailFast)
+	client := srv.Client(qosapi.WithRateLimiter(limiter))
+	ws := connectWS(t, srv, qosapi.WithRateLimiter(limiter))
+
+	if _, err := client.GetSnapshot([]string{"US:AAPL"}); err != nil {
+		t.Fatalf("GetSnapshot: %v", err)
+	}
+	// RS与/snapshot共享配额
+	if _, err := ws.RequestSnapshot([]string{"US:AAPL"}); !errors.Is(err, qosapi.ErrRateLimitExceeded) {
+		t.Fatalf("RequestSnapshot error = %v, want ErrRateLimitExceeded", err)
+	}
+	if n := srv.RequestCount("RS"); n != 0 {
+		t.Fatalf("server saw %d RS requests, want 0", n)
+	}
+}
+
+func TestRateLimiterWaitHonorsContext(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+
+	limiter := qosapi.NewRateLimiter(0.5, 1)
+	client := srv.Client(qosapi.WithRateLimiter(limiter))
+	if _, err := client.GetSnapshot([]string{"US:AAPL"}); err != nil {
+		t.Fatalf("GetSnapshot: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.GetSnapshotContext(ctx, []string{"US:AAPL"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("waited %v, want to return when ctx expires", d)
+	}
+	if n := srv.RequestCount("/snapshot"); n != 1 {
+		t.Fatalf("server saw %d requests, want 1", n)
+	}
+}
+
+func TestRateLimiterWaitSpacesRequests(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+
+	client := srv.Client(qosapi.WithRateLimiter(qosapi.NewRateLimiter(20, 1)))
+	start := time.Now()
+	for i := range 3 {
+		if _, err := client.GetSnapshot([]string{"US:AAPL"}); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	// 突发容量为1，后两次各需等待约50ms
+	if d := time.Since(start); d < 80*time.Millisecond {
+		t.Fatalf("3 requests took %v, want at least ~100ms", d)
+	}
+	if n := srv.RequestCount("/snapshot"); n != 3 {
+		t.Fatalf("server saw %d requests, want 3", n)
+	}
+}
@@ -0,0 +1,143 @@
+package qosapi
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ReconnectPolicy WebSocket断线重连策略
+type ReconnectPolicy struct {
+	MaxAttempts int           // 最大重连次数，0表示不限制
+	BaseDelay   time.Duration // 首次重连前的等待时间，之后每次翻倍
+	MaxDelay    time.Duration // 单次等待时间上限，0表示不限制
+	Jitter      float64       // 随机抖动比例(0~1)
+}
+
+// DefaultReconnectPolicy 返回默认重连策略：不限次数，0.5秒起指数退避，最长30秒
+func DefaultReconnectPolicy() *ReconnectPolicy {
+	return &ReconnectPolicy{
+		BaseDelay: 500 * time.Millisecond,
+		MaxDelay:  30 * time.Second,
+		Jitter:    0.5,
+	}
+}
+
+// SetReconnectPolicy 设置断线重连策略，nil表示断线后不自动重连
+func (c *WSClient) SetReconnectPolicy(policy *ReconnectPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reconnect = policy
+}
+
+// handleDisconnect 处理读取错误：失败所有等待中的请求，并按策略启动重连
+func (c *WSClient) handleDisconnect(conn *websocket.Conn, err error) {
+	c.mu.Lock()
+	if c.conn != conn {
+		// 已经被Close处理
+		c.mu.Unlock()
+		return
+	}
+	c.conn = nil
+	pending := c.takePendingLocked()
+	policy := c.reconnect
+	closeChan := c.closeChan
+	c.mu.Unlock()
+
+	conn.Close()
+	c.logger.Printf("WebSocket read error: %v", err)
+	failPending(pending, fmt.Errorf("%w: %v", ErrConnectionLost, err))
+
+	if policy != nil {
+		go c.reconnectLoop(policy, closeChan)
+	} else {
+		c.closeStreams(fmt.Errorf("%w: %v", ErrConnectionLost, err))
+	}
+}
+
+// reconnectLoop 按退避策略重新连接，成功后恢复所有订阅
+func (c *WSClient) reconnectLoop(policy *ReconnectPolicy, closeChan chan struct{}) {
+	for attempt := 1; policy.MaxAttempts <= 0 || attempt <= policy.MaxAttempts; attempt++ {
+		timer := time.NewTimer(backoffDelay(policy.BaseDelay, policy.MaxDelay, policy.Jitter, attempt))
+		select {
+		case <-closeChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		conn, err := c.dial()
+		if err != nil {
+			c.logger.Printf("WebSocket reconnect attempt %d failed: %v", attempt, err)
+			continue
+		}
+
+		c.mu.Lock()
+		if c.closed || c.closeChan != closeChan || c.conn != nil {
+			// 期间客户端被关闭或已通过Connect重新连接
+			c.mu.Unlock()
+			conn.Close()
+			return
+		}
+		if err := c.resubscribeLocked(conn); err != nil {
+			c.mu.Unlock()
+			conn.Close()
+			c.logger.Printf("WebSocket reconnect attempt %d failed to restore subscriptions: %v", attempt, err)
+			continue
+		}
+		c.conn = conn
+		go c.readLoop(conn)
+		c.mu.Unlock()
+
+		c.logger.Printf("WebSocket reconnected after %d attempt(s)", attempt)
+		return
+	}
+	c.logger.Printf("WebSocket reconnect gave up after %d attempts", policy.MaxAttempts)
+	c.closeStreams(fmt.Errorf("%w: reconnect gave up after %d attempts", ErrConnectionLost, policy.MaxAttempts))
+}
+
+// resubscribeLocked 在新连接上重新发送所有订阅，调用方需持有c.mu
+func (c *WSClient) resubscribeLocked(conn *websocket.Conn) error {
+	subscribed := c.registry.subscribed()
+	keys := make([]subscriptionKey, 0, len(subscribed))
+	for key := range subscribed {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b subscriptionKey) int {
+		if n := strings.Compare(a.Type, b.Type); n != 0 {
+			return n
+		}
+		return a.KLineType - b.KLineType
+	})
+
+	for _, key := range keys {
+		c.reqCounter++
+		req := WSRequest{
+			Type:      key.Type,
+			Codes:     groupCodes(subscribed[key]),
+			KLineType: key.KLineType,
+			ReqID:     c.reqCounter,
+		}
+		if err := conn.WriteJSON(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// takePendingLocked 取出所有等待响应的请求，调用方需持有c.mu
+func (c *WSClient) takePendingLocked() map[int]*pendingCall {
+	pending := c.callbacks
+	c.callbacks = make(map[int]*pendingCall)
+	return pending
+}
+
+// failPending 以err结束所有等待响应的请求
+func failPending(pending map[int]*pendingCall, err error) {
+	for _, call := range pending {
+		call.callback(nil, err)
+	}
+}
@@ -0,0 +1,170 @@
+package qosapi_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+	"github.com/qos-max/qos-quote-api-go-sdk/qostest"
+)
+
+// fastReconnect 测试用的快速重连策略
+func fastReconnect() qosapi.Option {
+	return qosapi.WithReconnectPolicy(&qosapi.ReconnectPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond})
+}
+
+func TestReconnectReplaysSubscriptions(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := connectWS(t, srv, fastReconnect())
+
+	var trades, klines atomic.Int32
+	if _, err := client.OnTrade([]string{"US:AAPL", "HK:700"}, func(qosapi.WSTrade) { trades.Add(1) }); err != nil {
+		t.Fatalf("OnTrade: %v", err)
+	}
+	if _, err := client.OnKLine([]string{"US:AAPL"}, qosapi.KLineTypeMin1, func(qosapi.WSKLine) { klines.Add(1) }); err != nil {
+		t.Fatalf("OnKLine: %v", err)
+	}
+	dropped, err := client.OnDepth([]string{"US:TSLA"}, func(qosapi.WSDepth) {})
+	if err != nil {
+		t.Fatalf("OnDepth: %v", err)
+	}
+	waitFor(t, "subscriptions", func() bool {
+		return srv.Subscribed("T", "HK:00700") && srv.SubscribedKLine("US:AAPL", qosapi.KLineTypeMin1) && srv.Subscribed("D", "US:TSLA")
+	})
+	if err := dropped.Unsubscribe(); err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	waitFor(t, "depth unsubscribed", func() bool { return !srv.Subscribed("D", "US:TSLA") })
+
+	srv.Disconnect()
+	waitFor(t, "subscriptions replayed", func() bool {
+		return srv.Connections() == 1 &&
+			srv.Subscribed("T", "US:AAPL") && srv.Subscribed("T", "HK:00700") &&
+			srv.SubscribedKLine("US:AAPL", qosapi.KLineTypeMin1)
+	})
+	if srv.Subscribed("D", "US:TSLA") {
+		t.Fatal("cancelled subscription was replayed")
+	}
+
+	srv.PushTrade(qosapi.WSTrade{Code: "HK:00700", Price: "300"})
+	srv.PushKLine(qosapi.WSKLine{Code: "US:AAPL", KLineType: qosapi.KLineTypeMin1})
+	waitFor(t, "pushes after reconnect", func() bool { return trades.Load() == 1 && klines.Load() == 1 })
+
+	if _, err := client.RequestSnapshot([]string{"US:AAPL"}); err != nil {
+		t.Fatalf("request after reconnect: %v", err)
+	}
+}
+
+func TestReconnectSurvivesRepeatedDisconnects(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := connectWS(t, srv, fastReconnect())
+
+	var n atomic.Int32
+	if _, err := client.OnSnapshot([]string{"US:AAPL"}, func(qosapi.WSSnapshot) { n.Add(1) }); err != nil {
+		t.Fatalf("OnSnapshot: %v", err)
+	}
+	// 每次连接都会发送一次S订阅请求
+	for i := 1; i <= 3; i++ {
+		waitFor(t, "subscription", func() bool { return srv.RequestCount("S") == i && srv.Subscribed("S", "US:AAPL") })
+		srv.Disconnect()
+	}
+	waitFor(t, "final subscription", func() bool { return srv.RequestCount("S") == 4 && srv.Subscribed("S", "US:AAPL") })
+	srv.PushSnapshot(qosapi.WSSnapshot{Code: "US:AAPL"})
+	waitFor(t, "push", func() bool { return n.Load() == 1 })
+}
+
+func TestPendingRequestFailsWhenConnectionIsLost(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := connectWS(t, srv, fastReconnect())
+	srv.SetLatency(time.Second)
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := client.RequestSnapshot([]string{"US:AAPL"})
+		errc <- err
+	}()
+	waitFor(t, "request sent", func() bool { return srv.RequestCount("RS") == 1 })
+	srv.Disconnect()
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, qosapi.ErrConnectionLost) {
+			t.Fatalf("error = %v, want ErrConnectionLost", err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("pending request was not failed on disconnect")
+	}
+}
+
+func TestNoReconnectWithoutPolicy(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := connectWS(t, srv)
+	client.SetReconnectPolicy(nil)
+
+	if err := client.SubscribeTrade([]string{"US:AAPL"}, func(qosapi.WSTrade) {}); err != nil {
+		t.Fatalf("SubscribeTrade: %v", err)
+	}
+	srv.Disconnect()
+	waitFor(t, "disconnect", func() bool { return srv.Connections() == 0 })
+	time.Sleep(100 * time.Millisecond)
+	if srv.Connections() != 0 {
+		t.Fatal("client reconnected without a reconnect policy")
+	}
+	if _, err := client.RequestSnapshot([]string{"US:AAPL"}); !errors.Is(err, qosapi.ErrNotConnected) {
+		t.Fatalf("request error = %v, want ErrNotConnected", err)
+	}
+}
+
+func TestConnectReplaysSubscriptions(t *testing.T) {
+	tests := []struct {
+		name string
+		drop func(t *testing.T, srv *qostest.Server, client *qosapi.WSClient)
+	}{
+		{"after Close", func(t *testing.T, srv *qostest.Server, client *qosapi.WSClient) {
+			if err := client.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+		}},
+		{"after reconnect gave up", func(t *testing.T, srv *qostest.Server, client *qosapi.WSClient) {
+			srv.InjectError("/ws", 0, "unavailable", 0)
+			srv.Disconnect()
+			// 首次连接加MaxAttempts次重连
+			waitFor(t, "reconnect attempts", func() bool { return srv.RequestCount("/ws") == 3 })
+			srv.ClearErrors()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := qostest.NewServer()
+			defer srv.Close()
+			client := connectWS(t, srv, qosapi.WithReconnectPolicy(&qosapi.ReconnectPolicy{
+				BaseDelay: 5 * time.Millisecond, MaxDelay: 10 * time.Millisecond, MaxAttempts: 2,
+			}))
+
+			var n atomic.Int32
+			if err := client.SubscribeSnapshot([]string{"US:AAPL"}, func(qosapi.WSSnapshot) { n.Add(1) }); err != nil {
+				t.Fatalf("SubscribeSnapshot: %v", err)
+			}
+			waitFor(t, "subscription", func() bool { return srv.Subscribed("S", "US:AAPL") })
+
+			tt.drop(t, srv, client)
+			waitFor(t, "disconnect", func() bool { return srv.Connections() == 0 })
+
+			if err := client.Connect(); err != nil {
+				t.Fatalf("Connect: %v", err)
+			}
+			if err := client.SubscribeSnapshot([]string{"US:AAPL"}, func(qosapi.WSSnapshot) { n.Add(1) }); err != nil {
+				t.Fatalf("SubscribeSnapshot: %v", err)
+			}
+			waitFor(t, "subscription restored", func() bool { return srv.Subscribed("S", "US:AAPL") })
+			srv.PushSnapshot(qosapi.WSSnapshot{Code: "US:AAPL"})
+			waitFor(t, "push", func() bool { return n.Load() > 0 })
+		})
+	}
+}
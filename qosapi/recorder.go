@@ -0,0 +1,475 @@
+package qosapi
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 录制文件格式：
+//
+//	文件 = [gzip]( magic 记录* )
+//	记录 = uvarint(长度) 内容
+//	内容 = 接收时间(int64纳秒，大端) uint8(len(类型)) 类型 uvarint(len(代码)) 代码 原始消息
+//
+// 每个录制文件旁有一个同名加.idx后缀的JSON索引文件，按类型和代码记录条数和时间范围，
+// 并每RecordIndexInterval条记录保存一个块的偏移量和时间
+var recordMagic = []byte("QOSREC\x00\x01")
+
+// 录制文件后缀
+const (
+	RecordFileExt      = ".qrec"
+	RecordIndexFileExt = ".idx"
+)
+
+// RecordIndexInterval 索引中每隔多少条记录保存一个块
+const RecordIndexInterval = 1024
+
+// maxRecordSize 单条记录内容的最大字节数，读取时长度超过该值的记录视为文件损坏
+const maxRecordSize = 64 << 20
+
+// ErrRecorderClosed 录制器已关闭
+var ErrRecorderClosed = errors.New("recorder closed")
+
+// Record 一条录制的消息
+type Record struct {
+	Time time.Time // 接收时间
+	Type string    // 消息类型，推送为S、T、D、K，请求响应为请求类型
+	Code string    // 股票代码，请求响应为空
+	Data []byte    // 原始消息
+}
+
+// RecordIndexEntry 录制文件中某个类型和代码的索引
+type RecordIndexEntry struct {
+	Type  string `json:"type"`
+	Code  string `json:"code"`
+	Count int    `json:"count"`
+	First int64  `json:"first"` // 第一条的接收时间(纳秒)
+	Last  int64  `json:"last"`  // 最后一条的接收时间(纳秒)
+}
+
+// RecordIndexBlock 录制文件中从某条记录开始的一块，用于跳过时间范围之前的数据
+type RecordIndexBlock struct {
+	Seq    int   `json:"seq"`    // 块中第一条记录的序号(从0开始)
+	Offset int64 `json:"offset"` // 块中第一条记录在未压缩数据中的偏移量
+	Time   int64 `json:"time"`   // 块中第一条记录的接收时间(纳秒)
+}
+
+// RecordIndex 录制文件的索引
+type RecordIndex struct {
+	File       string              `json:"file"`
+	Compressed bool                `json:"compressed"`
+	Count      int                 `json:"count"`
+	First      int64               `json:"first"`
+	Last       int64               `json:"last"`
+	Entries    []*RecordIndexEntry `json:"entries"`
+	Blocks     []RecordIndexBlock  `json:"blocks"` // 每RecordIndexInterval条记录一个
+}
+
+// SeekOffset 返回读取接收时间不早于t的记录时可以直接跳到的偏移量，即最后一个起始时间早于t的块。
+// 记录按接收时间顺序写入，该偏移量之前的记录都早于t；没有这样的块时返回第一条记录的偏移量
+func (idx *RecordIndex) SeekOffset(t time.Time) int64 {
+	offset := int64(len(recordMagic))
+	for _, b := range idx.Blocks {
+		if b.Time >= t.UnixNano() {
+			break
+		}
+		offset = b.Offset
+	}
+	return offset
+}
+
+// RecorderOptions 录制配置
+type RecorderOptions struct {
+	Dir      string        // 录制文件目录，不存在时自动创建
+	Prefix   string        // 文件名前缀，默认为"qos"
+	Compress bool          // 是否使用gzip压缩
+	MaxSize  int64         // 单个文件的最大字节数(未压缩)，超过后切换新文件，0表示不限制
+	MaxAge   time.Duration // 单个文件的最长录制时间，超过后切换新文件，0表示不限制
+}
+
+// Recorder 将WSClient收到的原始消息连同接收时间写入录制文件，按大小和时间切换文件。
+// 索引文件只在文件切换(包括Rotate)和Close时写入，录制中的文件和异常退出时的最后一个文件没有索引，
+// Replayer会从头读取这些文件
+type Recorder struct {
+	opts RecorderOptions
+
+	mu      sync.Mutex
+	file    *os.File
+	gz      *gzip.Writer
+	w       *bufio.Writer
+	path    string
+	size    int64
+	opened  time.Time
+	index   *RecordIndex
+	entries map[[2]string]*RecordIndexEntry
+	files   []string
+	err     error
+	closed  bool
+}
+
+// NewRecorder 创建录制器，第一个文件在收到第一条消息时创建
+func NewRecorder(opts RecorderOptions) (*Recorder, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("recorder dir is required")
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "qos"
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Recorder{opts: opts}, nil
+}
+
+// Attach 开始录制WSClient收到的所有消息，返回用于停止录制的函数。
+// 写入失败时记录到日志，错误可通过Err获取
+func (r *Recorder) Attach(c *WSClient) (detach func()) {
+	return c.OnRawMessage(func(msg RawMessage) {
+		if err := r.Record(msg); err != nil {
+			c.logger.Printf("Failed to record message: %v", err)
+		}
+	})
+}
+
+// Record 写入一条原始消息
+func (r *Recorder) Record(msg RawMessage) error {
+	typ, code := messageTypeCode(msg.Data)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return ErrRecorderClosed
+	}
+	if r.file != nil && r.shouldRotate(msg.Time) {
+		if err := r.closeFileLocked(); err != nil {
+			return r.fail(err)
+		}
+	}
+	if r.file == nil {
+		if err := r.openFileLocked(msg.Time); err != nil {
+			return r.fail(err)
+		}
+	}
+
+	var body bytes.Buffer
+	var buf [binary.MaxVarintLen64]byte
+	binary.Write(&body, binary.BigEndian, msg.Time.UnixNano())
+	body.WriteByte(byte(len(typ)))
+	body.WriteString(typ)
+	body.Write(buf[:binary.PutUvarint(buf[:], uint64(len(code)))])
+	body.WriteString(code)
+	body.Write(msg.Data)
+	if body.Len() > maxRecordSize {
+		return fmt.Errorf("record %s %s: message too large (%d bytes)", typ, code, len(msg.Data))
+	}
+
+	offset := r.size
+	n := binary.PutUvarint(buf[:], uint64(body.Len()))
+	if _, err := r.w.Write(buf[:n]); err != nil {
+		return r.fail(err)
+	}
+	if _, err := r.w.Write(body.Bytes()); err != nil {
+		return r.fail(err)
+	}
+	r.size += int64(n + body.Len())
+
+	ts := msg.Time.UnixNano()
+	if r.index.Count%RecordIndexInterval == 0 {
+		r.index.Blocks = append(r.index.Blocks, RecordIndexBlock{Seq: r.index.Count, Offset: offset, Time: ts})
+	}
+	r.index.Count++
+	if r.index.First == 0 {
+		r.index.First = ts
+	}
+	r.index.Last = ts
+	key := [2]string{typ, code}
+	entry, ok := r.entries[key]
+	if !ok {
+		entry = &RecordIndexEntry{Type: typ, Code: code, First: ts}
+		r.entries[key] = entry
+		r.index.Entries = append(r.index.Entries, entry)
+	}
+	entry.Count++
+	entry.Last = ts
+	return nil
+}
+
+func (r *Recorder) shouldRotate(now time.Time) bool {
+	if r.opts.MaxSize > 0 && r.size >= r.opts.MaxSize {
+		return true
+	}
+	return r.opts.MaxAge > 0 && now.Sub(r.opened) >= r.opts.MaxAge
+}
+
+// fail 记录第一个写入错误
+func (r *Recorder) fail(err error) error {
+	if r.err == nil {
+		r.err = err
+	}
+	return err
+}
+
+func (r *Recorder) openFileLocked(now time.Time) error {
+	name := r.opts.Prefix + "-" + now.UTC().Format("20060102T150405.000000000") + RecordFileExt
+	if r.opts.Compress {
+		name += ".gz"
+	}
+	path := filepath.Join(r.opts.Dir, name)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = f
+	r.gz = nil
+	if r.opts.Compress {
+		r.gz = gzip.NewWriter(f)
+		w = r.gz
+	}
+	r.w = bufio.NewWriterSize(w, 64*1024)
+	if _, err := r.w.Write(recordMagic); err != nil {
+		f.Close()
+		return err
+	}
+
+	r.file, r.path, r.opened = f, path, now
+	r.size = int64(len(recordMagic))
+	r.index = &RecordIndex{File: name, Compressed: r.opts.Compress}
+	r.entries = make(map[[2]string]*RecordIndexEntry)
+	r.files = append(r.files, path)
+	return nil
+}
+
+func (r *Recorder) closeFileLocked() error {
+	if r.file == nil {
+		return nil
+	}
+	f := r.file
+	r.file = nil
+
+	err := r.w.Flush()
+	if r.gz != nil {
+		err = errors.Join(err, r.gz.Close())
+	}
+	err = errors.Join(err, f.Close())
+
+	data, jerr := json.Marshal(r.index)
+	if jerr == nil {
+		jerr = os.WriteFile(r.path+RecordIndexFileExt, data, 0o644)
+	}
+	return errors.Join(err, jerr)
+}
+
+// Flush 将缓冲的数据写入文件，不写入索引，索引只在Rotate和Close时生成。
+// 压缩文件在切换或关闭前可能仍有部分数据未写入磁盘
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	if err := r.w.Flush(); err != nil {
+		return r.fail(err)
+	}
+	if r.gz != nil {
+		if err := r.gz.Flush(); err != nil {
+			return r.fail(err)
+		}
+	}
+	return nil
+}
+
+// Rotate 关闭当前文件并写入索引，下一条消息写入新文件
+func (r *Recorder) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.closeFileLocked(); err != nil {
+		return r.fail(err)
+	}
+	return nil
+}
+
+// Close 关闭当前文件并写入索引，之后的Record返回ErrRecorderClosed
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if err := r.closeFileLocked(); err != nil {
+		return r.fail(err)
+	}
+	return nil
+}
+
+// Files 返回已创建的录制文件路径，按创建顺序排列
+func (r *Recorder) Files() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.files...)
+}
+
+// Err 返回第一个写入错误
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// messageTypeCode 解析消息的类型和代码
+func messageTypeCode(data []byte) (typ, code string) {
+	var head struct {
+		Type string `json:"type"`
+		TP   string `json:"tp"`
+		Code string `json:"c"`
+	}
+	if json.Unmarshal(data, &head) != nil {
+		return "", ""
+	}
+	typ = head.Type
+	if typ == "" {
+		typ = head.TP
+	}
+	if len(typ) > 255 {
+		typ = typ[:255]
+	}
+	return typ, canonicalCode(head.Code)
+}
+
+// RecordReader 按顺序读取录制文件
+type RecordReader struct {
+	f      *os.File
+	gz     *gzip.Reader
+	r      *bufio.Reader
+	offset int64
+}
+
+// OpenRecording 打开录制文件，自动识别是否压缩
+func OpenRecording(path string) (*RecordReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	rr := &RecordReader{f: f}
+	br := bufio.NewReader(f)
+	if head, _ := br.Peek(2); len(head) == 2 && head[0] == 0x1f && head[1] == 0x8b {
+		if rr.gz, err = gzip.NewReader(br); err != nil {
+			f.Close()
+			return nil, err
+		}
+		br = bufio.NewReader(rr.gz)
+	}
+	rr.r = br
+
+	magic := make([]byte, len(recordMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, recordMagic) {
+		rr.Close()
+		return nil, fmt.Errorf("%s: not a recording file", path)
+	}
+	rr.offset = int64(len(recordMagic))
+	return rr, nil
+}
+
+// Next 读取下一条记录，没有更多记录时返回io.EOF
+func (rr *RecordReader) Next() (Record, error) {
+	length, err := binary.ReadUvarint(rr.r)
+	if err != nil {
+		if err == io.EOF {
+			return Record{}, io.EOF
+		}
+		return Record{}, fmt.Errorf("read record at %d: %w", rr.offset, err)
+	}
+	if length > maxRecordSize {
+		return Record{}, fmt.Errorf("read record at %d: record too large (%d bytes)", rr.offset, length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(rr.r, body); err != nil {
+		return Record{}, fmt.Errorf("read record at %d: %w", rr.offset, io.ErrUnexpectedEOF)
+	}
+	var lenBuf [binary.MaxVarintLen64]byte
+	rr.offset += int64(binary.PutUvarint(lenBuf[:], length)) + int64(length)
+
+	rec, err := decodeRecord(body)
+	if err != nil {
+		return Record{}, fmt.Errorf("decode record at %d: %w", rr.offset, err)
+	}
+	return rec, nil
+}
+
+// SkipTo 跳到未压缩数据中的offset处继续读取，offset必须是记录的起始位置(如RecordIndex.SeekOffset的返回值)。
+// 压缩文件只能向后跳
+func (rr *RecordReader) SkipTo(offset int64) error {
+	if offset < int64(len(recordMagic)) {
+		return fmt.Errorf("invalid record offset %d", offset)
+	}
+	if rr.gz == nil {
+		if _, err := rr.f.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		rr.r.Reset(rr.f)
+		rr.offset = offset
+		return nil
+	}
+	if offset < rr.offset {
+		return fmt.Errorf("cannot seek backwards in a compressed recording (at %d, want %d)", rr.offset, offset)
+	}
+	n, err := io.CopyN(io.Discard, rr.r, offset-rr.offset)
+	rr.offset += n
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func decodeRecord(body []byte) (Record, error) {
+	if len(body) < 9 {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	ts := int64(binary.BigEndian.Uint64(body))
+	body = body[8:]
+	n := int(body[0])
+	if len(body) < 1+n {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	typ := string(body[1 : 1+n])
+	body = body[1+n:]
+	codeLen, k := binary.Uvarint(body)
+	if k <= 0 || uint64(len(body)-k) < codeLen {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	code := string(body[k : k+int(codeLen)])
+	return Record{Time: time.Unix(0, ts), Type: typ, Code: code, Data: body[k+int(codeLen):]}, nil
+}
+
+// Close 关闭录制文件
+func (rr *RecordReader) Close() error {
+	if rr.gz != nil {
+		rr.gz.Close()
+	}
+	return rr.f.Close()
+}
+
+// ReadRecordIndex 读取录制文件的索引
+func ReadRecordIndex(path string) (*RecordIndex, error) {
+	data, err := os.ReadFile(path + RecordIndexFileExt)
+	if err != nil {
+		return nil, err
+	}
+	var index RecordIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("decode index %s: %w", path+RecordIndexFileExt, err)
+	}
+	return &index, nil
+}
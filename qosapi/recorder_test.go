@@ -0,0 +1,220 @@
+package qosapi_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+// recordTrades 录制n条逐笔成交，第i条的接收时间为base+i秒，价格为i
+func recordTrades(t *testing.T, opts qosapi.RecorderOptions, base time.Time, n int) string {
+	t.Helper()
+	rec, err := qosapi.NewRecorder(opts)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	for i := range n {
+		msg := qosapi.RawMessage{
+			Time: base.Add(time.Duration(i) * time.Second),
+			Data: []byte(fmt.Sprintf(`{"type":"T","c":"US:AAPL","p":"%d"}`, i)),
+		}
+		if err := rec.Record(msg); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	files := rec.Files()
+	if len(files) != 1 {
+		t.Fatalf("recorded %d files, want 1", len(files))
+	}
+	return files[0]
+}
+
+func TestRecorderRecordAfterClose(t *testing.T) {
+	rec, err := qosapi.NewRecorder(qosapi.RecorderOptions{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	err = rec.Record(qosapi.RawMessage{Time: time.Now(), Data: []byte(`{"type":"T"}`)})
+	if !errors.Is(err, qosapi.ErrRecorderClosed) || errors.Is(err, qosapi.ErrClientClosed) {
+		t.Fatalf("Record after Close error = %v, want ErrRecorderClosed", err)
+	}
+}
+
+func TestRecordIndexIsSparse(t *testing.T) {
+	base := time.Date(2026, 1, 5, 14, 30, 0, 0, time.UTC)
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compress=%v", compress), func(t *testing.T) {
+			file := recordTrades(t, qosapi.RecorderOptions{Dir: t.TempDir(), Compress: compress}, base, 2500)
+
+			index, err := qosapi.ReadRecordIndex(file)
+			if err != nil {
+				t.Fatalf("ReadRecordIndex: %v", err)
+			}
+			if index.Count != 2500 || len(index.Entries) != 1 || index.Entries[0].Count != 2500 {
+				t.Fatalf("index counts = %d, %d entries", index.Count, len(index.Entries))
+			}
+			if len(index.Blocks) != 3 {
+				t.Fatalf("index has %d blocks, want 3", len(index.Blocks))
+			}
+			data, err := os.ReadFile(file + qosapi.RecordIndexFileExt)
+			if err != nil {
+				t.Fatalf("read index: %v", err)
+			}
+			if len(data) > 1024 {
+				t.Fatalf("index is %d bytes, want it independent of the record count", len(data))
+			}
+
+			for _, b := range index.Blocks {
+				rr, err := qosapi.OpenRecording(file)
+				if err != nil {
+					t.Fatalf("OpenRecording: %v", err)
+				}
+				if err := rr.SkipTo(b.Offset); err != nil {
+					t.Fatalf("SkipTo(%d): %v", b.Offset, err)
+				}
+				got, err := rr.Next()
+				rr.Close()
+				if err != nil {
+					t.Fatalf("Next after SkipTo(%d): %v", b.Offset, err)
+				}
+				if got.Time.UnixNano() != b.Time || !strings.Contains(string(got.Data), fmt.Sprintf(`"p":"%d"`, b.Seq)) {
+					t.Fatalf("block %d starts at %s %s", b.Seq, got.Time, got.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayerSkipsBlocksBeforeStart(t *testing.T) {
+	base := time.Date(2026, 1, 5, 14, 30, 0, 0, time.UTC)
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compress=%v", compress), func(t *testing.T) {
+			file := recordTrades(t, qosapi.RecorderOptions{Dir: t.TempDir(), Compress: compress}, base, 3000)
+
+			replayer, err := qosapi.NewReplayer([]string{file}, qosapi.ReplayOptions{
+				Start: base.Add(2000 * time.Second),
+				End:   base.Add(2100 * time.Second),
+			})
+			if err != nil {
+				t.Fatalf("NewReplayer: %v", err)
+			}
+			var prices []string
+			if _, err := replayer.OnTrade([]string{"US:AAPL"}, func(tr qosapi.WSTrade) { prices = append(prices, tr.Price) }); err != nil {
+				t.Fatalf("OnTrade: %v", err)
+			}
+			if err := replayer.Run(context.Background()); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if len(prices) != 100 || prices[0] != "2000" || prices[99] != "2099" {
+				t.Fatalf("replayed %d trades (%v...), want 2000..2099", len(prices), prices[:min(len(prices), 3)])
+			}
+		})
+	}
+}
+
+func TestReplayerStopsReadingAtEnd(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	file := recordTrades(t, qosapi.RecorderOptions{Dir: t.TempDir()}, base, 10)
+	// 没有索引，且End之后的数据已损坏：回放到End后不应再读取
+	if err := os.Remove(file + qosapi.RecordIndexFileExt); err != nil {
+		t.Fatalf("remove index: %v", err)
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	f.Write(binary.AppendUvarint(nil, 1<<62))
+	f.Close()
+
+	replayer, err := qosapi.NewReplayer([]string{file}, qosapi.ReplayOptions{End: base.Add(5 * time.Second)})
+	if err != nil {
+		t.Fatalf("NewReplayer: %v", err)
+	}
+	n := 0
+	if _, err := replayer.OnTrade([]string{"US:AAPL"}, func(qosapi.WSTrade) { n++ }); err != nil {
+		t.Fatalf("OnTrade: %v", err)
+	}
+	if err := replayer.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if n != 5 {
+		t.Fatalf("replayed %d trades, want 5", n)
+	}
+}
+
+func TestRecordReaderReadsAll(t *testing.T) {
+	file := recordTrades(t, qosapi.RecorderOptions{Dir: t.TempDir()}, time.Unix(1700000000, 0), 10)
+	rr, err := qosapi.OpenRecording(file)
+	if err != nil {
+		t.Fatalf("OpenRecording: %v", err)
+	}
+	defer rr.Close()
+	n := 0
+	for {
+		rec, err := rr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if rec.Type != "T" || rec.Code != "US:AAPL" {
+			t.Fatalf("record %d = %s %s", n, rec.Type, rec.Code)
+		}
+		n++
+	}
+	if n != 10 {
+		t.Fatalf("read %d records, want 10", n)
+	}
+}
+
+func TestRecordReaderRejectsCorruptLength(t *testing.T) {
+	tests := []struct {
+		name   string
+		tail   []byte
+		errMsg string
+	}{
+		{"huge length", binary.AppendUvarint(nil, 1<<62), "too large"},
+		{"length past end of file", append(binary.AppendUvarint(nil, 100), "short"...), io.ErrUnexpectedEOF.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := recordTrades(t, qosapi.RecorderOptions{Dir: t.TempDir()}, time.Unix(1700000000, 0), 3)
+			f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			f.Write(tt.tail)
+			f.Close()
+
+			rr, err := qosapi.OpenRecording(file)
+			if err != nil {
+				t.Fatalf("OpenRecording: %v", err)
+			}
+			defer rr.Close()
+			for i := range 3 {
+				if _, err := rr.Next(); err != nil {
+					t.Fatalf("Next %d: %v", i, err)
+				}
+			}
+			_, err = rr.Next()
+			if err == nil || !strings.Contains(err.Error(), "read record at") || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Fatalf("Next on corrupt record error = %v, want read record error containing %q", err, tt.errMsg)
+			}
+		})
+	}
+}
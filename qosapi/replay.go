@@ -0,0 +1,277 @@
+package qosapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// MarketDataSource 行情推送来源。WSClient和Replayer都实现了该接口，
+// 基于它编写的策略代码可以在实盘和回放之间切换
+type MarketDataSource interface {
+	OnSnapshot(codes []string, callback func(WSSnapshot)) (*Subscription, error)
+	OnTrade(codes []string, callback func(WSTrade)) (*Subscription, error)
+	OnDepth(codes []string, callback func(WSDepth)) (*Subscription, error)
+	OnKLine(codes []string, klineType int, callback func(WSKLine)) (*Subscription, error)
+}
+
+var (
+	_ MarketDataSource = (*WSClient)(nil)
+	_ MarketDataSource = (*Replayer)(nil)
+)
+
+// sourceLogger 返回行情来源的日志记录器
+func sourceLogger(src MarketDataSource) Logger {
+	switch s := src.(type) {
+	case *WSClient:
+		return s.logger
+	case *Replayer:
+		return s.opts.Logger
+	}
+	return nopLogger{}
+}
+
+// 回放速度
+const (
+	ReplayAsFastAsPossible = 0 // 不等待，尽快回放
+	ReplayRealTime         = 1 // 按录制时的时间间隔回放
+)
+
+// ReplayOptions 回放配置
+type ReplayOptions struct {
+	// Speed 回放速度倍数：1为实时，10为10倍速，小于等于0表示尽快回放
+	Speed float64
+
+	// Start、End 只回放接收时间在[Start, End)范围内的消息，零值表示不限制
+	Start time.Time
+	End   time.Time
+
+	// Codes 只回放这些代码的消息，为空表示全部
+	Codes []string
+
+	// Logger 记录无法解析的消息，为nil时不记录
+	Logger Logger
+}
+
+// Replayer 回放Recorder录制的文件，通过与WSClient相同的Subscribe*和On*回调分发推送
+type Replayer struct {
+	files    []string
+	opts     ReplayOptions
+	codes    map[string]struct{}
+	registry *subscriptionRegistry
+
+	mu      sync.Mutex
+	running bool
+}
+
+// NewReplayer 创建回放器，files按顺序回放，通常为Recorder.Files的结果
+func NewReplayer(files []string, opts ReplayOptions) (*Replayer, error) {
+	if opts.Logger == nil {
+		opts.Logger = nopLogger{}
+	}
+	r := &Replayer{
+		files:    files,
+		opts:     opts,
+		registry: newSubscriptionRegistry(opts.Logger),
+	}
+	if len(opts.Codes) > 0 {
+		symbols, err := parseCodes(opts.Codes)
+		if err != nil {
+			return nil, err
+		}
+		r.codes = make(map[string]struct{}, len(symbols))
+		for _, s := range symbols {
+			r.codes[s.String()] = struct{}{}
+		}
+	}
+	return r, nil
+}
+
+// subscribe 注册回调，回放时分发
+func (r *Replayer) subscribe(key subscriptionKey, codes []string, handler func(interface{})) (*Subscription, error) {
+	codes, err := NormalizeCodes(codes)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := r.registry.add(key, codes, handler)
+	return &Subscription{unsubscribe: func() error {
+		r.registry.remove(id)
+		return nil
+	}}, nil
+}
+
+// SubscribeSnapshot 订阅回放的快照
+func (r *Replayer) SubscribeSnapshot(codes []string, callback func(WSSnapshot)) error {
+	_, err := r.OnSnapshot(codes, callback)
+	return err
+}
+
+// OnSnapshot 订阅回放的快照，返回的Subscription用于取消本次注册的回调
+func (r *Replayer) OnSnapshot(codes []string, callback func(WSSnapshot)) (*Subscription, error) {
+	return r.subscribe(subscriptionKey{Type: "S"}, codes, func(data interface{}) {
+		callback(data.(WSSnapshot))
+	})
+}
+
+// SubscribeTrade 订阅回放的逐笔成交
+func (r *Replayer) SubscribeTrade(codes []string, callback func(WSTrade)) error {
+	_, err := r.OnTrade(codes, callback)
+	return err
+}
+
+// OnTrade 订阅回放的逐笔成交，返回的Subscription用于取消本次注册的回调
+func (r *Replayer) OnTrade(codes []string, callback func(WSTrade)) (*Subscription, error) {
+	return r.subscribe(subscriptionKey{Type: "T"}, codes, func(data interface{}) {
+		callback(data.(WSTrade))
+	})
+}
+
+// SubscribeDepth 订阅回放的盘口
+func (r *Replayer) SubscribeDepth(codes []string, callback func(WSDepth)) error {
+	_, err := r.OnDepth(codes, callback)
+	return err
+}
+
+// OnDepth 订阅回放的盘口，返回的Subscription用于取消本次注册的回调
+func (r *Replayer) OnDepth(codes []string, callback func(WSDepth)) (*Subscription, error) {
+	return r.subscribe(subscriptionKey{Type: "D"}, codes, func(data interface{}) {
+		callback(data.(WSDepth))
+	})
+}
+
+// SubscribeKLine 订阅回放的K线
+func (r *Replayer) SubscribeKLine(codes []string, klineType int, callback func(WSKLine)) error {
+	_, err := r.OnKLine(codes, klineType, callback)
+	return err
+}
+
+// OnKLine 订阅回放的K线，返回的Subscription用于取消本次注册的回调
+func (r *Replayer) OnKLine(codes []string, klineType int, callback func(WSKLine)) (*Subscription, error) {
+	return r.subscribe(subscriptionKey{Type: "K", KLineType: klineType}, codes, func(data interface{}) {
+		callback(data.(WSKLine))
+	})
+}
+
+// Run 回放所有文件，回调在调用Run的goroutine中执行。
+// 全部回放完成时返回nil，ctx取消时返回ctx.Err()。同一时间只能有一个Run在执行
+func (r *Replayer) Run(ctx context.Context) error {
+	r.mu.Lock()
+	if r.running {
+		r.mu.Unlock()
+		return errors.New("replayer is already running")
+	}
+	r.running = true
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.running = false
+		r.mu.Unlock()
+	}()
+
+	var clock replayClock
+	for _, file := range r.files {
+		// 没有索引(如录制中断)时index为nil，从头读取
+		index, _ := ReadRecordIndex(file)
+		if r.skipFile(index) {
+			continue
+		}
+		if err := r.replayFile(ctx, file, index, &clock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipFile 根据索引判断文件是否完全在时间范围之外，没有索引时不跳过
+func (r *Replayer) skipFile(index *RecordIndex) bool {
+	if index == nil || index.Count == 0 {
+		return false
+	}
+	if !r.opts.Start.IsZero() && index.Last < r.opts.Start.UnixNano() {
+		return true
+	}
+	return !r.opts.End.IsZero() && index.First >= r.opts.End.UnixNano()
+}
+
+// replayFile 回放一个文件，有索引且设置了Start时跳过Start之前的块，读到End之后的记录时结束
+func (r *Replayer) replayFile(ctx context.Context, file string, index *RecordIndex, clock *replayClock) error {
+	rr, err := OpenRecording(file)
+	if err != nil {
+		return err
+	}
+	defer rr.Close()
+
+	if index != nil && !r.opts.Start.IsZero() {
+		if err := rr.SkipTo(index.SeekOffset(r.opts.Start)); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rec, err := rr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if !r.opts.End.IsZero() && !rec.Time.Before(r.opts.End) {
+			// 记录按接收时间顺序写入，之后的记录都不早于End
+			return nil
+		}
+		if !r.match(rec) {
+			continue
+		}
+		if err := clock.wait(ctx, rec.Time, r.opts.Speed); err != nil {
+			return err
+		}
+		r.registry.dispatch(rec.Type, rec.Data)
+	}
+}
+
+// match 判断记录是否需要回放
+func (r *Replayer) match(rec Record) bool {
+	switch rec.Type {
+	case "S", "T", "D", "K":
+	default:
+		return false
+	}
+	if !r.opts.Start.IsZero() && rec.Time.Before(r.opts.Start) {
+		return false
+	}
+	if !r.opts.End.IsZero() && !rec.Time.Before(r.opts.End) {
+		return false
+	}
+	if r.codes != nil {
+		if _, ok := r.codes[rec.Code]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// replayClock 将录制时间映射为回放时间
+type replayClock struct {
+	started   bool
+	wallStart time.Time
+	recStart  time.Time
+}
+
+// wait 等待到录制时间t对应的回放时间
+func (c *replayClock) wait(ctx context.Context, t time.Time, speed float64) error {
+	if speed <= 0 {
+		return nil
+	}
+	if !c.started {
+		c.started, c.wallStart, c.recStart = true, time.Now(), t
+		return nil
+	}
+	target := c.wallStart.Add(time.Duration(float64(t.Sub(c.recStart)) / speed))
+	return sleepContext(ctx, time.Until(target))
+}
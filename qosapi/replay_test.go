@@ -0,0 +1,166 @@
+package qosapi_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+// recordTwoFiles 录制两个文件，每个文件n条逐笔成交，第i条的接收时间为base+i秒，价格为i，
+// 代码交替为US:AAPL和US:TSLA；第二个文件之后还有一条请求响应
+func recordTwoFiles(t *testing.T, opts qosapi.RecorderOptions, base time.Time, n int) []string {
+	t.Helper()
+	rec, err := qosapi.NewRecorder(opts)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	for i := range 2 * n {
+		if i == n {
+			if err := rec.Rotate(); err != nil {
+				t.Fatalf("Rotate: %v", err)
+			}
+		}
+		code := "US:AAPL"
+		if i%2 == 1 {
+			code = "US:TSLA"
+		}
+		msg := qosapi.RawMessage{
+			Time: base.Add(time.Duration(i) * time.Second),
+			Data: []byte(fmt.Sprintf(`{"type":"T","c":%q,"p":"%d"}`, code, i)),
+		}
+		if err := rec.Record(msg); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	rec.Record(qosapi.RawMessage{Time: base.Add(time.Duration(2*n) * time.Second), Data: []byte(`{"type":"RS","reqid":1}`)})
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return rec.Files()
+}
+
+func TestReplayerFilters(t *testing.T) {
+	const n = 1500
+	base := time.Date(2026, 1, 5, 14, 30, 0, 0, time.UTC)
+	sec := func(i int) time.Time { return base.Add(time.Duration(i) * time.Second) }
+
+	tests := []struct {
+		name        string
+		opts        qosapi.ReplayOptions
+		first, last int // 期望回放的第一条和最后一条的价格
+		count       int
+	}{
+		{"all", qosapi.ReplayOptions{}, 0, 2*n - 1, 2 * n},
+		{"start in first file", qosapi.ReplayOptions{Start: sec(1100)}, 1100, 2*n - 1, 2*n - 1100},
+		{"start in second file", qosapi.ReplayOptions{Start: sec(2500)}, 2500, 2*n - 1, 2*n - 2500},
+		{"end in first file", qosapi.ReplayOptions{End: sec(10)}, 0, 9, 10},
+		{"range across files", qosapi.ReplayOptions{Start: sec(1490), End: sec(1510)}, 1490, 1509, 20},
+		{"start after all records", qosapi.ReplayOptions{Start: sec(5000)}, -1, -1, 0},
+		{"codes", qosapi.ReplayOptions{Codes: []string{"us:tsla"}, Start: sec(2000), End: sec(2010)}, 2001, 2009, 5},
+	}
+	for _, compress := range []bool{false, true} {
+		for _, withIndex := range []bool{true, false} {
+			files := recordTwoFiles(t, qosapi.RecorderOptions{Dir: t.TempDir(), Compress: compress}, base, n)
+			if len(files) != 2 {
+				t.Fatalf("recorded %d files, want 2", len(files))
+			}
+			if !withIndex {
+				for _, f := range files {
+					if err := os.Remove(f + qosapi.RecordIndexFileExt); err != nil {
+						t.Fatalf("remove index: %v", err)
+					}
+				}
+			}
+			for _, tt := range tests {
+				t.Run(fmt.Sprintf("%s/compress=%v/index=%v", tt.name, compress, withIndex), func(t *testing.T) {
+					replayer, err := qosapi.NewReplayer(files, tt.opts)
+					if err != nil {
+						t.Fatalf("NewReplayer: %v", err)
+					}
+					var prices []int
+					if _, err := replayer.OnTrade([]string{"US:AAPL", "US:TSLA"}, func(tr qosapi.WSTrade) {
+						p, _ := strconv.Atoi(tr.Price)
+						prices = append(prices, p)
+					}); err != nil {
+						t.Fatalf("OnTrade: %v", err)
+					}
+					if err := replayer.Run(context.Background()); err != nil {
+						t.Fatalf("Run: %v", err)
+					}
+					if len(prices) != tt.count {
+						t.Fatalf("replayed %d trades, want %d", len(prices), tt.count)
+					}
+					if tt.count > 0 && (prices[0] != tt.first || prices[len(prices)-1] != tt.last) {
+						t.Fatalf("replayed %d..%d, want %d..%d", prices[0], prices[len(prices)-1], tt.first, tt.last)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestReplayerRunTwiceConcurrently(t *testing.T) {
+	files := recordTwoFiles(t, qosapi.RecorderOptions{Dir: t.TempDir()}, time.Unix(1700000000, 0), 5)
+	replayer, err := qosapi.NewReplayer(files, qosapi.ReplayOptions{Speed: qosapi.ReplayRealTime})
+	if err != nil {
+		t.Fatalf("NewReplayer: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	if _, err := replayer.OnTrade([]string{"US:AAPL"}, func(qosapi.WSTrade) {
+		select {
+		case <-started:
+		default:
+			close(started)
+		}
+	}); err != nil {
+		t.Fatalf("OnTrade: %v", err)
+	}
+	go func() { done <- replayer.Run(ctx) }()
+	<-started
+	if err := replayer.Run(context.Background()); err == nil {
+		t.Fatal("second concurrent Run succeeded")
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("Run after cancel = %v, want context.Canceled", err)
+	}
+}
+
+func TestRecordReaderSkipToErrors(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	for _, compress := range []bool{false, true} {
+		file := recordTrades(t, qosapi.RecorderOptions{Dir: t.TempDir(), Compress: compress}, base, 2000)
+		index, err := qosapi.ReadRecordIndex(file)
+		if err != nil {
+			t.Fatalf("ReadRecordIndex: %v", err)
+		}
+		rr, err := qosapi.OpenRecording(file)
+		if err != nil {
+			t.Fatalf("OpenRecording: %v", err)
+		}
+		if err := rr.SkipTo(0); err == nil {
+			t.Errorf("compress=%v: SkipTo inside the header succeeded", compress)
+		}
+		if err := rr.SkipTo(index.Blocks[1].Offset); err != nil {
+			t.Fatalf("compress=%v: SkipTo: %v", compress, err)
+		}
+		// 未压缩文件可以向回跳，压缩文件不能
+		err = rr.SkipTo(index.Blocks[0].Offset)
+		if (err == nil) == compress {
+			t.Errorf("compress=%v: SkipTo backwards error = %v", compress, err)
+		}
+		if !compress {
+			if rec, err := rr.Next(); err != nil || !rec.Time.Equal(base) {
+				t.Errorf("Next after seeking back = %v, %v, want the first record", rec.Time, err)
+			}
+		}
+		rr.Close()
+	}
+}
@@ -0,0 +1,192 @@
+package qosapi
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// isIntradayKLineType 是否为日内(分钟或小时)K线类型
+func isIntradayKLineType(klineType int) bool {
+	switch klineType {
+	case KLineTypeMin1, KLineTypeMin5, KLineTypeMin15, KLineTypeMin30,
+		KLineTypeHour1, KLineTypeHour2, KLineTypeHour4:
+		return true
+	}
+	return false
+}
+
+// canResample 是否可以由from类型的K线合成to类型的K线
+func canResample(from, to int) bool {
+	switch {
+	case isIntradayKLineType(to):
+		return isIntradayKLineType(from) && from < to && to%from == 0
+	case to == KLineTypeDay:
+		return isIntradayKLineType(from)
+	case to == KLineTypeWeek, to == KLineTypeMonth:
+		return isIntradayKLineType(from) || from == KLineTypeDay
+	case to == KLineTypeYear:
+		return isIntradayKLineType(from) || from == KLineTypeDay || from == KLineTypeMonth
+	}
+	return false
+}
+
+// ResampleKLines 将较细粒度的K线合成为klineType类型的K线，如1分钟K线合成为5分钟、日线或周线。
+// K线时间戳为K线开始时间，按代码所在市场的时区划分：
+//   - 日内K线在交易时段内从时段开始时间对齐，如港股60分钟K线为09:30、10:30、11:30(半小时)、13:00...；
+//     交易时段外的K线(如美股盘前盘后)从当地零点对齐
+//   - 日线按当地日期，周线从周一开始，月线和年线按当地自然月和自然年
+//
+// 输入可以包含多个代码和未排序的K线，同一代码时间戳重复的K线只保留最后一条。
+// 结果按代码首次出现的顺序分组，每个代码内按时间排序。KLineType为0的输入视为类型未知，不做校验
+func ResampleKLines(klines []KLine, klineType int) ([]KLine, error) {
+	var from int
+	for _, k := range klines {
+		if k.KLineType == 0 {
+			continue
+		}
+		if from != 0 && k.KLineType != from {
+			return nil, fmt.Errorf("cannot resample mixed kline types %d and %d", from, k.KLineType)
+		}
+		from = k.KLineType
+	}
+	if from != 0 && !canResample(from, klineType) {
+		return nil, fmt.Errorf("cannot resample kline type %d to %d", from, klineType)
+	}
+	if !isIntradayKLineType(klineType) && klineType != KLineTypeDay && klineType != KLineTypeWeek &&
+		klineType != KLineTypeMonth && klineType != KLineTypeYear {
+		return nil, fmt.Errorf("unknown kline type %d", klineType)
+	}
+
+	var result []KLine
+	for _, series := range groupKLinesByCode(MergeKLines(klines)) {
+		code := series[0].Code
+		sym, err := ParseSymbol(code)
+		loc, sessions := time.UTC, []Session(nil)
+		if err == nil {
+			loc, sessions = sym.Location(), sym.Sessions()
+		}
+
+		var cur *KLineDecimal
+		for _, k := range series {
+			dec, err := k.Decimal()
+			if err != nil {
+				return nil, fmt.Errorf("kline %s at %d: %w", k.Code, k.Timestamp, err)
+			}
+			start := resampleBucket(time.Unix(k.Timestamp, 0).In(loc), klineType, sessions)
+			if cur != nil && cur.Timestamp == start {
+				cur.Close = dec.Close
+				cur.High = MaxDecimal(cur.High, dec.High)
+				cur.Low = MinDecimal(cur.Low, dec.Low)
+				cur.Volume = cur.Volume.Add(dec.Volume)
+				continue
+			}
+			if cur != nil {
+				result = append(result, cur.KLine())
+			}
+			dec.Timestamp = start
+			dec.KLineType = klineType
+			cur = &dec
+		}
+		if cur != nil {
+			result = append(result, cur.KLine())
+		}
+	}
+	return result, nil
+}
+
+// resampleBucket 返回t所在klineType类型K线的开始时间
+func resampleBucket(t time.Time, klineType int, sessions []Session) int64 {
+	y, m, d := t.Date()
+	loc := t.Location()
+	switch klineType {
+	case KLineTypeDay:
+		return time.Date(y, m, d, 0, 0, 0, 0, loc).Unix()
+	case KLineTypeWeek:
+		offset := (int(t.Weekday()) + 6) % 7 // 周一为0
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, loc).Unix()
+	case KLineTypeMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc).Unix()
+	case KLineTypeYear:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc).Unix()
+	}
+
+	// 日内K线按当地时钟计算，不受夏令时切换影响
+	interval := time.Duration(klineType) * time.Minute
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	origin := time.Duration(0)
+	for _, s := range sessions {
+		if clock >= s.Start && clock < s.End {
+			origin = s.Start
+			break
+		}
+	}
+	start := origin + (clock - origin).Truncate(interval)
+	return time.Date(y, m, d, 0, 0, int(start/time.Second), 0, loc).Unix()
+}
+
+// groupKLinesByCode 按代码首次出现的顺序分组，不改变组内顺序
+func groupKLinesByCode(klines []KLine) [][]KLine {
+	var groups [][]KLine
+	index := make(map[string]int)
+	for _, k := range klines {
+		code := canonicalCode(k.Code)
+		i, ok := index[code]
+		if !ok {
+			i = len(groups)
+			index[code] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], k)
+	}
+	return groups
+}
+
+// MergeKLines 合并多组K线，同一代码、同一K线类型、同一时间戳的K线只保留最后出现的一条，
+// 因此后传入的序列优先，如MergeKLines(history, live)中实时K线覆盖历史K线。
+// 结果按代码首次出现的顺序分组，每个代码内按时间排序
+func MergeKLines(series ...[]KLine) []KLine {
+	type key struct {
+		code      string
+		klineType int
+		ts        int64
+	}
+	var merged []KLine
+	index := make(map[key]int)
+	for _, klines := range series {
+		for _, k := range klines {
+			kk := key{canonicalCode(k.Code), k.KLineType, k.Timestamp}
+			if i, ok := index[kk]; ok {
+				merged[i] = k
+				continue
+			}
+			index[kk] = len(merged)
+			merged = append(merged, k)
+		}
+	}
+
+	var result []KLine
+	for _, group := range groupKLinesByCode(merged) {
+		slices.SortStableFunc(group, func(a, b KLine) int {
+			return cmp.Or(cmp.Compare(a.Timestamp, b.Timestamp), cmp.Compare(a.KLineType, b.KLineType))
+		})
+		result = append(result, group...)
+	}
+	return result
+}
+
+// KLine 转换为KLine
+func (k WSKLine) KLine() KLine {
+	return KLine{
+		Code:      k.Code,
+		Open:      k.Open,
+		Close:     k.Close,
+		High:      k.High,
+		Low:       k.Low,
+		Volume:    k.Volume,
+		Timestamp: k.Timestamp,
+		KLineType: k.KLineType,
+	}
+}
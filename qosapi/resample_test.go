@@ -0,0 +1,186 @@
+package qosapi_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+// flatKLine 开高低收都为price的K线
+func flatKLine(code string, t time.Time, klineType int, price, volume string) qosapi.KLine {
+	return qosapi.KLine{Code: code, Open: price, High: price, Low: price, Close: price, Volume: volume, Timestamp: t.Unix(), KLineType: klineType}
+}
+
+// klineStarts 将K线格式化为"当地时间 开/高/低/收 量"
+func klineStarts(klines []qosapi.KLine, loc *time.Location) []string {
+	var result []string
+	for _, k := range klines {
+		result = append(result, fmt.Sprintf("%s %s/%s/%s/%s %s",
+			time.Unix(k.Timestamp, 0).In(loc).Format("01-02 15:04"), k.Open, k.High, k.Low, k.Close, k.Volume))
+	}
+	return result
+}
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	return loc
+}
+
+func TestResampleKLinesBoundaries(t *testing.T) {
+	hk, ny := mustLoad(t, "Asia/Hong_Kong"), mustLoad(t, "America/New_York")
+	min1 := func(code string, loc *time.Location, clocks ...string) []qosapi.KLine {
+		var klines []qosapi.KLine
+		for i, c := range clocks {
+			day, clock, _ := strings.Cut(c, " ")
+			ts, err := time.ParseInLocation("2006-01-02 15:04", "2026-"+day+" "+clock, loc)
+			if err != nil {
+				t.Fatalf("parse %q: %v", c, err)
+			}
+			klines = append(klines, flatKLine(code, ts, qosapi.KLineTypeMin1, fmt.Sprint(10+i), "1"))
+		}
+		return klines
+	}
+
+	tests := []struct {
+		name      string
+		klines    []qosapi.KLine
+		klineType int
+		loc       *time.Location
+		want      []string
+	}{
+		{
+			name:      "HK hourly aligned to session starts",
+			klines:    min1("HK:700", hk, "03-09 09:30", "03-09 10:29", "03-09 10:30", "03-09 11:59", "03-09 13:00", "03-09 13:59", "03-09 14:00"),
+			klineType: qosapi.KLineTypeHour1,
+			loc:       hk,
+			want: []string{
+				"03-09 09:30 10/11/10/11 2",
+				"03-09 10:30 12/12/12/12 1",
+				"03-09 11:30 13/13/13/13 1",
+				"03-09 13:00 14/15/14/15 2",
+				"03-09 14:00 16/16/16/16 1",
+			},
+		},
+		{
+			name:      "US extended hours aligned to midnight",
+			klines:    min1("US:AAPL", ny, "03-09 09:00", "03-09 09:29", "03-09 09:30", "03-09 15:59", "03-09 16:00", "03-09 16:59"),
+			klineType: qosapi.KLineTypeHour1,
+			loc:       ny,
+			want: []string{
+				"03-09 09:00 10/11/10/11 2",
+				"03-09 09:30 12/12/12/12 1",
+				"03-09 15:30 13/13/13/13 1",
+				"03-09 16:00 14/15/14/15 2",
+			},
+		},
+		{
+			name:      "US daily across DST change",
+			klines:    min1("US:AAPL", ny, "03-06 09:30", "03-06 15:59", "03-09 09:30", "03-09 15:59"),
+			klineType: qosapi.KLineTypeDay,
+			loc:       ny,
+			want:      []string{"03-06 00:00 10/11/10/11 2", "03-09 00:00 12/13/12/13 2"},
+		},
+		{
+			name: "weekly starts on Monday",
+			klines: []qosapi.KLine{
+				flatKLine("US:AAPL", time.Date(2026, 3, 6, 0, 0, 0, 0, ny), qosapi.KLineTypeDay, "1", "1"),
+				flatKLine("US:AAPL", time.Date(2026, 3, 9, 0, 0, 0, 0, ny), qosapi.KLineTypeDay, "2", "1"),
+				flatKLine("US:AAPL", time.Date(2026, 3, 13, 0, 0, 0, 0, ny), qosapi.KLineTypeDay, "3", "1"),
+				flatKLine("US:AAPL", time.Date(2026, 3, 16, 0, 0, 0, 0, ny), qosapi.KLineTypeDay, "4", "1"),
+			},
+			klineType: qosapi.KLineTypeWeek,
+			loc:       ny,
+			want:      []string{"03-02 00:00 1/1/1/1 1", "03-09 00:00 2/3/2/3 2", "03-16 00:00 4/4/4/4 1"},
+		},
+		{
+			name: "monthly and unsorted with duplicates",
+			klines: []qosapi.KLine{
+				flatKLine("US:AAPL", time.Date(2026, 3, 2, 0, 0, 0, 0, ny), qosapi.KLineTypeDay, "5", "1"),
+				flatKLine("US:AAPL", time.Date(2026, 2, 27, 0, 0, 0, 0, ny), qosapi.KLineTypeDay, "2", "1"),
+				flatKLine("US:AAPL", time.Date(2026, 2, 2, 0, 0, 0, 0, ny), qosapi.KLineTypeDay, "1", "1"),
+				flatKLine("US:AAPL", time.Date(2026, 2, 27, 0, 0, 0, 0, ny), qosapi.KLineTypeDay, "3", "2"), // 覆盖前一条
+			},
+			klineType: qosapi.KLineTypeMonth,
+			loc:       ny,
+			want:      []string{"02-01 00:00 1/3/1/3 3", "03-01 00:00 5/5/5/5 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := qosapi.ResampleKLines(tt.klines, tt.klineType)
+			if err != nil {
+				t.Fatalf("ResampleKLines: %v", err)
+			}
+			if g := klineStarts(got, tt.loc); strings.Join(g, "; ") != strings.Join(tt.want, "; ") {
+				t.Fatalf("ResampleKLines =\n%s\nwant\n%s", strings.Join(g, "\n"), strings.Join(tt.want, "\n"))
+			}
+			for _, k := range got {
+				if k.KLineType != tt.klineType {
+					t.Fatalf("KLineType = %d, want %d", k.KLineType, tt.klineType)
+				}
+			}
+		})
+	}
+}
+
+func TestResampleKLinesRejectsInvalidConversions(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	tests := []struct {
+		name      string
+		klines    []qosapi.KLine
+		klineType int
+	}{
+		{"finer target", []qosapi.KLine{flatKLine("US:AAPL", at, qosapi.KLineTypeMin5, "1", "1")}, qosapi.KLineTypeMin1},
+		{"not a multiple", []qosapi.KLine{flatKLine("US:AAPL", at, qosapi.KLineTypeMin15, "1", "1")}, qosapi.KLineTypeHour2 + 5},
+		{"week to month", []qosapi.KLine{flatKLine("US:AAPL", at, qosapi.KLineTypeWeek, "1", "1")}, qosapi.KLineTypeMonth},
+		{"mixed types", []qosapi.KLine{
+			flatKLine("US:AAPL", at, qosapi.KLineTypeMin1, "1", "1"),
+			flatKLine("US:AAPL", at.Add(time.Hour), qosapi.KLineTypeMin5, "1", "1"),
+		}, qosapi.KLineTypeHour1},
+		{"unknown target", []qosapi.KLine{{Code: "US:AAPL", Timestamp: at.Unix()}}, 7},
+		{"invalid price", []qosapi.KLine{flatKLine("US:AAPL", at, qosapi.KLineTypeMin1, "x", "1")}, qosapi.KLineTypeMin5},
+	}
+	for _, tt := range tests {
+		if _, err := qosapi.ResampleKLines(tt.klines, tt.klineType); err == nil {
+			t.Errorf("%s: ResampleKLines succeeded, want error", tt.name)
+		}
+	}
+}
+
+func TestMergeKLines(t *testing.T) {
+	k := func(code string, ts int64, klineType int, close string) qosapi.KLine {
+		return qosapi.KLine{Code: code, Timestamp: ts, KLineType: klineType, Close: close}
+	}
+	history := []qosapi.KLine{
+		k("HK:00700", 60, qosapi.KLineTypeMin1, "h1"),
+		k("US:AAPL", 120, qosapi.KLineTypeMin1, "h2"),
+		k("US:AAPL", 60, qosapi.KLineTypeMin1, "h3"),
+	}
+	live := []qosapi.KLine{
+		k("us:aapl", 120, qosapi.KLineTypeMin1, "l1"), // 覆盖历史K线
+		k("US:AAPL", 120, qosapi.KLineTypeMin5, "l2"), // 类型不同，保留
+		k("HK:700", 0, qosapi.KLineTypeMin1, "l3"),
+		k("US:AAPL", 180, qosapi.KLineTypeMin1, "l4"),
+	}
+	var got []string
+	for _, kl := range qosapi.MergeKLines(history, nil, live) {
+		got = append(got, fmt.Sprintf("%s@%d/%d=%s", kl.Code, kl.Timestamp, kl.KLineType, kl.Close))
+	}
+	want := []string{
+		"HK:700@0/1=l3", "HK:00700@60/1=h1",
+		"US:AAPL@60/1=h3", "us:aapl@120/1=l1", "US:AAPL@120/5=l2", "US:AAPL@180/1=l4",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("MergeKLines =\n%v\nwant\n%v", got, want)
+	}
+	if got := qosapi.MergeKLines(); len(got) != 0 {
+		t.Fatalf("MergeKLines() = %v, want empty", got)
+	}
+}
@@ -0,0 +1,130 @@
+package qosapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy 请求重试策略
+type RetryPolicy struct {
+	MaxAttempts          int                  // 最大尝试次数(包含首次请求)，小于等于1表示不重试
+	BaseDelay            time.Duration        // 首次重试前的等待时间，之后每次翻倍
+	MaxDelay             time.Duration        // 单次等待时间上限(包括Retry-After)，0表示不限制
+	Jitter               float64              // 随机抖动比例(0~1)，等待时间在[d*(1-Jitter), d]之间随机
+	RetryableStatusCodes []int                // 需要重试的HTTP状态码，为空时使用APIError.Retryable判断
+	RetryOn              func(err error) bool // 自定义是否重试，为nil时使用默认规则
+	HonorRetryAfter      bool                 // 是否遵循服务端返回的Retry-After，等待时间不超过MaxDelay
+}
+
+// DefaultRetryPolicy 返回默认重试策略：最多3次尝试，指数退避，50%抖动
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:     3,
+		BaseDelay:       200 * time.Millisecond,
+		MaxDelay:        5 * time.Second,
+		Jitter:          0.5,
+		HonorRetryAfter: true,
+	}
+}
+
+// shouldRetry 判断错误是否可以重试
+func (p *RetryPolicy) shouldRetry(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if p.RetryOn != nil {
+		return p.RetryOn(err)
+	}
+	return IsRetryable(err, p.RetryableStatusCodes...)
+}
+
+// delay 计算第attempt次(从1开始)重试前的等待时间
+func (p *RetryPolicy) delay(attempt int, err error) time.Duration {
+	if p.HonorRetryAfter {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			if p.MaxDelay > 0 && apiErr.RetryAfter > p.MaxDelay {
+				return p.MaxDelay
+			}
+			return apiErr.RetryAfter
+		}
+	}
+
+	return backoffDelay(p.BaseDelay, p.MaxDelay, p.Jitter, attempt)
+}
+
+// backoffDelay 计算第attempt次(从1开始)指数退避的等待时间
+func backoffDelay(base, maxDelay time.Duration, jitter float64, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && (maxDelay <= 0 || d < maxDelay); i++ {
+		d *= 2
+	}
+	if maxDelay > 0 && d > maxDelay {
+		d = maxDelay
+	}
+	if jitter > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * min(jitter, 1) * float64(d))
+	}
+	return d
+}
+
+// IsRetryable 判断错误是否为可重试的临时错误：可重试的APIError、网络超时、连接被重置或拒绝、
+// 响应被意外截断。TLS证书错误、URL错误、域名解析失败等不会因重试而恢复的错误不重试。
+// 指定statusCodes时，APIError仅在状态码属于statusCodes时可重试
+func IsRetryable(err error, statusCodes ...int) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if len(statusCodes) > 0 {
+			return slices.Contains(statusCodes, apiErr.StatusCode)
+		}
+		return apiErr.Retryable
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	// http.Client返回的所有错误都是实现了net.Error的*url.Error，只有超时才重试
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// parseRetryAfter 解析Retry-After响应头，支持秒数和HTTP日期两种格式
+func parseRetryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// sleepContext 等待d时长，ctx结束时提前返回ctx的错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
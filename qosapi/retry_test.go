@@ -0,0 +1,139 @@
+package qosapi_test
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+	"github.com/qos-max/qos-quote-api-go-sdk/qostest"
+)
+
+func fastRetryPolicy(attempts int) *qosapi.RetryPolicy {
+	return &qosapi.RetryPolicy{MaxAttempts: attempts, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+}
+
+func TestRetryTransientServerError(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	srv.SetSnapshot(qosapi.Snapshot{Code: "US:AAPL", LastPrice: "200"})
+	srv.InjectError("/snapshot", http.StatusServiceUnavailable, "unavailable", 2)
+
+	client := srv.Client(qosapi.WithRetryPolicy(fastRetryPolicy(3)))
+	snapshots, err := client.GetSnapshot([]string{"US:AAPL"})
+	if err != nil {
+		t.Fatalf("GetSnapshot: %v", err)
+	}
+	if len(snapshots) != 1 || snapshots[0].LastPrice != "200" {
+		t.Fatalf("snapshots = %+v", snapshots)
+	}
+	if n := srv.RequestCount("/snapshot"); n != 3 {
+		t.Fatalf("requests = %d, want 3", n)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	srv.InjectError("/snapshot", http.StatusBadGateway, "bad gateway", 0)
+
+	client := srv.Client(qosapi.WithRetryPolicy(fastRetryPolicy(4)))
+	_, err := client.GetSnapshot([]string{"US:AAPL"})
+	if !errors.Is(err, qosapi.ErrServerError) {
+		t.Fatalf("err = %v, want ErrServerError", err)
+	}
+	if n := srv.RequestCount("/snapshot"); n != 4 {
+		t.Fatalf("requests = %d, want 4", n)
+	}
+}
+
+func TestRetrySkipsPermanentErrors(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	srv.InjectError("/snapshot", http.StatusUnauthorized, "invalid api key", 0)
+
+	client := srv.Client(qosapi.WithRetryPolicy(fastRetryPolicy(5)))
+	_, err := client.GetSnapshot([]string{"US:AAPL"})
+	if !errors.Is(err, qosapi.ErrAuthFailed) {
+		t.Fatalf("err = %v, want ErrAuthFailed", err)
+	}
+	if n := srv.RequestCount("/snapshot"); n != 1 {
+		t.Fatalf("requests = %d, want 1", n)
+	}
+}
+
+func TestRetryAfterIsCappedByMaxDelay(t *testing.T) {
+	var calls int
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"msg":"too many requests"}`)
+			return
+		}
+		fmt.Fprint(w, `{"msg":"OK","data":[]}`)
+	}))
+	defer hs.Close()
+
+	policy := fastRetryPolicy(2)
+	policy.MaxDelay = 20 * time.Millisecond
+	policy.HonorRetryAfter = true
+	client := qosapi.NewClient("key", qosapi.WithBaseURL(hs.URL), qosapi.WithRetryPolicy(policy))
+
+	start := time.Now()
+	if _, err := client.GetSnapshot([]string{"US:AAPL"}); err != nil {
+		t.Fatalf("GetSnapshot: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("retry waited %v, want Retry-After capped at MaxDelay", elapsed)
+	}
+	if calls != 2 {
+		t.Fatalf("calls = %d, want 2", calls)
+	}
+}
+
+// timeoutError 模拟网络超时
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsRetryable(t *testing.T) {
+	urlErr := func(err error) error {
+		return &url.Error{Op: "Post", URL: "https://api.qos.hk/snapshot", Err: err}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"timeout", urlErr(timeoutError{}), true},
+		{"connection reset", urlErr(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), true},
+		{"connection refused", urlErr(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), true},
+		{"truncated response", urlErr(io.ErrUnexpectedEOF), true},
+		{"unknown host", urlErr(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "api.qos.hk", IsNotFound: true}}), false},
+		{"certificate", urlErr(x509.UnknownAuthorityError{}), false},
+		{"unsupported scheme", urlErr(errors.New("unsupported protocol scheme")), false},
+		{"server error", &qosapi.APIError{StatusCode: 503, Retryable: true}, true},
+		{"bad request", &qosapi.APIError{StatusCode: 400}, false},
+	}
+	for _, tt := range tests {
+		if got := qosapi.IsRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: IsRetryable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if qosapi.IsRetryable(&qosapi.APIError{StatusCode: 503, Retryable: true}, 429) {
+		t.Error("status codes filter: 503 should not be retryable when only 429 is listed")
+	}
+}
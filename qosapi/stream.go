@@ -0,0 +1,263 @@
+package qosapi
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy 数据流缓冲区满时的处理方式
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota // 阻塞推送直到缓冲区有空间，会阻塞该连接上的所有推送
+	OverflowDropOldest                       // 丢弃缓冲区中最早的一条
+	OverflowDropNewest                       // 丢弃新到达的一条
+	OverflowCoalesce                         // 同一代码在缓冲区中只保留最新一条，缓冲区满时丢弃最早的一条
+)
+
+// DefaultStreamBufferSize 数据流默认缓冲区大小
+const DefaultStreamBufferSize = 256
+
+// streamConfig 数据流配置
+type streamConfig struct {
+	bufferSize int
+	overflow   OverflowPolicy
+}
+
+// StreamOption 数据流配置项
+type StreamOption func(*streamConfig)
+
+// StreamBufferSize 设置缓冲区大小，默认为DefaultStreamBufferSize
+func StreamBufferSize(size int) StreamOption {
+	return func(cfg *streamConfig) {
+		if size > 0 {
+			cfg.bufferSize = size
+		}
+	}
+}
+
+// StreamOverflow 设置缓冲区满时的处理方式，默认为OverflowBlock
+func StreamOverflow(policy OverflowPolicy) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.overflow = policy
+	}
+}
+
+// Stream 基于channel的订阅数据流，推送在独立的goroutine中投递，慢消费者不会阻塞回调
+// (OverflowBlock策略除外)。ctx结束、调用Close、WSClient被关闭或断线后不再重连时取消订阅并关闭C，
+// 结束原因可通过Err获取
+type Stream[T any] struct {
+	C <-chan T // 接收推送数据，数据流结束时被关闭
+
+	out      chan T
+	key      func(T) string
+	size     int
+	overflow OverflowPolicy
+	dropped  atomic.Uint64
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []T
+	closed bool
+	err    error
+
+	sub       *Subscription
+	detach    func()
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func newStream[T any](key func(T) string, opts []StreamOption) *Stream[T] {
+	cfg := streamConfig{bufferSize: DefaultStreamBufferSize}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	out := make(chan T)
+	s := &Stream[T]{
+		C:        out,
+		out:      out,
+		key:      key,
+		size:     cfg.bufferSize,
+		overflow: cfg.overflow,
+		queue:    make([]T, 0, cfg.bufferSize),
+		done:     make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// start 开始投递数据，并在ctx结束或客户端关闭时关闭数据流
+func (s *Stream[T]) start(ctx context.Context, c *WSClient, sub *Subscription) {
+	s.sub = sub
+	go s.pump()
+	detach, ok := c.addStream(func(err error) { s.close(err) })
+	if !ok {
+		s.close(ErrClientClosed)
+		return
+	}
+	s.mu.Lock()
+	s.detach = detach
+	s.mu.Unlock()
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.close(ctx.Err())
+		case <-s.done:
+		}
+	}()
+}
+
+// Dropped 返回因缓冲区满而被丢弃(或被合并)的消息数
+func (s *Stream[T]) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Err 返回数据流结束的原因：ctx结束时为ctx.Err()，WSClient被关闭时为ErrClientClosed，
+// 断线后不再重连时为包装了ErrConnectionLost的错误。数据流未结束或通过Close关闭时返回nil
+func (s *Stream[T]) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close 取消订阅并关闭数据流，多次调用是安全的
+func (s *Stream[T]) Close() error {
+	return s.close(nil)
+}
+
+// close 以cause为结束原因关闭数据流，C关闭前已设置好Err的返回值
+func (s *Stream[T]) close(cause error) error {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.err = cause
+		s.queue = nil
+		detach := s.detach
+		s.cond.Broadcast()
+		s.mu.Unlock()
+
+		close(s.done)
+		if detach != nil {
+			detach()
+		}
+		if s.sub != nil {
+			s.closeErr = s.sub.Unsubscribe()
+		}
+	})
+	return s.closeErr
+}
+
+// push 将推送数据放入缓冲区，在读取goroutine中调用
+func (s *Stream[T]) push(v T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	if s.overflow == OverflowCoalesce {
+		k := s.key(v)
+		for i := range s.queue {
+			if s.key(s.queue[i]) == k {
+				s.queue[i] = v
+				s.dropped.Add(1)
+				return
+			}
+		}
+	}
+
+	if len(s.queue) >= s.size {
+		switch s.overflow {
+		case OverflowBlock:
+			for len(s.queue) >= s.size && !s.closed {
+				s.cond.Wait()
+			}
+			if s.closed {
+				return
+			}
+		case OverflowDropNewest:
+			s.dropped.Add(1)
+			return
+		default:
+			s.queue = s.queue[1:]
+			s.dropped.Add(1)
+		}
+	}
+
+	s.queue = append(s.queue, v)
+	s.cond.Broadcast()
+}
+
+// pump 将缓冲区中的数据依次投递到C
+func (s *Stream[T]) pump() {
+	defer close(s.out)
+
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		v := s.queue[0]
+		s.queue = s.queue[1:]
+		s.cond.Broadcast()
+		s.mu.Unlock()
+
+		select {
+		case s.out <- v:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// SnapshotStream 以channel形式订阅实时快照
+func (c *WSClient) SnapshotStream(ctx context.Context, codes []string, opts ...StreamOption) (*Stream[WSSnapshot], error) {
+	s := newStream(func(v WSSnapshot) string { return v.Code }, opts)
+	sub, err := c.OnSnapshot(codes, s.push)
+	if err != nil {
+		return nil, err
+	}
+	s.start(ctx, c, sub)
+	return s, nil
+}
+
+// TradeStream 以channel形式订阅实时逐笔成交
+func (c *WSClient) TradeStream(ctx context.Context, codes []string, opts ...StreamOption) (*Stream[WSTrade], error) {
+	s := newStream(func(v WSTrade) string { return v.Code }, opts)
+	sub, err := c.OnTrade(codes, s.push)
+	if err != nil {
+		return nil, err
+	}
+	s.start(ctx, c, sub)
+	return s, nil
+}
+
+// DepthStream 以channel形式订阅实时盘口
+func (c *WSClient) DepthStream(ctx context.Context, codes []string, opts ...StreamOption) (*Stream[WSDepth], error) {
+	s := newStream(func(v WSDepth) string { return v.Code }, opts)
+	sub, err := c.OnDepth(codes, s.push)
+	if err != nil {
+		return nil, err
+	}
+	s.start(ctx, c, sub)
+	return s, nil
+}
+
+// KLineStream 以channel形式订阅实时K线
+func (c *WSClient) KLineStream(ctx context.Context, codes []string, klineType int, opts ...StreamOption) (*Stream[WSKLine], error) {
+	s := newStream(func(v WSKLine) string { return v.Code }, opts)
+	sub, err := c.OnKLine(codes, klineType, s.push)
+	if err != nil {
+		return nil, err
+	}
+	s.start(ctx, c, sub)
+	return s, nil
+}
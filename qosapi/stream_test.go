@@ -0,0 +1,158 @@
+package qosapi_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+	"github.com/qos-max/qos-quote-api-go-sdk/qostest"
+)
+
+// drain 读取C直到关闭，超时后测试失败
+func drain[T any](t *testing.T, s *qosapi.Stream[T]) []T {
+	t.Helper()
+	var got []T
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case v, ok := <-s.C:
+			if !ok {
+				return got
+			}
+			got = append(got, v)
+		case <-timeout:
+			t.Fatal("stream was not closed")
+		}
+	}
+}
+
+func TestStreamClosesWhenClientCloses(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := connectWS(t, srv)
+
+	stream, err := client.TradeStream(context.Background(), []string{"US:AAPL"})
+	if err != nil {
+		t.Fatalf("TradeStream: %v", err)
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("Err before close = %v, want nil", err)
+	}
+
+	client.Close()
+	drain(t, stream)
+	if err := stream.Err(); !errors.Is(err, qosapi.ErrClientClosed) {
+		t.Fatalf("Err = %v, want ErrClientClosed", err)
+	}
+}
+
+func TestStreamClosesOnContextCancel(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := connectWS(t, srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.SnapshotStream(ctx, []string{"US:AAPL"})
+	if err != nil {
+		t.Fatalf("SnapshotStream: %v", err)
+	}
+	waitFor(t, "upstream subscription", func() bool { return srv.Subscribed("S", "US:AAPL") })
+
+	cancel()
+	drain(t, stream)
+	if err := stream.Err(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Err = %v, want context.Canceled", err)
+	}
+	waitFor(t, "unsubscribe", func() bool { return !srv.Subscribed("S", "US:AAPL") })
+}
+
+func TestStreamClosesWhenConnectionLostWithoutReconnect(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := connectWS(t, srv)
+	client.SetReconnectPolicy(nil)
+
+	stream, err := client.DepthStream(context.Background(), []string{"US:AAPL"})
+	if err != nil {
+		t.Fatalf("DepthStream: %v", err)
+	}
+	srv.Disconnect()
+	drain(t, stream)
+	if err := stream.Err(); !errors.Is(err, qosapi.ErrConnectionLost) {
+		t.Fatalf("Err = %v, want ErrConnectionLost", err)
+	}
+}
+
+func TestStreamCloseReportsNoError(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := connectWS(t, srv)
+
+	stream, err := client.TradeStream(context.Background(), []string{"US:AAPL"})
+	if err != nil {
+		t.Fatalf("TradeStream: %v", err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	drain(t, stream)
+	client.Close()
+	if err := stream.Err(); err != nil {
+		t.Fatalf("Err after Close = %v, want nil", err)
+	}
+}
+
+func TestStreamOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      qosapi.OverflowPolicy
+		codes       []string
+		wantPrices  []string
+		wantDropped uint64
+	}{
+		{"DropNewest", qosapi.OverflowDropNewest, []string{"US:AAPL", "US:AAPL", "US:AAPL", "US:AAPL", "US:AAPL"}, []string{"0", "1", "2"}, 2},
+		{"DropOldest", qosapi.OverflowDropOldest, []string{"US:AAPL", "US:AAPL", "US:AAPL", "US:AAPL", "US:AAPL"}, []string{"0", "3", "4"}, 2},
+		{"Coalesce", qosapi.OverflowCoalesce, []string{"US:AAPL", "US:TSLA", "US:AAPL", "US:TSLA", "US:AAPL"}, []string{"0", "3", "4"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := qostest.NewServer()
+			defer srv.Close()
+			client := connectWS(t, srv)
+
+			stream, err := client.TradeStream(context.Background(), []string{"US:AAPL", "US:TSLA"},
+				qosapi.StreamBufferSize(2), qosapi.StreamOverflow(tt.policy))
+			if err != nil {
+				t.Fatalf("TradeStream: %v", err)
+			}
+			defer stream.Close()
+			waitFor(t, "upstream subscription", func() bool {
+				return srv.Subscribed("T", "US:AAPL") && srv.Subscribed("T", "US:TSLA")
+			})
+
+			// 第一条被投递goroutine取出并阻塞在C上，其余进入容量为2的缓冲区
+			srv.PushTrade(qosapi.WSTrade{Code: tt.codes[0], Price: "0"})
+			time.Sleep(20 * time.Millisecond)
+			for i, code := range tt.codes[1:] {
+				srv.PushTrade(qosapi.WSTrade{Code: code, Price: fmt.Sprint(i + 1)})
+			}
+			waitFor(t, "overflow", func() bool { return stream.Dropped() == tt.wantDropped })
+
+			var got []string
+			for range tt.wantPrices {
+				select {
+				case v := <-stream.C:
+					got = append(got, v.Price)
+				case <-time.After(2 * time.Second):
+					t.Fatalf("got %v, want %v", got, tt.wantPrices)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantPrices) {
+				t.Fatalf("prices = %v, want %v", got, tt.wantPrices)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package qosapi
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Subscription 订阅句柄，通过Unsubscribe取消本次订阅注册的回调
+type Subscription struct {
+	once        sync.Once
+	unsubscribe func() error
+	err         error
+}
+
+// Unsubscribe 取消订阅，只移除本次订阅注册的回调。
+// 当某个代码不再有任何回调时，才会向服务端发送取消订阅请求。多次调用是安全的
+func (s *Subscription) Unsubscribe() error {
+	s.once.Do(func() {
+		if s.unsubscribe != nil {
+			s.err = s.unsubscribe()
+		}
+	})
+	return s.err
+}
+
+// subscriptionKey 订阅的数据类型，K线订阅还需区分K线类型
+type subscriptionKey struct {
+	Type      string
+	KLineType int
+}
+
+// registryEntry 一次订阅注册的回调
+type registryEntry struct {
+	id      uint64
+	key     subscriptionKey
+	codes   []string
+	handler func(interface{})
+}
+
+// subscriptionRegistry 按数据类型和代码管理订阅回调，同一代码可以有多个回调
+type subscriptionRegistry struct {
+	logger  Logger
+	mu      sync.RWMutex
+	nextID  uint64
+	entries map[uint64]*registryEntry
+	index   map[subscriptionKey]map[string]map[uint64]*registryEntry
+}
+
+func newSubscriptionRegistry(logger Logger) *subscriptionRegistry {
+	return &subscriptionRegistry{
+		logger:  logger,
+		entries: make(map[uint64]*registryEntry),
+		index:   make(map[subscriptionKey]map[string]map[uint64]*registryEntry),
+	}
+}
+
+// add 注册回调，返回注册ID以及此前没有任何回调的代码(需要向服务端订阅)
+func (r *subscriptionRegistry) add(key subscriptionKey, codes []string, handler func(interface{})) (uint64, []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	entry := &registryEntry{
+		id:      r.nextID,
+		key:     key,
+		codes:   uniqueCodes(splitCodes(codes)),
+		handler: handler,
+	}
+	r.entries[entry.id] = entry
+
+	byCode, ok := r.index[key]
+	if !ok {
+		byCode = make(map[string]map[uint64]*registryEntry)
+		r.index[key] = byCode
+	}
+
+	var added []string
+	for _, code := range entry.codes {
+		handlers, ok := byCode[code]
+		if !ok {
+			handlers = make(map[uint64]*registryEntry)
+			byCode[code] = handlers
+			added = append(added, code)
+		}
+		handlers[entry.id] = entry
+	}
+	return entry.id, added
+}
+
+// remove 移除一次注册，返回不再有任何回调的代码(需要向服务端取消订阅)
+func (r *subscriptionRegistry) remove(id uint64) (subscriptionKey, []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.entries[id]
+	if !ok {
+		return subscriptionKey{}, nil
+	}
+	delete(r.entries, id)
+
+	byCode := r.index[entry.key]
+	var removed []string
+	for _, code := range entry.codes {
+		handlers := byCode[code]
+		delete(handlers, id)
+		if len(handlers) == 0 {
+			delete(byCode, code)
+			removed = append(removed, code)
+		}
+	}
+	if len(byCode) == 0 {
+		delete(r.index, entry.key)
+	}
+	return entry.key, removed
+}
+
+// removeCodes 移除指定代码上的所有回调
+func (r *subscriptionRegistry) removeCodes(key subscriptionKey, codes []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byCode := r.index[key]
+	for _, code := range splitCodes(codes) {
+		for id, entry := range byCode[code] {
+			entry.codes = slices.DeleteFunc(entry.codes, func(c string) bool { return c == code })
+			if len(entry.codes) == 0 {
+				delete(r.entries, id)
+			}
+		}
+		delete(byCode, code)
+	}
+	if len(byCode) == 0 {
+		delete(r.index, key)
+	}
+}
+
+// subscribed 返回当前所有有回调的订阅，用于重连后恢复
+func (r *subscriptionRegistry) subscribed() map[subscriptionKey][]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(map[subscriptionKey][]string, len(r.index))
+	for key, byCode := range r.index {
+		codes := make([]string, 0, len(byCode))
+		for code := range byCode {
+			codes = append(codes, code)
+		}
+		slices.Sort(codes)
+		result[key] = codes
+	}
+	return result
+}
+
+// handlers 返回某个代码上的所有回调，按注册顺序排列
+func (r *subscriptionRegistry) handlers(key subscriptionKey, code string) []func(interface{}) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := r.index[key][code]
+	if len(entries) == 0 {
+		return nil
+	}
+	ids := make([]uint64, 0, len(entries))
+	for id := range entries {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	result := make([]func(interface{}), len(ids))
+	for i, id := range ids {
+		result[i] = entries[id].handler
+	}
+	return result
+}
+
+// dispatch 解析推送消息并分发给对应代码的回调，typ为推送类型S/T/D/K
+func (r *subscriptionRegistry) dispatch(typ string, message []byte) {
+	var (
+		data interface{}
+		key  = subscriptionKey{Type: typ}
+		code string
+		err  error
+	)
+
+	switch typ {
+	case "S":
+		var snapshot WSSnapshot
+		err = json.Unmarshal(message, &snapshot)
+		data, code = snapshot, snapshot.Code
+	case "T":
+		var trade WSTrade
+		err = json.Unmarshal(message, &trade)
+		data, code = trade, trade.Code
+	case "D":
+		var depth WSDepth
+		err = json.Unmarshal(message, &depth)
+		data, code = depth, depth.Code
+	case "K":
+		var kline WSKLine
+		err = json.Unmarshal(message, &kline)
+		data, code = kline, kline.Code
+		key.KLineType = kline.KLineType
+	default:
+		return
+	}
+
+	if err != nil {
+		r.logger.Printf("Failed to unmarshal %s message: %v", typ, err)
+		return
+	}
+	if code == "" {
+		return
+	}
+
+	handlers := r.handlers(key, code)
+	if handlers == nil {
+		// 推送的代码格式可能与订阅时不同，如HK:700与HK:00700
+		if canonical := canonicalCode(code); canonical != code {
+			handlers = r.handlers(key, canonical)
+		}
+	}
+	for _, handler := range handlers {
+		handler(data)
+	}
+}
+
+// uniqueCodes 去除重复代码，保留首次出现的顺序
+func uniqueCodes(codes []string) []string {
+	seen := make(map[string]struct{}, len(codes))
+	result := codes[:0]
+	for _, code := range codes {
+		if _, ok := seen[code]; ok {
+			continue
+		}
+		seen[code] = struct{}{}
+		result = append(result, code)
+	}
+	return result
+}
+
+// splitCodes 将"US:AAPL,TSLA"形式的代码拆分为"US:AAPL"、"US:TSLA"
+func splitCodes(codes []string) []string {
+	result := make([]string, 0, len(codes))
+	for _, item := range codes {
+		market, tickers, ok := strings.Cut(item, ":")
+		if !ok {
+			result = append(result, item)
+			continue
+		}
+		for _, ticker := range strings.Split(tickers, ",") {
+			if ticker = strings.TrimSpace(ticker); ticker != "" {
+				result = append(result, market+":"+ticker)
+			}
+		}
+	}
+	return result
+}
+
+// groupCodes 将"US:AAPL"、"US:TSLA"形式的代码按市场合并为"US:AAPL,TSLA"
+func groupCodes(codes []string) []string {
+	var markets []string
+	tickers := make(map[string][]string)
+	for _, code := range codes {
+		market, ticker, ok := strings.Cut(code, ":")
+		if !ok {
+			market, ticker = "", code
+		}
+		if _, ok := tickers[market]; !ok {
+			markets = append(markets, market)
+		}
+		tickers[market] = append(tickers[market], ticker)
+	}
+
+	result := make([]string, 0, len(markets))
+	for _, market := range markets {
+		if market == "" {
+			result = append(result, tickers[market]...)
+			continue
+		}
+		result = append(result, market+":"+strings.Join(tickers[market], ","))
+	}
+	return result
+}
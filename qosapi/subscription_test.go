@@ -0,0 +1,135 @@
+package qosapi_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+	"github.com/qos-max/qos-quote-api-go-sdk/qostest"
+)
+
+// waitFor 等待cond成立，超时后测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// connectWS 创建并连接到模拟服务器的WSClient，测试结束时关闭
+func connectWS(t *testing.T, srv *qostest.Server, opts ...qosapi.Option) *qosapi.WSClient {
+	t.Helper()
+	client := srv.WSClient(opts...)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestSubscriptionHandlesAreIndependent(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := connectWS(t, srv)
+
+	var first, second atomic.Int32
+	sub1, err := client.OnTrade([]string{"US:AAPL"}, func(qosapi.WSTrade) { first.Add(1) })
+	if err != nil {
+		t.Fatalf("OnTrade: %v", err)
+	}
+	sub2, err := client.OnTrade([]string{"us:aapl", "US:TSLA"}, func(qosapi.WSTrade) { second.Add(1) })
+	if err != nil {
+		t.Fatalf("OnTrade: %v", err)
+	}
+	waitFor(t, "upstream subscription", func() bool {
+		return srv.Subscribed("T", "US:AAPL") && srv.Subscribed("T", "US:TSLA")
+	})
+
+	srv.PushTrade(qosapi.WSTrade{Code: "US:AAPL", Price: "1"})
+	waitFor(t, "both handlers", func() bool { return first.Load() == 1 && second.Load() == 1 })
+
+	if err := sub2.Unsubscribe(); err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	waitFor(t, "TSLA unsubscribed", func() bool { return !srv.Subscribed("T", "US:TSLA") })
+	if !srv.Subscribed("T", "US:AAPL") {
+		t.Fatal("AAPL unsubscribed while another handler still uses it")
+	}
+
+	srv.PushTrade(qosapi.WSTrade{Code: "US:AAPL", Price: "2"})
+	waitFor(t, "first handler", func() bool { return first.Load() == 2 })
+	if n := second.Load(); n != 1 {
+		t.Fatalf("removed handler called %d times, want 1", n)
+	}
+
+	sub1.Unsubscribe()
+	waitFor(t, "AAPL unsubscribed", func() bool { return !srv.Subscribed("T", "US:AAPL") })
+	if err := sub1.Unsubscribe(); err != nil {
+		t.Fatalf("second Unsubscribe: %v", err)
+	}
+}
+
+func TestSubscribeKeepsErrorOnlySignature(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := connectWS(t, srv)
+
+	var got atomic.Int32
+	var callback func(qosapi.WSSnapshot) = func(qosapi.WSSnapshot) { got.Add(1) }
+	var subscribe func([]string, func(qosapi.WSSnapshot)) error = client.SubscribeSnapshot
+	if err := subscribe([]string{"HK:700"}, callback); err != nil {
+		t.Fatalf("SubscribeSnapshot: %v", err)
+	}
+	if err := client.SubscribeSnapshot([]string{"HK:00700"}, callback); err != nil {
+		t.Fatalf("SubscribeSnapshot: %v", err)
+	}
+	waitFor(t, "subscription", func() bool { return srv.Subscribed("S", "HK:00700") })
+
+	srv.PushSnapshot(qosapi.WSSnapshot{Code: "HK:700"})
+	waitFor(t, "both callbacks", func() bool { return got.Load() == 2 })
+
+	if err := client.UnsubscribeSnapshot([]string{"HK:00700"}); err != nil {
+		t.Fatalf("UnsubscribeSnapshot: %v", err)
+	}
+	waitFor(t, "unsubscribed", func() bool { return !srv.Subscribed("S", "HK:00700") })
+	srv.PushSnapshot(qosapi.WSSnapshot{Code: "HK:00700"})
+	time.Sleep(20 * time.Millisecond)
+	if n := got.Load(); n != 2 {
+		t.Fatalf("callbacks called %d times after UnsubscribeSnapshot, want 2", n)
+	}
+}
+
+func TestKLineSubscriptionsAreKeyedByType(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := connectWS(t, srv)
+
+	var min1, min5 atomic.Int32
+	sub, err := client.OnKLine([]string{"US:AAPL"}, qosapi.KLineTypeMin1, func(qosapi.WSKLine) { min1.Add(1) })
+	if err != nil {
+		t.Fatalf("OnKLine: %v", err)
+	}
+	if _, err := client.OnKLine([]string{"US:AAPL"}, qosapi.KLineTypeMin5, func(qosapi.WSKLine) { min5.Add(1) }); err != nil {
+		t.Fatalf("OnKLine: %v", err)
+	}
+	waitFor(t, "subscriptions", func() bool {
+		return srv.SubscribedKLine("US:AAPL", qosapi.KLineTypeMin1) && srv.SubscribedKLine("US:AAPL", qosapi.KLineTypeMin5)
+	})
+
+	srv.PushKLine(qosapi.WSKLine{Code: "US:AAPL", KLineType: qosapi.KLineTypeMin5})
+	waitFor(t, "5m handler", func() bool { return min5.Load() == 1 })
+	if min1.Load() != 0 {
+		t.Fatal("1m handler received a 5m kline")
+	}
+
+	sub.Unsubscribe()
+	waitFor(t, "1m unsubscribed", func() bool { return !srv.SubscribedKLine("US:AAPL", qosapi.KLineTypeMin1) })
+	if !srv.SubscribedKLine("US:AAPL", qosapi.KLineTypeMin5) {
+		t.Fatal("5m subscription removed with 1m")
+	}
+}
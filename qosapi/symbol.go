@@ -0,0 +1,227 @@
+package qosapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Symbol 交易品种代码，由市场和品种代码组成，如US:AAPL、HK:00700
+type Symbol struct {
+	Market string // 市场代码，MarketUS等
+	Ticker string // 品种代码
+}
+
+// NewSymbol 创建并规范化品种代码
+func NewSymbol(market, ticker string) (Symbol, error) {
+	s := Symbol{
+		Market: strings.ToUpper(strings.TrimSpace(market)),
+		Ticker: strings.TrimSpace(ticker),
+	}
+	if err := s.normalize(); err != nil {
+		return Symbol{}, err
+	}
+	return s, nil
+}
+
+// ParseSymbol 解析"市场:代码"形式的单个品种代码，并按市场规则规范化：
+//   - US、CF：代码转为大写
+//   - HK：纯数字，补0到5位，如HK:700为HK:00700
+//   - SH、SZ：纯数字，补0到6位
+func ParseSymbol(code string) (Symbol, error) {
+	market, ticker, ok := strings.Cut(strings.TrimSpace(code), ":")
+	if !ok {
+		return Symbol{}, fmt.Errorf("%w %q: missing market prefix", ErrInvalidCode, code)
+	}
+	if strings.Contains(ticker, ",") {
+		return Symbol{}, fmt.Errorf("%w %q: multiple tickers, use ParseSymbols", ErrInvalidCode, code)
+	}
+	return NewSymbol(market, ticker)
+}
+
+// ParseSymbols 解析"US:AAPL,TSLA"形式的代码，同一市场的多个代码用逗号分隔
+func ParseSymbols(code string) ([]Symbol, error) {
+	market, tickers, ok := strings.Cut(strings.TrimSpace(code), ":")
+	if !ok {
+		return nil, fmt.Errorf("%w %q: missing market prefix", ErrInvalidCode, code)
+	}
+	var result []Symbol
+	for _, ticker := range strings.Split(tickers, ",") {
+		s, err := NewSymbol(market, ticker)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// MustParseSymbol 解析品种代码，失败时panic
+func MustParseSymbol(code string) Symbol {
+	s, err := ParseSymbol(code)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String 返回"市场:代码"形式的代码
+func (s Symbol) String() string {
+	return s.Market + ":" + s.Ticker
+}
+
+// Validate 校验品种代码是否符合市场规则(不做补0等规范化)
+func (s Symbol) Validate() error {
+	n := s
+	if err := n.normalize(); err != nil {
+		return err
+	}
+	if n != s {
+		return fmt.Errorf("%w %q: not normalized, expected %q", ErrInvalidCode, s.String(), n.String())
+	}
+	return nil
+}
+
+// MarshalText 实现encoding.TextMarshaler
+func (s Symbol) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText 实现encoding.TextUnmarshaler
+func (s *Symbol) UnmarshalText(text []byte) error {
+	v, err := ParseSymbol(string(text))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+// normalize 按市场规则校验并规范化代码
+func (s *Symbol) normalize() error {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w %q: %s", ErrInvalidCode, s.String(), reason)
+	}
+
+	if s.Ticker == "" {
+		return invalid("empty ticker")
+	}
+
+	switch s.Market {
+	case MarketUS:
+		s.Ticker = strings.ToUpper(s.Ticker)
+		if len(s.Ticker) > 10 || !isTicker(s.Ticker, ".-") {
+			return invalid("US ticker must be up to 10 letters, digits, '.' or '-'")
+		}
+	case MarketCF:
+		s.Ticker = strings.ToUpper(s.Ticker)
+		if len(s.Ticker) > 20 || !isTicker(s.Ticker, "") {
+			return invalid("crypto ticker must be letters and digits")
+		}
+	case MarketHK:
+		if len(s.Ticker) > 5 || !isDigits(s.Ticker) {
+			return invalid("HK ticker must be up to 5 digits")
+		}
+		s.Ticker = padZeros(s.Ticker, 5)
+	case MarketSH, MarketSZ:
+		if len(s.Ticker) > 6 || !isDigits(s.Ticker) {
+			return invalid(s.Market + " ticker must be 6 digits")
+		}
+		s.Ticker = padZeros(s.Ticker, 6)
+	default:
+		return invalid("unknown market")
+	}
+	return nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isTicker 是否只包含大写字母、数字和extra中的字符，且至少包含一个字母或数字，
+// 因此"."、".."等不会被当作代码
+func isTicker(s, extra string) bool {
+	alnum := false
+	for _, r := range s {
+		switch {
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'):
+			alnum = true
+		case !strings.ContainsRune(extra, r):
+			return false
+		}
+	}
+	return alnum
+}
+
+func padZeros(s string, width int) string {
+	if len(s) >= width {
+		return s
+	}
+	return strings.Repeat("0", width-len(s)) + s
+}
+
+// FormatCodes 将品种代码按市场合并为接口使用的格式，如[US:AAPL US:TSLA HK:00700]
+// 转换为["US:AAPL,TSLA", "HK:00700"]，市场按首次出现的顺序排列
+func FormatCodes(symbols []Symbol) []string {
+	codes := make([]string, len(symbols))
+	for i, s := range symbols {
+		codes[i] = s.String()
+	}
+	return groupCodes(uniqueCodes(codes))
+}
+
+// NormalizeCodes 解析、校验并规范化接口格式的代码，结果按市场重新合并。
+// 任何一个代码不合法时返回包装了ErrInvalidCode的错误
+func NormalizeCodes(codes []string) ([]string, error) {
+	symbols, err := parseCodes(codes)
+	if err != nil {
+		return nil, err
+	}
+	return FormatCodes(symbols), nil
+}
+
+// parseCodes 解析接口格式的代码列表
+func parseCodes(codes []string) ([]Symbol, error) {
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("%w: no codes", ErrInvalidCode)
+	}
+	var symbols []Symbol
+	for _, code := range codes {
+		s, err := ParseSymbols(code)
+		if err != nil {
+			return nil, err
+		}
+		symbols = append(symbols, s...)
+	}
+	return symbols, nil
+}
+
+// normalizeKLineRequests 规范化K线请求中的代码，返回新的切片，不修改requests
+func normalizeKLineRequests(requests []KLineRequest) ([]KLineRequest, error) {
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("%w: no kline requests", ErrInvalidCode)
+	}
+	result := make([]KLineRequest, len(requests))
+	for i, r := range requests {
+		codes, err := NormalizeCodes([]string{r.Codes})
+		if err != nil {
+			return nil, err
+		}
+		r.Codes = strings.Join(codes, ",")
+		result[i] = r
+	}
+	return result, nil
+}
+
+// canonicalCode 返回代码的规范形式，无法解析时原样返回
+func canonicalCode(code string) string {
+	s, err := ParseSymbol(code)
+	if err != nil {
+		return code
+	}
+	return s.String()
+}
@@ -0,0 +1,61 @@
+package qosapi_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+func TestParseSymbol(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "US:aapl", want: "US:AAPL"},
+		{in: " us:BRK.B ", want: "US:BRK.B"},
+		{in: "US:BF-B", want: "US:BF-B"},
+		{in: "HK:700", want: "HK:00700"},
+		{in: "SH:600519", want: "SH:600519"},
+		{in: "SZ:1", want: "SZ:000001"},
+		{in: "CF:btcusdt", want: "CF:BTCUSDT"},
+		{in: "US:.", wantErr: true},
+		{in: "US:..", wantErr: true},
+		{in: "US:-", wantErr: true},
+		{in: "US:.-.", wantErr: true},
+		{in: "US:A/B", wantErr: true},
+		{in: `US:A\B`, wantErr: true},
+		{in: "US:", wantErr: true},
+		{in: "AAPL", wantErr: true},
+		{in: "XX:AAPL", wantErr: true},
+		{in: "HK:ABC", wantErr: true},
+		{in: "HK:123456", wantErr: true},
+		{in: "CF:BTC.USDT", wantErr: true},
+		{in: "US:AAPL,TSLA", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := qosapi.ParseSymbol(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseSymbol(%q) = %s, want error", tt.in, got)
+			} else if !errors.Is(err, qosapi.ErrInvalidCode) {
+				t.Errorf("ParseSymbol(%q) error = %v, want ErrInvalidCode", tt.in, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSymbol(%q): %v", tt.in, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseSymbol(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseSymbolsRejectsDotTickers(t *testing.T) {
+	if _, err := qosapi.ParseSymbols("US:AAPL,.."); !errors.Is(err, qosapi.ErrInvalidCode) {
+		t.Fatalf("ParseSymbols error = %v, want ErrInvalidCode", err)
+	}
+}
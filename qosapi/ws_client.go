@@ -1,38 +1,79 @@
 package qosapi
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"log"
+	"net/http"
 	"net/url"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// DefaultRequestTimeout 不带ctx的Request*方法的默认超时时间
+const DefaultRequestTimeout = 30 * time.Second
+
 // WSClient WebSocket客户端
 type WSClient struct {
-	apiKey      string
-	conn        *websocket.Conn
-	mu          sync.Mutex
-	reqCounter  int
-	callbacks   map[int]func(interface{}, error)
-	subscribers map[string]func(interface{})
-	closeChan   chan struct{}
+	apiKey         string
+	url            string
+	dialer         *websocket.Dialer
+	userAgent      string
+	logger         Logger
+	conn           *websocket.Conn
+	mu             sync.Mutex
+	reqCounter     int
+	callbacks      map[int]*pendingCall
+	registry       *subscriptionRegistry
+	closeChan      chan struct{}
+	closed         bool
+	limiter        *RateLimiter
+	reconnect      *ReconnectPolicy
+	heartbeat      time.Duration
+	requestTimeout time.Duration
+	rawHandlers    map[int]func(RawMessage)
+	rawHandlerID   int
+	streams        map[int]func(error)
+	streamID       int
+}
+
+// RawMessage 收到的原始WebSocket消息
+type RawMessage struct {
+	Time time.Time // 接收时间
+	Data []byte    // 消息内容，处理函数返回后不会被修改
+}
+
+// pendingCall 等待响应的请求
+type pendingCall struct {
+	req      WSRequest
+	callback func(json.RawMessage, error)
 }
 
-// NewWSClient 创建新的WebSocket客户端
-func NewWSClient(apiKey string) *WSClient {
+// NewWSClient 创建新的WebSocket客户端，默认启用断线自动重连。
+// opts可配置WebSocket地址、拨号器、日志、限流器和重连策略等
+func NewWSClient(apiKey string, opts ...Option) *WSClient {
+	o := newClientOptions(opts)
 	return &WSClient{
-		apiKey:      apiKey,
-		callbacks:   make(map[int]func(interface{}, error)),
-		subscribers: make(map[string]func(interface{})),
-		closeChan:   make(chan struct{}),
+		apiKey:         apiKey,
+		url:            o.wsURL,
+		dialer:         o.dialer,
+		userAgent:      o.userAgent,
+		logger:         o.logger,
+		callbacks:      make(map[int]*pendingCall),
+		registry:       newSubscriptionRegistry(o.logger),
+		closeChan:      make(chan struct{}),
+		limiter:        o.limiter,
+		reconnect:      o.reconnect,
+		requestTimeout: o.requestTimeout,
+		rawHandlers:    make(map[int]func(RawMessage)),
+		streams:        make(map[int]func(error)),
 	}
 }
 
-// Connect 连接到WebSocket服务器
+// Connect 连接到WebSocket服务器，并恢复之前的所有订阅
 func (c *WSClient) Connect() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -41,483 +82,530 @@ func (c *WSClient) Connect() error {
 		return nil
 	}
 
-	// 添加API Key到URL参数
-	u, err := url.Parse(WSBaseURL)
-	if err != nil {
-		return err
+	if c.closed {
+		// Close之后重新连接
+		c.closeChan = make(chan struct{})
+		c.closed = false
+		if c.heartbeat > 0 {
+			c.startHeartbeatLocked(c.heartbeat)
+		}
 	}
-	q := u.Query()
-	q.Set("key", c.apiKey)
-	u.RawQuery = q.Encode()
 
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	conn, err := c.dial()
 	if err != nil {
 		return err
 	}
+	// Close之后或自动重连放弃后，注册表中仍保留原有订阅，需要在新连接上恢复
+	if err := c.resubscribeLocked(conn); err != nil {
+		conn.Close()
+		return err
+	}
 
 	c.conn = conn
 
 	// 启动读取goroutine
-	go c.readLoop()
+	go c.readLoop(conn)
 
 	return nil
 }
 
-// Close 关闭WebSocket连接
+// dial 建立WebSocket连接
+func (c *WSClient) dial() (*websocket.Conn, error) {
+	// 添加API Key到URL参数
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("key", c.apiKey)
+	u.RawQuery = q.Encode()
+
+	var header http.Header
+	if c.userAgent != "" {
+		header = http.Header{"User-Agent": []string{c.userAgent}}
+	}
+
+	conn, _, err := c.dialer.Dial(u.String(), header)
+	return conn, err
+}
+
+// Close 关闭WebSocket连接，并停止心跳和自动重连
 func (c *WSClient) Close() error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	if c.conn == nil {
+	if c.closed {
+		c.mu.Unlock()
 		return nil
 	}
-
+	c.closed = true
 	close(c.closeChan)
-	err := c.conn.Close()
+	conn := c.conn
 	c.conn = nil
+	pending := c.takePendingLocked()
+	c.mu.Unlock()
 
-	return err
+	failPending(pending, ErrClientClosed)
+	c.closeStreams(ErrClientClosed)
+
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
 }
 
 // readLoop 读取WebSocket消息的循环
-func (c *WSClient) readLoop() {
+func (c *WSClient) readLoop(conn *websocket.Conn) {
 	for {
-		select {
-		case <-c.closeChan:
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			c.handleDisconnect(conn, err)
 			return
-		default:
-			_, message, err := c.conn.ReadMessage()
-			if err != nil {
-				log.Printf("WebSocket read error: %v", err)
-				return
-			}
+		}
+		c.notifyRaw(RawMessage{Time: time.Now(), Data: message})
+		c.handleMessage(message)
+	}
+}
 
-			var baseResp WSResponse
-			if err := json.Unmarshal(message, &baseResp); err != nil {
-				log.Printf("Failed to unmarshal message: %v", err)
-				continue
-			}
+// OnRawMessage 注册原始消息处理函数，在解析和分发之前以接收顺序调用，返回用于注销的函数。
+// 处理函数在读取goroutine中执行，应尽快返回
+func (c *WSClient) OnRawMessage(handler func(RawMessage)) (remove func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rawHandlerID++
+	id := c.rawHandlerID
+	c.rawHandlers[id] = handler
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.rawHandlers, id)
+	}
+}
 
-			if baseResp.Type == "" {
-				baseResp.Type = baseResp.TP
-			}
+// addStream 注册数据流的关闭函数，客户端关闭或断线后不再重连时调用。客户端已关闭时ok为false
+func (c *WSClient) addStream(closeStream func(error)) (detach func(), ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, false
+	}
+	c.streamID++
+	id := c.streamID
+	c.streams[id] = closeStream
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.streams, id)
+	}, true
+}
 
-			// 处理订阅数据推送
-			switch baseResp.Type {
-			case "S":
-				var snapshot WSSnapshot
-				if err := json.Unmarshal(message, &snapshot); err != nil {
-					log.Printf("Failed to unmarshal snapshot: %v", err)
-					continue
-				}
-				if cb, ok := c.subscribers["S"]; ok {
-					if len(snapshot.Code) > 0 {
-						cb(snapshot)
-					}
-				}
-			case "T":
-				var trade WSTrade
-				if err := json.Unmarshal(message, &trade); err != nil {
-					log.Printf("Failed to unmarshal trade: %v", err)
-					continue
-				}
-				if cb, ok := c.subscribers["T"]; ok {
-					if len(trade.Code) > 0 {
-						cb(trade)
-					}
-				}
-			case "D":
-				var depth WSDepth
-				if err := json.Unmarshal(message, &depth); err != nil {
-					log.Printf("Failed to unmarshal depth: %v", err)
-					continue
-				}
-				if cb, ok := c.subscribers["D"]; ok {
-					if len(depth.Code) > 0 {
-						cb(depth)
-					}
-				}
-			case "K":
-				var kline WSKLine
-				if err := json.Unmarshal(message, &kline); err != nil {
-					log.Printf("Failed to unmarshal kline: %v", err)
-					continue
-				}
-				if cb, ok := c.subscribers["K"]; ok {
-					if len(kline.Code) > 0 {
-						cb(kline)
-					}
-				}
-			default:
-				// 处理请求响应
-				c.mu.Lock()
-				if cb, ok := c.callbacks[baseResp.ReqID]; ok {
-					delete(c.callbacks, baseResp.ReqID)
-					c.mu.Unlock()
-
-					if baseResp.Msg != "OK" {
-						cb(nil, errors.New(baseResp.Msg))
-					} else {
-						cb(baseResp, nil)
-					}
-				} else {
-					c.mu.Unlock()
-				}
+// closeStreams 以err为结束原因关闭所有数据流
+func (c *WSClient) closeStreams(err error) {
+	c.mu.Lock()
+	streams := c.streams
+	c.streams = make(map[int]func(error))
+	c.mu.Unlock()
+
+	for _, closeStream := range streams {
+		closeStream(err)
+	}
+}
+
+// notifyRaw 调用原始消息处理函数
+func (c *WSClient) notifyRaw(msg RawMessage) {
+	c.mu.Lock()
+	if len(c.rawHandlers) == 0 {
+		c.mu.Unlock()
+		return
+	}
+	ids := make([]int, 0, len(c.rawHandlers))
+	for id := range c.rawHandlers {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	handlers := make([]func(RawMessage), len(ids))
+	for i, id := range ids {
+		handlers[i] = c.rawHandlers[id]
+	}
+	c.mu.Unlock()
+
+	for _, h := range handlers {
+		h(msg)
+	}
+}
+
+// handleMessage 处理一条WebSocket消息
+func (c *WSClient) handleMessage(message []byte) {
+	var baseResp WSResponse
+	if err := json.Unmarshal(message, &baseResp); err != nil {
+		c.logger.Printf("Failed to unmarshal message: %v", err)
+		return
+	}
+
+	if baseResp.Type == "" {
+		baseResp.Type = baseResp.TP
+	}
+
+	switch baseResp.Type {
+	case "S", "T", "D", "K":
+		// 处理订阅数据推送
+		c.registry.dispatch(baseResp.Type, message)
+	default:
+		// 处理请求响应
+		c.mu.Lock()
+		if call, ok := c.callbacks[baseResp.ReqID]; ok {
+			delete(c.callbacks, baseResp.ReqID)
+			c.mu.Unlock()
+
+			if baseResp.Msg != "OK" {
+				call.callback(nil, newAPIError(0, baseResp.Msg, call.req.Type, requestCodes(call.req.Codes, call.req.KLineReqs)))
+			} else {
+				call.callback(baseResp.Data, nil)
 			}
+		} else {
+			c.mu.Unlock()
 		}
 	}
 }
 
+// SetRateLimiter 设置客户端限流器，可与QOSClient共享同一个限流器，nil表示不限流
+func (c *WSClient) SetRateLimiter(limiter *RateLimiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limiter = limiter
+}
+
+// limiterEndpoints 请求类型对应的限流接口名，与QOSClient使用的HTTP路径一致
+var limiterEndpoints = map[string]string{
+	"RS": "/snapshot",
+	"RT": "/trade",
+	"RD": "/depth",
+	"RK": "/kline",
+	"RH": "/history",
+	"RI": "/instrument-info",
+}
+
 // sendRequest 发送WebSocket请求
-func (c *WSClient) sendRequest(req WSRequest, callback func(interface{}, error)) error {
+func (c *WSClient) sendRequest(req WSRequest, callback func(json.RawMessage, error)) error {
+	_, err := c.sendRequestContext(context.Background(), req, callback)
+	return err
+}
+
+// sendRequestContext 发送WebSocket请求并返回请求ID，ctx用于限流等待
+func (c *WSClient) sendRequestContext(ctx context.Context, req WSRequest, callback func(json.RawMessage, error)) (int, error) {
+	c.mu.Lock()
+	limiter := c.limiter
+	c.mu.Unlock()
+
+	if limiter != nil && req.Type != "H" {
+		endpoint, ok := limiterEndpoints[req.Type]
+		if !ok {
+			endpoint = req.Type
+		}
+		if err := limiter.Wait(ctx, endpoint); err != nil {
+			return 0, err
+		}
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if c.conn == nil {
-		return errors.New("WebSocket not connected")
+		return 0, ErrNotConnected
 	}
 
 	c.reqCounter++
 	req.ReqID = c.reqCounter
 
 	if callback != nil {
-		c.callbacks[req.ReqID] = callback
+		c.callbacks[req.ReqID] = &pendingCall{req: req, callback: callback}
 	}
 
-	return c.conn.WriteJSON(req)
+	if err := c.conn.WriteJSON(req); err != nil {
+		delete(c.callbacks, req.ReqID)
+		return 0, err
+	}
+	return req.ReqID, nil
 }
 
-// SubscribeSnapshot 订阅实时快照
-func (c *WSClient) SubscribeSnapshot(codes []string, callback func(WSSnapshot)) error {
-	key := "S"
-	c.subscribers[key] = func(data interface{}) {
-		callback(data.(WSSnapshot))
+// call 发送请求并等待响应，返回响应中的data。
+// ctx结束时返回ctx的错误并清理回调，连接断开时返回ErrConnectionLost
+func (c *WSClient) call(ctx context.Context, req WSRequest) (json.RawMessage, error) {
+	type result struct {
+		data json.RawMessage
+		err  error
 	}
+	resultChan := make(chan result, 1)
+	reqID, err := c.sendRequestContext(ctx, req, func(data json.RawMessage, err error) {
+		resultChan <- result{data, err}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-resultChan:
+		return r.data, r.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.callbacks, reqID)
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
 
+// requestContext 为不带ctx的Request*方法创建带默认超时的ctx
+func (c *WSClient) requestContext() (context.Context, context.CancelFunc) {
+	c.mu.Lock()
+	timeout := c.requestTimeout
+	c.mu.Unlock()
+
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// SetRequestTimeout 设置不带ctx的Request*方法的超时时间，默认为DefaultRequestTimeout，0表示不超时
+func (c *WSClient) SetRequestTimeout(timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requestTimeout = timeout
+}
+
+// subscribe 注册回调，并向服务端订阅此前没有回调的代码
+func (c *WSClient) subscribe(key subscriptionKey, codes []string, handler func(interface{})) (*Subscription, error) {
+	codes, err := NormalizeCodes(codes)
+	if err != nil {
+		return nil, err
+	}
+
+	id, added := c.registry.add(key, codes, handler)
+	if len(added) > 0 {
+		err = c.sendRequest(WSRequest{
+			Type:      key.Type,
+			Codes:     groupCodes(added),
+			KLineType: key.KLineType,
+		}, nil)
+		if err != nil {
+			c.registry.remove(id)
+			return nil, err
+		}
+	}
+
+	return &Subscription{unsubscribe: func() error {
+		key, removed := c.registry.remove(id)
+		if len(removed) == 0 {
+			return nil
+		}
+		err := c.sendRequest(WSRequest{
+			Type:      key.Type + "C",
+			Codes:     groupCodes(removed),
+			KLineType: key.KLineType,
+		}, nil)
+		if errors.Is(err, ErrNotConnected) {
+			// 未连接时服务端已无订阅，重连时也不会再恢复
+			return nil
+		}
+		return err
+	}}, nil
+}
+
+// unsubscribe 移除代码上的所有回调并向服务端取消订阅
+func (c *WSClient) unsubscribe(key subscriptionKey, codes []string) error {
+	codes, err := NormalizeCodes(codes)
+	if err != nil {
+		return err
+	}
+
+	c.registry.removeCodes(key, codes)
 	return c.sendRequest(WSRequest{
-		Type:  "S",
-		Codes: codes,
+		Type:      key.Type + "C",
+		Codes:     codes,
+		KLineType: key.KLineType,
 	}, nil)
 }
 
-// UnsubscribeSnapshot 取消订阅实时快照
+// SubscribeSnapshot 订阅实时快照，同一代码可以多次订阅注册多个回调，通过UnsubscribeSnapshot取消。
+// 需要单独取消本次注册的回调时使用OnSnapshot
+func (c *WSClient) SubscribeSnapshot(codes []string, callback func(WSSnapshot)) error {
+	_, err := c.OnSnapshot(codes, callback)
+	return err
+}
+
+// OnSnapshot 订阅实时快照并注册回调，返回的Subscription只取消本次注册的回调，
+// 代码上不再有任何回调时才向服务端取消订阅
+func (c *WSClient) OnSnapshot(codes []string, callback func(WSSnapshot)) (*Subscription, error) {
+	return c.subscribe(subscriptionKey{Type: "S"}, codes, func(data interface{}) {
+		callback(data.(WSSnapshot))
+	})
+}
+
+// UnsubscribeSnapshot 取消订阅实时快照，移除这些代码上的所有回调
 func (c *WSClient) UnsubscribeSnapshot(codes []string) error {
-	return c.sendRequest(WSRequest{
-		Type:  "SC",
-		Codes: codes,
-	}, nil)
+	return c.unsubscribe(subscriptionKey{Type: "S"}, codes)
 }
 
-// SubscribeTrade 订阅实时逐笔成交
+// SubscribeTrade 订阅实时逐笔成交，同一代码可以多次订阅注册多个回调，通过UnsubscribeTrade取消。
+// 需要单独取消本次注册的回调时使用OnTrade
 func (c *WSClient) SubscribeTrade(codes []string, callback func(WSTrade)) error {
-	key := "T"
-	c.subscribers[key] = func(data interface{}) {
-		callback(data.(WSTrade))
-	}
+	_, err := c.OnTrade(codes, callback)
+	return err
+}
 
-	return c.sendRequest(WSRequest{
-		Type:  "T",
-		Codes: codes,
-	}, nil)
+// OnTrade 订阅实时逐笔成交并注册回调，返回的Subscription只取消本次注册的回调，
+// 代码上不再有任何回调时才向服务端取消订阅
+func (c *WSClient) OnTrade(codes []string, callback func(WSTrade)) (*Subscription, error) {
+	return c.subscribe(subscriptionKey{Type: "T"}, codes, func(data interface{}) {
+		callback(data.(WSTrade))
+	})
 }
 
-// UnsubscribeTrade 取消订阅实时逐笔成交
+// UnsubscribeTrade 取消订阅实时逐笔成交，移除这些代码上的所有回调
 func (c *WSClient) UnsubscribeTrade(codes []string) error {
-	return c.sendRequest(WSRequest{
-		Type:  "TC",
-		Codes: codes,
-	}, nil)
+	return c.unsubscribe(subscriptionKey{Type: "T"}, codes)
 }
 
-// SubscribeDepth 订阅实时盘口
+// SubscribeDepth 订阅实时盘口，同一代码可以多次订阅注册多个回调，通过UnsubscribeDepth取消。
+// 需要单独取消本次注册的回调时使用OnDepth
 func (c *WSClient) SubscribeDepth(codes []string, callback func(WSDepth)) error {
-	key := "D"
-	c.subscribers[key] = func(data interface{}) {
-		callback(data.(WSDepth))
-	}
+	_, err := c.OnDepth(codes, callback)
+	return err
+}
 
-	return c.sendRequest(WSRequest{
-		Type:  "D",
-		Codes: codes,
-	}, nil)
+// OnDepth 订阅实时盘口并注册回调，返回的Subscription只取消本次注册的回调，
+// 代码上不再有任何回调时才向服务端取消订阅
+func (c *WSClient) OnDepth(codes []string, callback func(WSDepth)) (*Subscription, error) {
+	return c.subscribe(subscriptionKey{Type: "D"}, codes, func(data interface{}) {
+		callback(data.(WSDepth))
+	})
 }
 
-// UnsubscribeDepth 取消订阅实时盘口
+// UnsubscribeDepth 取消订阅实时盘口，移除这些代码上的所有回调
 func (c *WSClient) UnsubscribeDepth(codes []string) error {
-	return c.sendRequest(WSRequest{
-		Type:  "DC",
-		Codes: codes,
-	}, nil)
+	return c.unsubscribe(subscriptionKey{Type: "D"}, codes)
 }
 
-// SubscribeKLine 订阅实时K线
+// SubscribeKLine 订阅实时K线，同一代码可以多次订阅注册多个回调，通过UnsubscribeKLine取消。
+// 需要单独取消本次注册的回调时使用OnKLine
 func (c *WSClient) SubscribeKLine(codes []string, klineType int, callback func(WSKLine)) error {
-	key := "K"
-	c.subscribers[key] = func(data interface{}) {
-		callback(data.(WSKLine))
-	}
+	_, err := c.OnKLine(codes, klineType, callback)
+	return err
+}
 
-	return c.sendRequest(WSRequest{
-		Type:      "K",
-		Codes:     codes,
-		KLineType: klineType,
-	}, nil)
+// OnKLine 订阅实时K线并注册回调，返回的Subscription只取消本次注册的回调，
+// 代码上不再有任何回调时才向服务端取消订阅
+func (c *WSClient) OnKLine(codes []string, klineType int, callback func(WSKLine)) (*Subscription, error) {
+	return c.subscribe(subscriptionKey{Type: "K", KLineType: klineType}, codes, func(data interface{}) {
+		callback(data.(WSKLine))
+	})
 }
 
-// UnsubscribeKLine 取消订阅实时K线
+// UnsubscribeKLine 取消订阅实时K线，移除这些代码上的所有回调
 func (c *WSClient) UnsubscribeKLine(codes []string, klineType int) error {
-	return c.sendRequest(WSRequest{
-		Type:      "KC",
-		Codes:     codes,
-		KLineType: klineType,
-	}, nil)
+	return c.unsubscribe(subscriptionKey{Type: "K", KLineType: klineType}, codes)
 }
 
-// RequestSnapshot 请求实时快照
+// RequestSnapshot 请求实时快照，超时时间由SetRequestTimeout设置
 func (c *WSClient) RequestSnapshot(codes []string) ([]Snapshot, error) {
-	var result []Snapshot
-	errChan := make(chan error, 1)
-
-	err := c.sendRequest(WSRequest{
-		Type:  "RS",
-		Codes: codes,
-	}, func(data interface{}, err error) {
-		if err != nil {
-			errChan <- err
-			return
-		}
-
-		jsonData, err := json.Marshal(data)
-		if err != nil {
-			errChan <- err
-			return
-		}
-
-		var resp struct {
-			Data []Snapshot `json:"data"`
-		}
-		if err := json.Unmarshal(jsonData, &resp); err != nil {
-			errChan <- err
-			return
-		}
-
-		result = resp.Data
-		errChan <- nil
-	})
+	ctx, cancel := c.requestContext()
+	defer cancel()
+	return c.RequestSnapshotContext(ctx, codes)
+}
 
+// RequestSnapshotContext 请求实时快照，ctx可用于超时和取消
+func (c *WSClient) RequestSnapshotContext(ctx context.Context, codes []string) ([]Snapshot, error) {
+	codes, err := NormalizeCodes(codes)
 	if err != nil {
 		return nil, err
 	}
-
-	return result, <-errChan
+	return CallWS[[]Snapshot](ctx, c, WSRequest{Type: "RS", Codes: codes})
 }
 
-// RequestTrade 请求实时逐笔成交
+// RequestTrade 请求实时逐笔成交，超时时间由SetRequestTimeout设置
 func (c *WSClient) RequestTrade(codes []string, count int) ([]Trade, error) {
-	var result []Trade
-	errChan := make(chan error, 1)
-
-	err := c.sendRequest(WSRequest{
-		Type:  "RT",
-		Codes: codes,
-		Count: count,
-	}, func(data interface{}, err error) {
-		if err != nil {
-			errChan <- err
-			return
-		}
-
-		jsonData, err := json.Marshal(data)
-		if err != nil {
-			errChan <- err
-			return
-		}
-
-		var resp struct {
-			Data []Trade `json:"data"`
-		}
-		if err := json.Unmarshal(jsonData, &resp); err != nil {
-			errChan <- err
-			return
-		}
-
-		result = resp.Data
-		errChan <- nil
-	})
+	ctx, cancel := c.requestContext()
+	defer cancel()
+	return c.RequestTradeContext(ctx, codes, count)
+}
 
+// RequestTradeContext 请求实时逐笔成交，ctx可用于超时和取消
+func (c *WSClient) RequestTradeContext(ctx context.Context, codes []string, count int) ([]Trade, error) {
+	codes, err := NormalizeCodes(codes)
 	if err != nil {
 		return nil, err
 	}
-
-	return result, <-errChan
+	return CallWS[[]Trade](ctx, c, WSRequest{Type: "RT", Codes: codes, Count: count})
 }
 
-// RequestDepth 请求实时盘口
+// RequestDepth 请求实时盘口，超时时间由SetRequestTimeout设置
 func (c *WSClient) RequestDepth(codes []string) ([]Depth, error) {
-	var result []Depth
-	errChan := make(chan error, 1)
-
-	err := c.sendRequest(WSRequest{
-		Type:  "RD",
-		Codes: codes,
-	}, func(data interface{}, err error) {
-		if err != nil {
-			errChan <- err
-			return
-		}
-
-		jsonData, err := json.Marshal(data)
-		if err != nil {
-			errChan <- err
-			return
-		}
-
-		var resp struct {
-			Data []Depth `json:"data"`
-		}
-		if err := json.Unmarshal(jsonData, &resp); err != nil {
-			errChan <- err
-			return
-		}
-
-		result = resp.Data
-		errChan <- nil
-	})
+	ctx, cancel := c.requestContext()
+	defer cancel()
+	return c.RequestDepthContext(ctx, codes)
+}
 
+// RequestDepthContext 请求实时盘口，ctx可用于超时和取消
+func (c *WSClient) RequestDepthContext(ctx context.Context, codes []string) ([]Depth, error) {
+	codes, err := NormalizeCodes(codes)
 	if err != nil {
 		return nil, err
 	}
-
-	return result, <-errChan
+	return CallWS[[]Depth](ctx, c, WSRequest{Type: "RD", Codes: codes})
 }
 
-// RequestKLine 请求实时K线
+// RequestKLine 请求实时K线，超时时间由SetRequestTimeout设置
 func (c *WSClient) RequestKLine(requests []KLineRequest) ([][]KLine, error) {
-	var result [][]KLine
-	errChan := make(chan error, 1)
-
-	err := c.sendRequest(WSRequest{
-		Type:      "RK",
-		KLineReqs: requests,
-	}, func(data interface{}, err error) {
-		if err != nil {
-			errChan <- err
-			return
-		}
-
-		jsonData, err := json.Marshal(data)
-		if err != nil {
-			errChan <- err
-			return
-		}
-
-		var resp struct {
-			Data []struct {
-				Code string  `json:"c"`
-				K    []KLine `json:"k"`
-			} `json:"data"`
-		}
-		if err := json.Unmarshal(jsonData, &resp); err != nil {
-			errChan <- err
-			return
-		}
-
-		result = make([][]KLine, len(resp.Data))
-		for i, item := range resp.Data {
-			result[i] = item.K
-		}
-		errChan <- nil
-	})
+	ctx, cancel := c.requestContext()
+	defer cancel()
+	return c.RequestKLineContext(ctx, requests)
+}
 
+// RequestKLineContext 请求实时K线，ctx可用于超时和取消
+func (c *WSClient) RequestKLineContext(ctx context.Context, requests []KLineRequest) ([][]KLine, error) {
+	requests, err := normalizeKLineRequests(requests)
 	if err != nil {
 		return nil, err
 	}
-
-	return result, <-errChan
+	groups, err := CallWS[[]klineGroup](ctx, c, WSRequest{Type: "RK", KLineReqs: requests})
+	if err != nil {
+		return nil, err
+	}
+	return klineLines(groups), nil
 }
 
-// RequestHistoryKLine 请求历史K线
+// RequestHistoryKLine 请求历史K线，超时时间由SetRequestTimeout设置
 func (c *WSClient) RequestHistoryKLine(requests []KLineRequest) ([][]KLine, error) {
-	var result [][]KLine
-	errChan := make(chan error, 1)
-
-	err := c.sendRequest(WSRequest{
-		Type:      "RH",
-		KLineReqs: requests,
-	}, func(data interface{}, err error) {
-		if err != nil {
-			errChan <- err
-			return
-		}
-
-		jsonData, err := json.Marshal(data)
-		if err != nil {
-			errChan <- err
-			return
-		}
-
-		var resp struct {
-			Data []struct {
-				Code string  `json:"c"`
-				K    []KLine `json:"k"`
-			} `json:"data"`
-		}
-		if err := json.Unmarshal(jsonData, &resp); err != nil {
-			errChan <- err
-			return
-		}
-
-		result = make([][]KLine, len(resp.Data))
-		for i, item := range resp.Data {
-			result[i] = item.K
-		}
-		errChan <- nil
-	})
+	ctx, cancel := c.requestContext()
+	defer cancel()
+	return c.RequestHistoryKLineContext(ctx, requests)
+}
 
+// RequestHistoryKLineContext 请求历史K线，ctx可用于超时和取消
+func (c *WSClient) RequestHistoryKLineContext(ctx context.Context, requests []KLineRequest) ([][]KLine, error) {
+	requests, err := normalizeKLineRequests(requests)
 	if err != nil {
 		return nil, err
 	}
-
-	return result, <-errChan
+	groups, err := CallWS[[]klineGroup](ctx, c, WSRequest{Type: "RH", KLineReqs: requests})
+	if err != nil {
+		return nil, err
+	}
+	return klineLines(groups), nil
 }
 
-// RequestInstrumentInfo 请求交易品种的基础信息
+// RequestInstrumentInfo 请求交易品种的基础信息，超时时间由SetRequestTimeout设置
 func (c *WSClient) RequestInstrumentInfo(codes []string) ([]InstrumentInfo, error) {
-	var result []InstrumentInfo
-	errChan := make(chan error, 1)
-
-	err := c.sendRequest(WSRequest{
-		Type:  "RI",
-		Codes: codes,
-	}, func(data interface{}, err error) {
-		if err != nil {
-			errChan <- err
-			return
-		}
-
-		jsonData, err := json.Marshal(data)
-		if err != nil {
-			errChan <- err
-			return
-		}
-
-		var resp struct {
-			Data []InstrumentInfo `json:"data"`
-		}
-		if err := json.Unmarshal(jsonData, &resp); err != nil {
-			errChan <- err
-			return
-		}
-
-		result = resp.Data
-		errChan <- nil
-	})
+	ctx, cancel := c.requestContext()
+	defer cancel()
+	return c.RequestInstrumentInfoContext(ctx, codes)
+}
 
+// RequestInstrumentInfoContext 请求交易品种的基础信息，ctx可用于超时和取消
+func (c *WSClient) RequestInstrumentInfoContext(ctx context.Context, codes []string) ([]InstrumentInfo, error) {
+	codes, err := NormalizeCodes(codes)
 	if err != nil {
 		return nil, err
 	}
-
-	return result, <-errChan
+	return CallWS[[]InstrumentInfo](ctx, c, WSRequest{Type: "RI", Codes: codes})
 }
 
 // SendHeartbeat 发送心跳
@@ -527,17 +615,26 @@ func (c *WSClient) SendHeartbeat() error {
 	}, nil)
 }
 
-// StartHeartbeat 启动定时心跳
+// StartHeartbeat 启动定时心跳，断线重连期间心跳暂停，重连后自动恢复
 func (c *WSClient) StartHeartbeat(interval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.heartbeat = interval
+	c.startHeartbeatLocked(interval)
+}
+
+// startHeartbeatLocked 启动心跳goroutine，直到Close时退出，调用方需持有c.mu
+func (c *WSClient) startHeartbeatLocked(interval time.Duration) {
+	closeChan := c.closeChan
 	ticker := time.NewTicker(interval)
 	go func() {
 		for {
 			select {
 			case <-ticker.C:
-				if err := c.SendHeartbeat(); err != nil {
-					log.Printf("Failed to send heartbeat: %v", err)
+				if err := c.SendHeartbeat(); err != nil && !errors.Is(err, ErrNotConnected) {
+					c.logger.Printf("Failed to send heartbeat: %v", err)
 				}
-			case <-c.closeChan:
+			case <-closeChan:
 				ticker.Stop()
 				return
 			}
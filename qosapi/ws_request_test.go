@@ -0,0 +1,121 @@
+package qosapi_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+	"github.com/qos-max/qos-quote-api-go-sdk/qostest"
+)
+
+func TestWSRequestReturnsData(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	srv.SetSnapshot(qosapi.Snapshot{Code: "HK:00700", LastPrice: "300.2"})
+	client := connectWS(t, srv)
+
+	got, err := client.RequestSnapshot([]string{"HK:700"})
+	if err != nil {
+		t.Fatalf("RequestSnapshot: %v", err)
+	}
+	if len(got) != 1 || got[0].LastPrice != "300.2" {
+		t.Fatalf("RequestSnapshot = %+v", got)
+	}
+	if n := srv.RequestCount("RS"); n != 1 {
+		t.Fatalf("server saw %d RS requests, want 1", n)
+	}
+}
+
+func TestWSRequestContextDeadline(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := connectWS(t, srv)
+	srv.SetLatency(500 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.RequestSnapshotContext(ctx, []string{"US:AAPL"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > 400*time.Millisecond {
+		t.Fatalf("returned after %v, want to return when ctx expires", d)
+	}
+
+	// 迟到的响应被丢弃，后续请求正常
+	srv.SetLatency(0)
+	time.Sleep(500 * time.Millisecond)
+	if _, err := client.RequestSnapshot([]string{"US:AAPL"}); err != nil {
+		t.Fatalf("request after timeout: %v", err)
+	}
+}
+
+func TestWSRequestContextCancel(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := connectWS(t, srv)
+	srv.SetLatency(time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := client.RequestDepthContext(ctx, []string{"US:AAPL"})
+		errc <- err
+	}()
+	waitFor(t, "request sent", func() bool { return srv.RequestCount("RD") == 1 })
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("error = %v, want context.Canceled", err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("request did not return after cancel")
+	}
+}
+
+func TestWSRequestDefaultTimeout(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := connectWS(t, srv, qosapi.WithRequestTimeout(50*time.Millisecond))
+	srv.SetLatency(500 * time.Millisecond)
+
+	if _, err := client.RequestTrade([]string{"US:AAPL"}, 10); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestWSRequestInjectedError(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := connectWS(t, srv)
+	srv.InjectError("RS", 0, "invalid code", 1)
+
+	_, err := client.RequestSnapshot([]string{"US:AAPL"})
+	var apiErr *qosapi.APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, qosapi.ErrInvalidCode) {
+		t.Fatalf("error = %v, want APIError wrapping ErrInvalidCode", err)
+	}
+	if _, err := client.RequestSnapshot([]string{"US:AAPL"}); err != nil {
+		t.Fatalf("request after injected error: %v", err)
+	}
+	if n := srv.RequestCount("RS"); n != 2 {
+		t.Fatalf("server saw %d RS requests, want 2", n)
+	}
+}
+
+func TestWSRequestRejectsInvalidCodesLocally(t *testing.T) {
+	srv := qostest.NewServer()
+	defer srv.Close()
+	client := connectWS(t, srv)
+
+	if _, err := client.RequestSnapshot([]string{"AAPL"}); !errors.Is(err, qosapi.ErrInvalidCode) {
+		t.Fatalf("error = %v, want ErrInvalidCode", err)
+	}
+	if n := srv.RequestCount("RS"); n != 0 {
+		t.Fatalf("server saw %d RS requests, want 0", n)
+	}
+}
@@ -0,0 +1,185 @@
+package qosproxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+// downstream 下游WebSocket连接
+type downstream struct {
+	p      *Proxy
+	conn   *websocket.Conn
+	send   chan []byte
+	done   chan struct{}
+	once   sync.Once
+	topics map[topic]struct{} // 由Proxy.mu保护
+}
+
+// handleWS 处理下游WebSocket连接
+func (p *Proxy) handleWS(w http.ResponseWriter, r *http.Request) {
+	if !p.authorized(r.URL.Query().Get("key")) {
+		writeJSON(w, http.StatusUnauthorized, response{Msg: "invalid api key"})
+		return
+	}
+	conn, err := p.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	d := &downstream{
+		p:      p,
+		conn:   conn,
+		send:   make(chan []byte, p.opts.BufferSize),
+		done:   make(chan struct{}),
+		topics: make(map[topic]struct{}),
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		conn.Close()
+		return
+	}
+	p.conns[d] = struct{}{}
+	p.mu.Unlock()
+
+	go d.writeLoop()
+	d.readLoop()
+	d.close()
+	p.removeConn(d)
+}
+
+// removeConn 移除断开的下游连接及其所有订阅
+func (p *Proxy) removeConn(d *downstream) {
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+
+	p.mu.Lock()
+	delete(p.conns, d)
+	topics := make([]topic, 0, len(d.topics))
+	for t := range d.topics {
+		topics = append(topics, t)
+	}
+	p.mu.Unlock()
+
+	p.releaseLocked(d, topics)
+}
+
+// close 关闭连接，读取循环随之退出
+func (d *downstream) close() {
+	d.once.Do(func() {
+		close(d.done)
+		d.conn.Close()
+	})
+}
+
+// enqueue 将消息放入发送缓冲区，缓冲区已满说明下游处理过慢，断开该连接
+func (d *downstream) enqueue(message []byte) {
+	select {
+	case d.send <- message:
+	case <-d.done:
+	default:
+		d.p.opts.Logger.Printf("Disconnecting slow client %s: send buffer is full", d.conn.RemoteAddr())
+		d.close()
+	}
+}
+
+// reply 发送响应
+func (d *downstream) reply(resp response) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	d.enqueue(data)
+}
+
+func (d *downstream) writeLoop() {
+	for {
+		select {
+		case message := <-d.send:
+			d.conn.SetWriteDeadline(time.Now().Add(d.p.opts.WriteTimeout))
+			if err := d.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				d.close()
+				return
+			}
+		case <-d.done:
+			return
+		}
+	}
+}
+
+func (d *downstream) readLoop() {
+	for {
+		_, message, err := d.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req qosapi.WSRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			d.reply(response{Msg: "invalid request"})
+			continue
+		}
+		d.handleRequest(req)
+	}
+}
+
+// handleRequest 处理一条下游请求。订阅请求在读取goroutine中按顺序处理，R*请求在独立的goroutine中转发到上游
+func (d *downstream) handleRequest(req qosapi.WSRequest) {
+	p := d.p
+	switch req.Type {
+	case "S", "T", "D", "K":
+		kt := 0
+		if req.Type == "K" {
+			kt = req.KLineType
+		}
+		if err := p.subscribe(d, req.Type, kt, req.Codes); err != nil {
+			d.reply(response{Type: req.Type, Msg: errorMsg(err), ReqID: req.ReqID})
+			return
+		}
+		d.reply(response{Type: req.Type, Msg: "OK", ReqID: req.ReqID})
+	case "SC", "TC", "DC", "KC":
+		kt := 0
+		if req.Type == "KC" {
+			kt = req.KLineType
+		}
+		if err := p.unsubscribe(d, req.Type[:1], kt, req.Codes); err != nil {
+			d.reply(response{Type: req.Type, Msg: errorMsg(err), ReqID: req.ReqID})
+			return
+		}
+		d.reply(response{Type: req.Type, Msg: "OK", ReqID: req.ReqID})
+	case "H":
+		d.reply(response{Type: req.Type, Msg: "OK", Time: time.Now().Unix(), ReqID: req.ReqID})
+	case "RS", "RT", "RD", "RK", "RH", "RI":
+		go d.forwardRequest(req)
+	default:
+		d.reply(response{Type: req.Type, Msg: "unknown request type", ReqID: req.ReqID})
+	}
+}
+
+// forwardRequest 将R*请求转发到上游，响应中的reqid替换为下游的请求ID
+func (d *downstream) forwardRequest(req qosapi.WSRequest) {
+	p := d.p
+	ctx, cancel := context.WithTimeout(context.Background(), p.opts.RequestTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-d.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	reqID := req.ReqID
+	req.ReqID = 0
+	data, err := qosapi.CallWS[json.RawMessage](ctx, p.requestUpstream().client, req)
+	if err != nil {
+		d.reply(response{Type: req.Type, Msg: errorMsg(err), ReqID: reqID})
+		return
+	}
+	d.reply(response{Type: req.Type, Msg: "OK", ReqID: reqID, Data: data})
+}
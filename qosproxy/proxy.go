@@ -0,0 +1,319 @@
+// Package qosproxy 实现本地行情代理：使用一个或少量上游WebSocket连接，
+// 以与QOS相同的/ws协议和HTTP接口为多个本地客户端提供服务。
+// 代理按代码合并所有下游客户端的订阅，只向上游订阅其并集，最后一个订阅者取消时才向上游取消订阅。
+//
+// 现有代码只需将地址指向代理即可：
+//
+//	client := qosapi.NewWSClient("local-key", qosapi.WithWSURL("ws://127.0.0.1:8090/ws"))
+//	rest := qosapi.NewClient("local-key", qosapi.WithBaseURL("http://127.0.0.1:8090"))
+package qosproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+// 默认配置
+const (
+	DefaultHeartbeat    = 20 * time.Second // 上游心跳间隔
+	DefaultBufferSize   = 1024             // 每个下游连接的发送缓冲区大小(消息数)
+	DefaultWriteTimeout = 10 * time.Second // 向下游写入一条消息的超时时间
+)
+
+// restPaths 代理转发的HTTP接口
+var restPaths = []string{"/snapshot", "/trade", "/depth", "/kline", "/history", "/instrument-info"}
+
+// Options 代理配置
+type Options struct {
+	// APIKey 上游API Key
+	APIKey string
+
+	// Upstreams 上游WebSocket连接数，默认为1。新订阅分配到当前订阅数最少的连接
+	Upstreams int
+
+	// ClientOptions 创建上游QOSClient和WSClient时使用的配置，如地址、限流器和重试策略
+	ClientOptions []qosapi.Option
+
+	// Keys 下游客户端需要携带的key(WebSocket为key参数，HTTP为key请求头)，为空时不校验
+	Keys []string
+
+	// AllowedOrigins 允许建立WebSocket连接的浏览器来源(Origin请求头，如"https://app.example.com")，
+	// "*"表示允许所有来源。未携带Origin的非浏览器客户端和与Host相同的来源总是允许
+	AllowedOrigins []string
+
+	// Heartbeat 上游心跳间隔，默认为DefaultHeartbeat
+	Heartbeat time.Duration
+
+	// BufferSize 每个下游连接的发送缓冲区大小，缓冲区满时断开该连接，默认为DefaultBufferSize
+	BufferSize int
+
+	// WriteTimeout 向下游写入一条消息的超时时间，默认为DefaultWriteTimeout
+	WriteTimeout time.Duration
+
+	// RequestTimeout 转发R*请求和HTTP接口的超时时间，默认为qosapi.DefaultRequestTimeout
+	RequestTimeout time.Duration
+
+	// Logger 记录连接和订阅错误，为nil时不记录
+	Logger qosapi.Logger
+}
+
+// topic 一个订阅主题
+type topic struct {
+	typ       string
+	code      string
+	klineType int
+}
+
+// topicState 主题的下游订阅者和负责的上游连接
+type topicState struct {
+	upstream *upstream
+	conns    map[*downstream]struct{}
+}
+
+// upstream 上游连接
+type upstream struct {
+	client *qosapi.WSClient
+	topics int // 由Proxy.mu保护
+}
+
+// Stats 代理的运行状态
+type Stats struct {
+	Clients   int   // 下游WebSocket连接数
+	Topics    int   // 向上游订阅的主题数(类型、代码和K线类型的组合)
+	Upstreams []int // 每个上游连接上的主题数
+}
+
+// Proxy 行情代理，实现了http.Handler
+type Proxy struct {
+	opts      Options
+	rest      *qosapi.QOSClient
+	upstreams []*upstream
+	keys      map[string]struct{}
+	upgrader  websocket.Upgrader
+	mux       *http.ServeMux
+	next      int // 轮流选择转发R*请求的上游连接，由mu保护
+
+	// subMu 串行化订阅变更，向上游订阅和取消订阅时持有，避免阻塞推送转发
+	subMu sync.Mutex
+
+	mu     sync.Mutex
+	topics map[topic]*topicState
+	conns  map[*downstream]struct{}
+	closed bool
+}
+
+// New 创建代理并连接上游，任意上游连接失败时返回错误
+func New(opts Options) (*Proxy, error) {
+	if opts.Upstreams <= 0 {
+		opts.Upstreams = 1
+	}
+	if opts.Heartbeat <= 0 {
+		opts.Heartbeat = DefaultHeartbeat
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = DefaultWriteTimeout
+	}
+	if opts.RequestTimeout <= 0 {
+		opts.RequestTimeout = qosapi.DefaultRequestTimeout
+	}
+	if opts.Logger == nil {
+		opts.Logger = nopLogger{}
+	}
+
+	p := &Proxy{
+		opts:     opts,
+		rest:     qosapi.NewClient(opts.APIKey, opts.ClientOptions...),
+		upgrader: websocket.Upgrader{CheckOrigin: checkOrigin(opts.AllowedOrigins)},
+		mux:      http.NewServeMux(),
+		topics:   make(map[topic]*topicState),
+		conns:    make(map[*downstream]struct{}),
+	}
+	if len(opts.Keys) > 0 {
+		p.keys = make(map[string]struct{}, len(opts.Keys))
+		for _, key := range opts.Keys {
+			p.keys[key] = struct{}{}
+		}
+	}
+
+	for i := 0; i < opts.Upstreams; i++ {
+		client := qosapi.NewWSClient(opts.APIKey, opts.ClientOptions...)
+		if err := client.Connect(); err != nil {
+			p.closeUpstreams()
+			return nil, err
+		}
+		client.StartHeartbeat(opts.Heartbeat)
+		client.OnRawMessage(func(msg qosapi.RawMessage) { p.forward(msg.Data) })
+		p.upstreams = append(p.upstreams, &upstream{client: client})
+	}
+
+	p.mux.HandleFunc("/ws", p.handleWS)
+	for _, path := range restPaths {
+		p.mux.HandleFunc(path, p.handleREST(path))
+	}
+	return p, nil
+}
+
+// ServeHTTP 实现http.Handler，处理/ws和QOS的HTTP接口
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+// Close 断开所有下游连接并关闭上游连接
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	conns := make([]*downstream, 0, len(p.conns))
+	for d := range p.conns {
+		conns = append(conns, d)
+	}
+	p.mu.Unlock()
+
+	for _, d := range conns {
+		d.close()
+	}
+	return p.closeUpstreams()
+}
+
+func (p *Proxy) closeUpstreams() error {
+	var errs []error
+	for _, u := range p.upstreams {
+		errs = append(errs, u.client.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Stats 返回代理的运行状态
+func (p *Proxy) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := Stats{Clients: len(p.conns), Topics: len(p.topics), Upstreams: make([]int, len(p.upstreams))}
+	for i, u := range p.upstreams {
+		s.Upstreams[i] = u.topics
+	}
+	return s
+}
+
+// checkOrigin 返回WebSocket来源检查函数，allowed为空时使用gorilla默认的同源检查
+func checkOrigin(allowed []string) func(*http.Request) bool {
+	if len(allowed) == 0 {
+		return nil
+	}
+	if slices.Contains(allowed, "*") {
+		return func(*http.Request) bool { return true }
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, o := range allowed {
+			if strings.EqualFold(o, origin) {
+				return true
+			}
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+}
+
+// authorized 校验下游携带的key
+func (p *Proxy) authorized(key string) bool {
+	if p.keys == nil {
+		return true
+	}
+	_, ok := p.keys[key]
+	return ok
+}
+
+// response 返回给下游的响应，格式与QOS接口一致
+type response struct {
+	Type  string          `json:"type,omitempty"`
+	Msg   string          `json:"msg"`
+	Time  int64           `json:"time,omitempty"`
+	ReqID int             `json:"reqid,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// errorMsg 返回错误对应的msg，上游返回的错误保留原始msg
+func errorMsg(err error) string {
+	var apiErr *qosapi.APIError
+	if errors.As(err, &apiErr) && apiErr.Msg != "" {
+		return apiErr.Msg
+	}
+	return err.Error()
+}
+
+// handleREST 返回转发HTTP接口的函数，请求经过上游QOSClient的限流和重试
+func (p *Proxy) handleREST(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, response{Msg: "method not allowed"})
+			return
+		}
+		if !p.authorized(r.Header.Get("key")) {
+			writeJSON(w, http.StatusUnauthorized, response{Msg: "invalid api key"})
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil || !json.Valid(body) {
+			writeJSON(w, http.StatusBadRequest, response{Msg: "invalid request body"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), p.opts.RequestTimeout)
+		defer cancel()
+		data, err := qosapi.Call[json.RawMessage](ctx, p.rest, path, json.RawMessage(body))
+		if err != nil {
+			status := http.StatusBadGateway
+			var apiErr *qosapi.APIError
+			if errors.As(err, &apiErr) && apiErr.StatusCode != 0 {
+				status = apiErr.StatusCode
+			}
+			writeJSON(w, status, response{Msg: errorMsg(err)})
+			return
+		}
+		writeJSON(w, http.StatusOK, response{Msg: "OK", Data: data})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// pickUpstream 返回主题数最少的上游连接，调用方需持有p.mu
+func (p *Proxy) pickUpstream() *upstream {
+	return slices.MinFunc(p.upstreams, func(a, b *upstream) int { return a.topics - b.topics })
+}
+
+// requestUpstream 轮流返回用于转发R*请求的上游连接
+func (p *Proxy) requestUpstream() *upstream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	u := p.upstreams[p.next%len(p.upstreams)]
+	p.next++
+	return u
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...any) {}
@@ -0,0 +1,89 @@
+package qosproxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+	"github.com/qos-max/qos-quote-api-go-sdk/qosproxy"
+	"github.com/qos-max/qos-quote-api-go-sdk/qostest"
+)
+
+// startProxy 启动连接到模拟服务器的代理，返回代理的WebSocket地址
+func startProxy(t *testing.T, opts qosproxy.Options) string {
+	t.Helper()
+	srv := qostest.NewServer()
+	t.Cleanup(srv.Close)
+	opts.ClientOptions = append(opts.ClientOptions, qosapi.WithBaseURL(srv.URL()), qosapi.WithWSURL(srv.WSURL()))
+	proxy, err := qosproxy.New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { proxy.Close() })
+	ts := httptest.NewServer(proxy)
+	t.Cleanup(ts.Close)
+	return "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+}
+
+// dialStatus 以origin为Origin请求头连接代理，返回握手的HTTP状态码
+func dialStatus(t *testing.T, wsURL, origin string) int {
+	t.Helper()
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err == nil {
+		conn.Close()
+	}
+	if resp == nil {
+		t.Fatalf("dial %s: %v", wsURL, err)
+	}
+	return resp.StatusCode
+}
+
+func TestProxyOriginCheck(t *testing.T) {
+	wsURL := startProxy(t, qosproxy.Options{})
+	host := strings.TrimPrefix(strings.TrimSuffix(wsURL, "/ws"), "ws://")
+
+	tests := []struct {
+		origin string
+		want   int
+	}{
+		{"", http.StatusSwitchingProtocols},
+		{"http://" + host, http.StatusSwitchingProtocols},
+		{"https://evil.example.com", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if got := dialStatus(t, wsURL, tt.origin); got != tt.want {
+			t.Errorf("Origin %q: status %d, want %d", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestProxyAllowedOrigins(t *testing.T) {
+	wsURL := startProxy(t, qosproxy.Options{AllowedOrigins: []string{"https://app.example.com"}})
+
+	tests := []struct {
+		origin string
+		want   int
+	}{
+		{"https://app.example.com", http.StatusSwitchingProtocols},
+		{"HTTPS://APP.EXAMPLE.COM", http.StatusSwitchingProtocols},
+		{"https://evil.example.com", http.StatusForbidden},
+		{"", http.StatusSwitchingProtocols},
+	}
+	for _, tt := range tests {
+		if got := dialStatus(t, wsURL, tt.origin); got != tt.want {
+			t.Errorf("Origin %q: status %d, want %d", tt.origin, got, tt.want)
+		}
+	}
+
+	wsURL = startProxy(t, qosproxy.Options{AllowedOrigins: []string{"*"}})
+	if got := dialStatus(t, wsURL, "https://anywhere.example.com"); got != http.StatusSwitchingProtocols {
+		t.Errorf("wildcard origin: status %d, want 101", got)
+	}
+}
@@ -0,0 +1,191 @@
+package qosproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+// expandCodes 解析并规范化代码，"US:AAPL,TSLA"形式的代码拆分为单个代码
+func expandCodes(codes []string) ([]string, error) {
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("%w: no codes", qosapi.ErrInvalidCode)
+	}
+	var result []string
+	for _, code := range codes {
+		symbols, err := qosapi.ParseSymbols(code)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range symbols {
+			result = append(result, s.String())
+		}
+	}
+	return result, nil
+}
+
+// subscribe 在上游订阅主题
+func (u *upstream) subscribe(typ string, klineType int, codes []string) error {
+	// 推送通过OnRawMessage原样转发，回调只用于保持上游订阅
+	var err error
+	switch typ {
+	case "S":
+		_, err = u.client.OnSnapshot(codes, func(qosapi.WSSnapshot) {})
+	case "T":
+		_, err = u.client.OnTrade(codes, func(qosapi.WSTrade) {})
+	case "D":
+		_, err = u.client.OnDepth(codes, func(qosapi.WSDepth) {})
+	case "K":
+		_, err = u.client.OnKLine(codes, klineType, func(qosapi.WSKLine) {})
+	}
+	return err
+}
+
+// unsubscribe 在上游取消订阅主题
+func (u *upstream) unsubscribe(typ string, klineType int, codes []string) error {
+	switch typ {
+	case "S":
+		return u.client.UnsubscribeSnapshot(codes)
+	case "T":
+		return u.client.UnsubscribeTrade(codes)
+	case "D":
+		return u.client.UnsubscribeDepth(codes)
+	case "K":
+		return u.client.UnsubscribeKLine(codes, klineType)
+	}
+	return nil
+}
+
+// subscribe 为下游连接订阅代码，只有此前没有任何下游订阅的代码才向上游订阅
+func (p *Proxy) subscribe(d *downstream, typ string, klineType int, codes []string) error {
+	codes, err := expandCodes(codes)
+	if err != nil {
+		return err
+	}
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+
+	p.mu.Lock()
+	var added []string
+	for _, code := range codes {
+		t := topic{typ: typ, code: code, klineType: klineType}
+		if state, ok := p.topics[t]; ok {
+			state.conns[d] = struct{}{}
+			d.topics[t] = struct{}{}
+		} else {
+			added = append(added, code)
+		}
+	}
+	if len(added) == 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	u := p.pickUpstream()
+	p.mu.Unlock()
+
+	if err := u.subscribe(typ, klineType, added); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, code := range added {
+		t := topic{typ: typ, code: code, klineType: klineType}
+		p.topics[t] = &topicState{upstream: u, conns: map[*downstream]struct{}{d: {}}}
+		d.topics[t] = struct{}{}
+		u.topics++
+	}
+	return nil
+}
+
+// unsubscribe 为下游连接取消订阅代码
+func (p *Proxy) unsubscribe(d *downstream, typ string, klineType int, codes []string) error {
+	codes, err := expandCodes(codes)
+	if err != nil {
+		return err
+	}
+	topics := make([]topic, len(codes))
+	for i, code := range codes {
+		topics[i] = topic{typ: typ, code: code, klineType: klineType}
+	}
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+	p.releaseLocked(d, topics)
+	return nil
+}
+
+// releaseLocked 移除下游连接对主题的订阅，不再有订阅者的主题向上游取消订阅。调用方需持有p.subMu
+func (p *Proxy) releaseLocked(d *downstream, topics []topic) {
+	type group struct {
+		u         *upstream
+		typ       string
+		klineType int
+	}
+	removed := make(map[group][]string)
+
+	p.mu.Lock()
+	for _, t := range topics {
+		if _, ok := d.topics[t]; !ok {
+			continue
+		}
+		delete(d.topics, t)
+		state := p.topics[t]
+		delete(state.conns, d)
+		if len(state.conns) == 0 {
+			delete(p.topics, t)
+			state.upstream.topics--
+			g := group{state.upstream, t.typ, t.klineType}
+			removed[g] = append(removed[g], t.code)
+		}
+	}
+	p.mu.Unlock()
+
+	for g, codes := range removed {
+		// 上游未连接时服务端已无订阅，重连时也不会再恢复
+		if err := g.u.unsubscribe(g.typ, g.klineType, codes); err != nil && !errors.Is(err, qosapi.ErrNotConnected) {
+			p.opts.Logger.Printf("Failed to unsubscribe %s %v upstream: %v", g.typ, codes, err)
+		}
+	}
+}
+
+// pushHeader 推送消息中用于路由的字段
+type pushHeader struct {
+	TP        string `json:"tp"`
+	Code      string `json:"c"`
+	KLineType int    `json:"kt"`
+}
+
+// forward 将上游推送原样转发给订阅了该主题的下游连接
+func (p *Proxy) forward(message []byte) {
+	var h pushHeader
+	if err := json.Unmarshal(message, &h); err != nil {
+		return
+	}
+	switch h.TP {
+	case "S", "T", "D":
+		h.KLineType = 0
+	case "K":
+	default:
+		return
+	}
+	if s, err := qosapi.ParseSymbol(h.Code); err == nil {
+		h.Code = s.String()
+	}
+
+	p.mu.Lock()
+	state, ok := p.topics[topic{typ: h.TP, code: h.Code, klineType: h.KLineType}]
+	var targets []*downstream
+	if ok {
+		targets = make([]*downstream, 0, len(state.conns))
+		for d := range state.conns {
+			targets = append(targets, d)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, d := range targets {
+		d.enqueue(message)
+	}
+}
@@ -0,0 +1,133 @@
+package qostest
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+// request HTTP和WebSocket请求共用的请求参数
+type request struct {
+	Codes     []string              `json:"codes"`
+	Count     int                   `json:"count"`
+	KLineReqs []qosapi.KLineRequest `json:"kline_reqs"`
+}
+
+// response 接口响应
+type response struct {
+	Type  string `json:"type,omitempty"`
+	Msg   string `json:"msg"`
+	Time  int64  `json:"time,omitempty"`
+	ReqID int    `json:"reqid,omitempty"`
+	Data  any    `json:"data,omitempty"`
+}
+
+// klineGroup K线接口返回的单个代码的K线
+type klineGroup struct {
+	Code string         `json:"c"`
+	K    []qosapi.KLine `json:"k"`
+}
+
+// handleHTTP 返回处理HTTP接口的函数
+func (s *Server) handleHTTP(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		injected, latency := s.beginRequest(path)
+		if latency > 0 {
+			time.Sleep(latency)
+		}
+
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, response{Msg: "method not allowed"})
+			return
+		}
+		if s.APIKey != "" && r.Header.Get("key") != s.APIKey {
+			writeJSON(w, http.StatusUnauthorized, response{Msg: "invalid api key"})
+			return
+		}
+		if injected != nil {
+			status := injected.statusCode
+			if status == 0 {
+				status = http.StatusOK
+			}
+			writeJSON(w, status, response{Msg: injected.msg})
+			return
+		}
+
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, response{Msg: "invalid request body"})
+			return
+		}
+		writeJSON(w, http.StatusOK, response{Msg: "OK", Data: s.query(path, req)})
+	}
+}
+
+// query 按接口路径查询数据
+func (s *Server) query(path string, req request) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch path {
+	case "/instrument-info":
+		return collect(s.instruments, req.Codes)
+	case "/snapshot":
+		return collect(s.snapshots, req.Codes)
+	case "/depth":
+		return collect(s.depths, req.Codes)
+	case "/trade":
+		result := []qosapi.Trade{}
+		for _, code := range expandCodes(req.Codes) {
+			result = append(result, latest(s.trades[code], req.Count)...)
+		}
+		return result
+	case "/kline", "/history":
+		result := []klineGroup{}
+		for _, kr := range req.KLineReqs {
+			for _, code := range expandCodes([]string{kr.Codes}) {
+				klines := s.klines[klineKey{code, kr.KLineType}]
+				if path == "/history" && kr.EndTime > 0 {
+					klines = until(klines, kr.EndTime)
+				}
+				result = append(result, klineGroup{Code: code, K: latest(klines, kr.Count)})
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// collect 按请求代码的顺序取出数据，不存在的代码被忽略
+func collect[T any](items map[string]T, codes []string) []T {
+	result := []T{}
+	for _, code := range expandCodes(codes) {
+		if item, ok := items[code]; ok {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// latest 返回最后count条数据，count小于等于0时返回全部
+func latest[T any](items []T, count int) []T {
+	if count > 0 && len(items) > count {
+		items = items[len(items)-count:]
+	}
+	return append([]T{}, items...)
+}
+
+// until 返回时间戳不晚于endTime的K线
+func until(klines []qosapi.KLine, endTime int64) []qosapi.KLine {
+	n := len(klines)
+	for n > 0 && klines[n-1].Timestamp > endTime {
+		n--
+	}
+	return klines[:n]
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,225 @@
+// Package qostest 提供用于测试的本地QOS模拟服务器，实现与api.qos.hk相同的HTTP接口和WebSocket协议，
+// 数据由测试代码通过Set*方法设置，并支持注入错误、延迟和断开连接。
+//
+//	srv := qostest.NewServer()
+//	defer srv.Close()
+//	srv.SetSnapshot(qosapi.Snapshot{Code: "US:AAPL", LastPrice: "200.1"})
+//	client := srv.Client()
+//	snapshots, err := client.GetSnapshot([]string{"US:AAPL"})
+package qostest
+
+import (
+	"cmp"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+// Server 本地QOS模拟服务器
+type Server struct {
+	// APIKey 不为空时，请求必须携带该API Key，否则返回鉴权错误
+	APIKey string
+
+	srv *httptest.Server
+
+	mu          sync.Mutex
+	instruments map[string]qosapi.InstrumentInfo
+	snapshots   map[string]qosapi.Snapshot
+	depths      map[string]qosapi.Depth
+	trades      map[string][]qosapi.Trade
+	klines      map[klineKey][]qosapi.KLine
+	errors      map[string]*injectedError
+	latency     time.Duration
+	requests    map[string]int
+	conns       map[*wsConn]struct{}
+}
+
+// klineKey K线数据的键
+type klineKey struct {
+	code      string
+	klineType int
+}
+
+// injectedError 注入的错误
+type injectedError struct {
+	statusCode int
+	msg        string
+	remaining  int // 剩余次数，小于0表示一直生效
+}
+
+// NewServer 创建并启动模拟服务器
+func NewServer() *Server {
+	s := &Server{
+		instruments: make(map[string]qosapi.InstrumentInfo),
+		snapshots:   make(map[string]qosapi.Snapshot),
+		depths:      make(map[string]qosapi.Depth),
+		trades:      make(map[string][]qosapi.Trade),
+		klines:      make(map[klineKey][]qosapi.KLine),
+		errors:      make(map[string]*injectedError),
+		requests:    make(map[string]int),
+		conns:       make(map[*wsConn]struct{}),
+	}
+
+	mux := http.NewServeMux()
+	for _, path := range []string{"/instrument-info", "/snapshot", "/depth", "/trade", "/kline", "/history"} {
+		mux.HandleFunc(path, s.handleHTTP(path))
+	}
+	mux.HandleFunc("/ws", s.handleWS)
+	s.srv = httptest.NewServer(mux)
+	return s
+}
+
+// URL 返回HTTP接口基础URL
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// WSURL 返回WebSocket地址
+func (s *Server) WSURL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http") + "/ws"
+}
+
+// Client 创建连接到模拟服务器的QOSClient
+func (s *Server) Client(opts ...qosapi.Option) *qosapi.QOSClient {
+	opts = append([]qosapi.Option{qosapi.WithBaseURL(s.URL())}, opts...)
+	return qosapi.NewClient(s.APIKey, opts...)
+}
+
+// WSClient 创建连接到模拟服务器的WSClient(未连接)
+func (s *Server) WSClient(opts ...qosapi.Option) *qosapi.WSClient {
+	opts = append([]qosapi.Option{qosapi.WithWSURL(s.WSURL())}, opts...)
+	return qosapi.NewWSClient(s.APIKey, opts...)
+}
+
+// Close 断开所有WebSocket连接并关闭服务器
+func (s *Server) Close() {
+	s.Disconnect()
+	s.srv.Close()
+}
+
+// SetInstrumentInfo 设置品种基础信息
+func (s *Server) SetInstrumentInfo(items ...qosapi.InstrumentInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range items {
+		s.instruments[canonical(item.Code)] = item
+	}
+}
+
+// SetSnapshot 设置行情快照
+func (s *Server) SetSnapshot(items ...qosapi.Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range items {
+		s.snapshots[canonical(item.Code)] = item
+	}
+}
+
+// SetDepth 设置盘口深度
+func (s *Server) SetDepth(items ...qosapi.Depth) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range items {
+		s.depths[canonical(item.Code)] = item
+	}
+}
+
+// SetTrades 设置某个代码的逐笔成交，按时间戳排序
+func (s *Server) SetTrades(code string, trades ...qosapi.Trade) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	trades = slices.Clone(trades)
+	slices.SortStableFunc(trades, func(a, b qosapi.Trade) int { return cmp.Compare(a.Timestamp, b.Timestamp) })
+	s.trades[canonical(code)] = trades
+}
+
+// SetKLines 设置某个代码某种K线类型的K线，/kline和/history接口共用，按时间戳排序
+func (s *Server) SetKLines(code string, klineType int, klines ...qosapi.KLine) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	klines = slices.Clone(klines)
+	slices.SortStableFunc(klines, func(a, b qosapi.KLine) int { return cmp.Compare(a.Timestamp, b.Timestamp) })
+	s.klines[klineKey{canonical(code), klineType}] = klines
+}
+
+// InjectError 让指定接口返回错误。endpoint为HTTP路径(如"/snapshot")或WebSocket请求类型(如"RS")，
+// "/ws"表示拒绝WebSocket握手；statusCode仅对HTTP接口和"/ws"有效，为0时HTTP接口返回200和错误msg，
+// "/ws"返回503；times为生效次数，小于等于0表示一直生效直到ClearErrors
+func (s *Server) InjectError(endpoint string, statusCode int, msg string, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if times <= 0 {
+		times = -1
+	}
+	s.errors[endpoint] = &injectedError{statusCode: statusCode, msg: msg, remaining: times}
+}
+
+// ClearErrors 清除所有注入的错误
+func (s *Server) ClearErrors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.errors)
+}
+
+// SetLatency 设置每个请求响应前的延迟
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// RequestCount 返回指定接口收到的请求数，endpoint含义同InjectError
+func (s *Server) RequestCount(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[endpoint]
+}
+
+// beginRequest 记录请求并返回需要注入的错误和延迟
+func (s *Server) beginRequest(endpoint string) (*injectedError, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[endpoint]++
+	e, ok := s.errors[endpoint]
+	if !ok {
+		return nil, s.latency
+	}
+	if e.remaining > 0 {
+		e.remaining--
+		if e.remaining == 0 {
+			delete(s.errors, endpoint)
+		}
+	}
+	return e, s.latency
+}
+
+// canonical 返回代码的规范形式
+func canonical(code string) string {
+	sym, err := qosapi.ParseSymbol(code)
+	if err != nil {
+		return code
+	}
+	return sym.String()
+}
+
+// expandCodes 将请求中的代码拆分为规范形式的单个代码
+func expandCodes(codes []string) []string {
+	var result []string
+	for _, code := range codes {
+		symbols, err := qosapi.ParseSymbols(code)
+		if err != nil {
+			result = append(result, code)
+			continue
+		}
+		for _, sym := range symbols {
+			result = append(result, sym.String())
+		}
+	}
+	return result
+}
@@ -0,0 +1,234 @@
+package qostest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/qos-max/qos-quote-api-go-sdk/qosapi"
+)
+
+// requestPaths WebSocket请求类型对应的HTTP接口
+var requestPaths = map[string]string{
+	"RS": "/snapshot",
+	"RT": "/trade",
+	"RD": "/depth",
+	"RK": "/kline",
+	"RH": "/history",
+	"RI": "/instrument-info",
+}
+
+// subKey 一个连接上的订阅
+type subKey struct {
+	typ       string
+	code      string
+	klineType int
+}
+
+// wsConn 模拟服务器上的WebSocket连接
+type wsConn struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	subs    map[subKey]struct{} // 由Server.mu保护
+}
+
+func (c *wsConn) write(v any) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// handleWS 处理WebSocket连接
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	if s.APIKey != "" && r.URL.Query().Get("key") != s.APIKey {
+		writeJSON(w, http.StatusUnauthorized, response{Msg: "invalid api key"})
+		return
+	}
+	if injected, _ := s.beginRequest("/ws"); injected != nil {
+		status := injected.statusCode
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, response{Msg: injected.msg})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &wsConn{conn: conn, subs: make(map[subKey]struct{})}
+
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req qosapi.WSRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			c.write(response{Msg: "invalid request"})
+			continue
+		}
+		s.handleWSRequest(c, req)
+	}
+}
+
+// handleWSRequest 处理一条WebSocket请求
+func (s *Server) handleWSRequest(c *wsConn, req qosapi.WSRequest) {
+	switch req.Type {
+	case "S", "T", "D", "K", "SC", "TC", "DC", "KC":
+		if injected, _ := s.beginRequest(req.Type); injected != nil {
+			c.write(response{Type: req.Type, Msg: injected.msg, ReqID: req.ReqID})
+			return
+		}
+		s.updateSubscriptions(c, req)
+		c.write(response{Type: req.Type, Msg: "OK", ReqID: req.ReqID})
+	case "H":
+		s.beginRequest(req.Type)
+		c.write(response{Type: req.Type, Msg: "OK", Time: time.Now().Unix(), ReqID: req.ReqID})
+	default:
+		path, ok := requestPaths[req.Type]
+		if !ok {
+			c.write(response{Type: req.Type, Msg: "unknown request type", ReqID: req.ReqID})
+			return
+		}
+		injected, latency := s.beginRequest(req.Type)
+		// 在独立的goroutine中响应，延迟不会阻塞同一连接上的其他请求
+		go func() {
+			if latency > 0 {
+				time.Sleep(latency)
+			}
+			if injected != nil {
+				c.write(response{Type: req.Type, Msg: injected.msg, ReqID: req.ReqID})
+				return
+			}
+			data := s.query(path, request{Codes: req.Codes, Count: req.Count, KLineReqs: req.KLineReqs})
+			c.write(response{Type: req.Type, Msg: "OK", ReqID: req.ReqID, Data: data})
+		}()
+	}
+}
+
+// updateSubscriptions 更新连接的订阅
+func (s *Server) updateSubscriptions(c *wsConn, req qosapi.WSRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	typ, cancel := req.Type, false
+	if len(typ) == 2 {
+		typ, cancel = typ[:1], true
+	}
+	for _, code := range expandCodes(req.Codes) {
+		key := subKey{typ: typ, code: code}
+		if typ == "K" {
+			key.klineType = req.KLineType
+		}
+		if cancel {
+			delete(c.subs, key)
+		} else {
+			c.subs[key] = struct{}{}
+		}
+	}
+}
+
+// push 向订阅了key的所有连接推送数据，返回推送的连接数
+func (s *Server) push(key subKey, v any) int {
+	s.mu.Lock()
+	var targets []*wsConn
+	for c := range s.conns {
+		if _, ok := c.subs[key]; ok {
+			targets = append(targets, c)
+		}
+	}
+	s.mu.Unlock()
+
+	n := 0
+	for _, c := range targets {
+		if c.write(v) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// PushSnapshot 向订阅了该代码的连接推送快照，返回推送的连接数
+func (s *Server) PushSnapshot(v qosapi.WSSnapshot) int {
+	v.Type = "S"
+	return s.push(subKey{typ: "S", code: canonical(v.Code)}, v)
+}
+
+// PushTrade 向订阅了该代码的连接推送逐笔成交，返回推送的连接数
+func (s *Server) PushTrade(v qosapi.WSTrade) int {
+	v.Type = "T"
+	return s.push(subKey{typ: "T", code: canonical(v.Code)}, v)
+}
+
+// PushDepth 向订阅了该代码的连接推送盘口，返回推送的连接数
+func (s *Server) PushDepth(v qosapi.WSDepth) int {
+	v.Type = "D"
+	return s.push(subKey{typ: "D", code: canonical(v.Code)}, v)
+}
+
+// PushKLine 向订阅了该代码和K线类型的连接推送K线，返回推送的连接数
+func (s *Server) PushKLine(v qosapi.WSKLine) int {
+	v.Type = "K"
+	return s.push(subKey{typ: "K", code: canonical(v.Code), klineType: v.KLineType}, v)
+}
+
+// Subscribed 是否有连接订阅了该类型("S"、"T"、"D")和代码
+func (s *Server) Subscribed(typ, code string) bool {
+	return s.subscribed(subKey{typ: typ, code: canonical(code)})
+}
+
+// SubscribedKLine 是否有连接订阅了该代码和K线类型
+func (s *Server) SubscribedKLine(code string, klineType int) bool {
+	return s.subscribed(subKey{typ: "K", code: canonical(code), klineType: klineType})
+}
+
+func (s *Server) subscribed(key subKey) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		if _, ok := c.subs[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Connections 返回当前WebSocket连接数
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// Disconnect 强制断开所有WebSocket连接(不发送关闭帧)，用于测试断线重连
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	conns := make([]*wsConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.conn.UnderlyingConn().Close()
+	}
+}